@@ -0,0 +1,384 @@
+// Package cluster spreads a cache across several processes, in the manner
+// of groupcache, so that each key is cached by one node instead of by every
+// replica of a service. Nodes agree on each key's owner through a
+// consistent hash ring, fetch keys they do not own from the owner over
+// HTTP, and keep recently fetched values in a small local hot cache.
+package cluster
+
+/*
+Each node is identified by the base URL its Node handler is served at, such as "http://10.0.0.1:8080/_zwis". A node stores the keys it owns in its local cache and forwards reads and writes of other keys to their owners, which serve them through the httpapi protocol. Owners only ever consult their local cache for requests from peers, so nodes whose membership views briefly disagree cannot forward a request in a loop.
+
+Values fetched from peers are kept in the hot cache for the hot TTL. A node that writes or deletes a key drops its own hot copy, but other nodes may serve their copies until the hot TTL runs out, which bounds how stale a read can be.
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/httpapi"
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+const (
+	defaultHotCapacity = 1024
+	defaultHotTTL      = time.Minute
+)
+
+// Stats reports where a Node's reads were served from.
+type Stats struct {
+	LocalHits  uint64 // Reads of owned keys found in the local cache
+	HotHits    uint64 // Reads of other nodes' keys found in the hot cache
+	PeerHits   uint64 // Reads of other nodes' keys fetched from the owner
+	Misses     uint64 // Reads that found nothing, including failed fetches
+	PeerErrors uint64 // Requests to other nodes that failed
+}
+
+// TypedNode is one member of a cluster of caches. It implements zwis.Cache
+// for the whole cluster, and serves the keys it owns to other members as
+// an http.Handler.
+//
+// Get cannot report errors through the zwis.Cache interface, so it treats a
+// failed fetch from a peer as a miss; use Lookup to see the error.
+type TypedNode[V any] struct {
+	self    string
+	local   zwis.TypedCache[string, V]
+	hot     zwis.TypedCache[string, V]
+	hotTTL  time.Duration
+	codec   zwis.Codec[V]
+	client  *http.Client
+	ring    *Ring
+	handler http.Handler
+
+	mu    sync.RWMutex
+	peers map[string]*httpapi.TypedClient[V] // clients for every node but self
+
+	localHits  atomic.Uint64
+	hotHits    atomic.Uint64
+	peerHits   atomic.Uint64
+	misses     atomic.Uint64
+	peerErrors atomic.Uint64
+}
+
+// Node is a TypedNode holding interface{} values.
+type Node = TypedNode[interface{}]
+
+var _ zwis.Cache = (*Node)(nil)
+
+// Option configures a Node.
+type Option func(*options)
+
+type options struct {
+	replicas int
+	hotCache interface{}
+	hotTTL   time.Duration
+	client   *http.Client
+	codec    interface{}
+}
+
+// WithReplicas sets the number of virtual nodes each node gets on the hash
+// ring. Every node in a cluster must use the same number. The default is
+// DefaultReplicas.
+func WithReplicas(n int) Option {
+	return func(o *options) {
+		o.replicas = n
+	}
+}
+
+// WithHotCache sets the cache that holds values fetched from other nodes.
+// The default is an LRU cache of 1024 entries.
+func WithHotCache[V any](cache zwis.TypedCache[string, V]) Option {
+	return func(o *options) {
+		o.hotCache = cache
+	}
+}
+
+// WithHotTTL sets how long values fetched from other nodes are kept in the
+// hot cache, and so how stale a read of another node's key can be. The
+// default is one minute.
+func WithHotTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.hotTTL = ttl
+	}
+}
+
+// WithHTTPClient sets the http.Client used for requests to other nodes.
+// The default is http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithCodec sets the codec used to send values of type V between nodes.
+// Every node in a cluster must use the same codec. The default is
+// zwis.GobCodec.
+func WithCodec[V any](codec zwis.Codec[V]) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+// NewNode creates a cluster member that stores the keys it owns in local.
+// self is the base URL the node's handler is served at, and is how other
+// nodes refer to it. The node starts as the only member; call SetPeers to
+// add the others.
+func NewNode(self string, local zwis.Cache, opts ...Option) (*Node, error) {
+	return NewTypedNode[interface{}](self, local, opts...)
+}
+
+// NewTypedNode creates a cluster member that stores the keys it owns in
+// local. self is the base URL the node's handler is served at.
+func NewTypedNode[V any](self string, local zwis.TypedCache[string, V], opts ...Option) (*TypedNode[V], error) {
+	self = strings.TrimSuffix(self, "/")
+	if err := checkURL(self); err != nil {
+		return nil, err
+	}
+
+	o := options{hotTTL: defaultHotTTL, client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	valueType := reflect.TypeOf((*V)(nil)).Elem()
+	var codec zwis.Codec[V] = zwis.GobCodec[V]{}
+	if o.codec != nil {
+		var ok bool
+		if codec, ok = o.codec.(zwis.Codec[V]); !ok {
+			return nil, fmt.Errorf("cluster: codec %T does not encode %v values", o.codec, valueType)
+		}
+	}
+	var hot zwis.TypedCache[string, V] = zwis.NewTypedLRUCache[string, V](defaultHotCapacity)
+	if o.hotCache != nil {
+		var ok bool
+		if hot, ok = o.hotCache.(zwis.TypedCache[string, V]); !ok {
+			return nil, fmt.Errorf("cluster: hot cache %T does not hold %v values", o.hotCache, valueType)
+		}
+	}
+
+	n := &TypedNode[V]{
+		self:   self,
+		local:  local,
+		hot:    hot,
+		hotTTL: o.hotTTL,
+		codec:  codec,
+		client: o.client,
+		ring:   NewRing(o.replicas),
+		peers:  make(map[string]*httpapi.TypedClient[V]),
+	}
+	n.ring.Add(self)
+	n.handler = httpapi.NewHandler(ownerView[V]{n})
+	return n, nil
+}
+
+func checkURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("cluster: invalid node URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("cluster: invalid node URL %q: scheme must be http or https", s)
+	}
+	return nil
+}
+
+// SetPeers replaces the cluster's membership with nodes, the base URLs of
+// every member. The node itself is always a member, whether or not it is
+// listed. Keys whose owner changes are not moved; they are missed at their
+// new owner until written again.
+func (n *TypedNode[V]) SetPeers(nodes ...string) error {
+	members := []string{n.self}
+	peers := make(map[string]*httpapi.TypedClient[V], len(nodes))
+	for _, node := range nodes {
+		node = strings.TrimSuffix(node, "/")
+		if node == n.self {
+			continue
+		}
+		if err := checkURL(node); err != nil {
+			return err
+		}
+		client, err := httpapi.NewTypedClient[V](node,
+			httpapi.WithHTTPClient(n.client),
+			httpapi.WithCodec[V](n.codec))
+		if err != nil {
+			return err
+		}
+		peers[node] = client
+		members = append(members, node)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.peers = peers
+	n.ring.Set(members...)
+	return nil
+}
+
+// Peers returns the base URLs of every member, including this node, in
+// sorted order.
+func (n *TypedNode[V]) Peers() []string {
+	return n.ring.Nodes()
+}
+
+// Owner returns the base URL of the node that owns key.
+func (n *TypedNode[V]) Owner(key string) string {
+	return n.ring.Owner(key)
+}
+
+// owner returns the node that owns key and a client for it, or a nil
+// client if this node owns it.
+func (n *TypedNode[V]) owner(key string) (string, *httpapi.TypedClient[V]) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	owner := n.ring.Owner(key)
+	return owner, n.peers[owner]
+}
+
+// Lookup is Get that also reports a failure to fetch the key from its
+// owner.
+func (n *TypedNode[V]) Lookup(ctx context.Context, key string) (V, bool, error) {
+	owner, peer := n.owner(key)
+	if peer == nil {
+		value, ok := n.local.Get(ctx, key)
+		n.count(ok, &n.localHits)
+		return value, ok, nil
+	}
+
+	if value, ok := n.hot.Get(ctx, key); ok {
+		n.hotHits.Add(1)
+		return value, true, nil
+	}
+	value, ok, err := peer.Lookup(ctx, key)
+	if err != nil {
+		n.peerErrors.Add(1)
+		n.misses.Add(1)
+		return value, false, fmt.Errorf("cluster: fetching %q from %s: %w", key, owner, err)
+	}
+	if ok {
+		n.hot.Set(ctx, key, value, n.hotTTL)
+	}
+	n.count(ok, &n.peerHits)
+	return value, ok, nil
+}
+
+func (n *TypedNode[V]) count(hit bool, hits *atomic.Uint64) {
+	if hit {
+		hits.Add(1)
+	} else {
+		n.misses.Add(1)
+	}
+}
+
+// Get returns key's value from the local cache if this node owns it, and
+// otherwise from the hot cache or the owner.
+func (n *TypedNode[V]) Get(ctx context.Context, key string) (V, bool) {
+	value, ok, _ := n.Lookup(ctx, key)
+	return value, ok
+}
+
+// Set stores the value on key's owner.
+func (n *TypedNode[V]) Set(ctx context.Context, key string, value V, ttl time.Duration) error {
+	owner, peer := n.owner(key)
+	if peer == nil {
+		return n.local.Set(ctx, key, value, ttl)
+	}
+	n.hot.Delete(ctx, key)
+	return n.peerErr(owner, peer.Set(ctx, key, value, ttl))
+}
+
+// Delete deletes key from its owner.
+func (n *TypedNode[V]) Delete(ctx context.Context, key string) error {
+	owner, peer := n.owner(key)
+	if peer == nil {
+		return n.local.Delete(ctx, key)
+	}
+	n.hot.Delete(ctx, key)
+	return n.peerErr(owner, peer.Delete(ctx, key))
+}
+
+// Flush empties the local and hot caches of every member.
+func (n *TypedNode[V]) Flush(ctx context.Context) error {
+	n.mu.RLock()
+	peers := n.peers
+	n.mu.RUnlock()
+
+	errs := []error{n.flushLocal(ctx)}
+	for node, peer := range peers {
+		errs = append(errs, n.peerErr(node, peer.Flush(ctx)))
+	}
+	return errors.Join(errs...)
+}
+
+func (n *TypedNode[V]) flushLocal(ctx context.Context) error {
+	return errors.Join(n.local.Flush(ctx), n.hot.Flush(ctx))
+}
+
+// peerErr counts and describes an error from a request to node.
+func (n *TypedNode[V]) peerErr(node string, err error) error {
+	if err == nil {
+		return nil
+	}
+	n.peerErrors.Add(1)
+	return fmt.Errorf("cluster: %s: %w", node, err)
+}
+
+// Stats reports where the node's reads were served from.
+func (n *TypedNode[V]) Stats() Stats {
+	return Stats{
+		LocalHits:  n.localHits.Load(),
+		HotHits:    n.hotHits.Load(),
+		PeerHits:   n.peerHits.Load(),
+		Misses:     n.misses.Load(),
+		PeerErrors: n.peerErrors.Load(),
+	}
+}
+
+// ServeHTTP serves the keys this node owns to the other members.
+func (n *TypedNode[V]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.handler.ServeHTTP(w, r)
+}
+
+// ownerView presents a node's local cache to httpapi.Handler, with values
+// encoded by the node's codec as the handler's clients expect. It never
+// forwards to other nodes.
+type ownerView[V any] struct {
+	n *TypedNode[V]
+}
+
+func (v ownerView[V]) Get(ctx context.Context, key string) (interface{}, bool) {
+	value, ok := v.n.local.Get(ctx, key)
+	if !ok {
+		return nil, false
+	}
+	data, err := v.n.codec.Encode(value)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (v ownerView[V]) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("cluster: unexpected %T value", value)
+	}
+	decoded, err := v.n.codec.Decode(data)
+	if err != nil {
+		return fmt.Errorf("cluster: decoding %q: %w", key, err)
+	}
+	return v.n.local.Set(ctx, key, decoded, ttl)
+}
+
+func (v ownerView[V]) Delete(ctx context.Context, key string) error {
+	return v.n.local.Delete(ctx, key)
+}
+
+func (v ownerView[V]) Flush(ctx context.Context) error {
+	return v.n.flushLocal(ctx)
+}
@@ -0,0 +1,133 @@
+package cluster
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultReplicas is the number of virtual nodes each node gets on a Ring
+// when none is given.
+const DefaultReplicas = 50
+
+// Ring assigns keys to nodes by consistent hashing. Each node is placed at
+// several points, its virtual nodes, and a key belongs to the node at the
+// first point at or after the key's hash. Adding or removing a node only
+// moves the keys next to its points, and rings built from the same nodes
+// agree on every key whatever order the nodes were added in.
+//
+// A Ring is safe for concurrent use.
+type Ring struct {
+	replicas int
+
+	mu     sync.RWMutex
+	nodes  map[string]struct{}
+	hashes []uint64          // sorted points on the ring
+	owners map[uint64]string // node at each point
+}
+
+// NewRing creates an empty ring giving each node replicas virtual nodes,
+// or DefaultReplicas if replicas is not positive.
+func NewRing(replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	return &Ring{replicas: replicas, nodes: make(map[string]struct{})}
+}
+
+// Add adds nodes to the ring. Nodes already on it are ignored.
+func (r *Ring) Add(nodes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, node := range nodes {
+		r.nodes[node] = struct{}{}
+	}
+	r.rebuild()
+}
+
+// Remove removes nodes from the ring.
+func (r *Ring) Remove(nodes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, node := range nodes {
+		delete(r.nodes, node)
+	}
+	r.rebuild()
+}
+
+// Set replaces the ring's nodes with nodes.
+func (r *Ring) Set(nodes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes = make(map[string]struct{}, len(nodes))
+	for _, node := range nodes {
+		r.nodes[node] = struct{}{}
+	}
+	r.rebuild()
+}
+
+// rebuild recomputes the points from r.nodes. r.mu must be held.
+func (r *Ring) rebuild() {
+	r.hashes = make([]uint64, 0, len(r.nodes)*r.replicas)
+	r.owners = make(map[uint64]string, len(r.nodes)*r.replicas)
+	for node := range r.nodes {
+		for i := 0; i < r.replicas; i++ {
+			h := hashString(strconv.Itoa(i) + "#" + node)
+			// Settle collisions the same way on every ring, whatever the
+			// order of map iteration.
+			if owner, ok := r.owners[h]; ok {
+				if node < owner {
+					r.owners[h] = node
+				}
+				continue
+			}
+			r.owners[h] = node
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+}
+
+// Owner returns the node key belongs to, or "" if the ring is empty.
+func (r *Ring) Owner(key string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := hashString(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+// Nodes returns the ring's nodes in sorted order.
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// hashString is FNV-1a followed by the MurmurHash3 finaliser, which spreads
+// the similar strings naming virtual nodes evenly around the ring. It must
+// not change, since every node in a cluster has to agree on it.
+func hashString(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
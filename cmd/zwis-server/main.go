@@ -0,0 +1,108 @@
+// Command zwis-server serves a zwis cache over the Redis protocol and,
+// optionally, the memcached protocol and an HTTP API.
+//
+// Usage:
+//
+//	zwis-server [-addr :6379] [-memcached-addr :11211] [-http-addr :8080] [-type lru] [-capacity 100000] [-shards 0] [-default-ttl 0] [-janitor 0] [-dir path]
+//
+// Any Redis client can then connect to it, for example redis-cli -p 6379,
+// any memcached client to the memcached address, and HTTP clients such as
+// httpapi.Client to the HTTP address, if those are given.
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/NonsoAmadi10/zwis/httpapi"
+	"github.com/NonsoAmadi10/zwis/server"
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+func main() {
+	addr := flag.String("addr", ":6379", "TCP address to serve Redis clients on; empty disables the Redis listener")
+	memcachedAddr := flag.String("memcached-addr", "", "TCP address to serve memcached clients on; empty disables the memcached listener")
+	httpAddr := flag.String("http-addr", "", "TCP address to serve the HTTP API on; empty disables it")
+	cacheType := flag.String("type", string(zwis.LRUCacheType), "cache type: memory, lru, lfu, arc, disk, tinylfu, sieve, s3fifo, 2q, slru, clock or clockpro")
+	capacity := flag.Int("capacity", 100_000, "maximum number of entries; 0 means unbounded for memory and disk caches")
+	shards := flag.Int("shards", 0, "split the cache into this many shards; 0 disables sharding")
+	defaultTTL := flag.Duration("default-ttl", 0, "TTL for keys set without an expiration; 0 keeps them until evicted")
+	janitor := flag.Duration("janitor", 0, "interval between sweeps for expired keys; 0 disables the janitor")
+	dir := flag.String("dir", "", "directory for disk caches")
+	flag.Parse()
+
+	opts := []zwis.Option{zwis.WithCapacity(*capacity), zwis.WithDefaultTTL(*defaultTTL)}
+	if *janitor > 0 {
+		opts = append(opts, zwis.WithJanitorInterval(*janitor))
+	}
+	if *dir != "" {
+		opts = append(opts, zwis.WithDirectory(*dir))
+	}
+
+	var cache zwis.Cache
+	var err error
+	if *shards > 0 {
+		cache, err = zwis.NewShardedCache(zwis.CacheType(*cacheType), *shards, opts...)
+	} else {
+		cache, err = zwis.NewCache(zwis.CacheType(*cacheType), opts...)
+	}
+	if err != nil {
+		log.Fatalf("zwis-server: %v", err)
+	}
+
+	srv := server.New(cache)
+	done := make(chan error, 3)
+	listeners := 0
+	if *addr != "" {
+		listeners++
+		go func() { done <- srv.ListenAndServe(*addr) }()
+		log.Printf("zwis-server: serving a %s cache to Redis clients on %s", *cacheType, *addr)
+	}
+	if *memcachedAddr != "" {
+		listeners++
+		go func() { done <- srv.ListenAndServeMemcached(*memcachedAddr) }()
+		log.Printf("zwis-server: serving a %s cache to memcached clients on %s", *cacheType, *memcachedAddr)
+	}
+	var httpServer *http.Server
+	if *httpAddr != "" {
+		listeners++
+		httpServer = &http.Server{Addr: *httpAddr, Handler: httpapi.NewHandler(cache)}
+		go func() { done <- httpServer.ListenAndServe() }()
+		log.Printf("zwis-server: serving a %s cache over HTTP on %s", *cacheType, *httpAddr)
+	}
+	if listeners == 0 {
+		log.Fatal("zwis-server: no listen address given")
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	failed := false
+	select {
+	case s := <-sig:
+		log.Printf("zwis-server: %v, shutting down", s)
+	case err := <-done:
+		log.Printf("zwis-server: %v", err)
+		listeners--
+		failed = true
+	}
+	srv.Close()
+	if httpServer != nil {
+		httpServer.Close()
+	}
+	for ; listeners > 0; listeners-- {
+		<-done
+	}
+	if c, ok := cache.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Printf("zwis-server: closing cache: %v", err)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
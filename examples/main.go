@@ -5,13 +5,13 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/NonsoAmadi10/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis"
 )
 
 func main() {
 	ctx := context.Background()
 
-	arcCache, err := zwis.NewCache(zwis.ARCCacheType, 100)
+	arcCache, err := zwis.NewCache(zwis.ARCCacheType, zwis.WithCapacity(100))
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,284 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// StatusError is returned by Client methods when the server replies with
+// an error status.
+type StatusError struct {
+	Code    int    // HTTP status code
+	Message string // The server's error message
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpapi: %d %s: %s", e.Code, http.StatusText(e.Code), e.Message)
+}
+
+// TypedClient is a zwis cache backed by a Handler on a remote server.
+// Values are serialised with a codec, encoding/gob by default, so values
+// stored on the server by other means, such as a raw PUT from curl, fail to
+// decode and are missed by Get. Use a TypedClient[[]byte] with BytesCodec
+// to read such values.
+//
+// Get cannot report errors through the zwis.Cache interface, so it treats
+// them as misses; use Lookup to see them.
+type TypedClient[V any] struct {
+	baseURL string
+	client  *http.Client
+	codec   zwis.Codec[V]
+}
+
+// Client is a TypedClient holding interface{} values.
+type Client = TypedClient[interface{}]
+
+var (
+	_ zwis.Cache         = (*Client)(nil)
+	_ zwis.BatchCache    = (*Client)(nil)
+	_ zwis.StatsProvider = (*Client)(nil)
+)
+
+// BytesCodec is a zwis.Codec that passes []byte values through unchanged,
+// for clients sharing keys with other HTTP users of the Handler.
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(value []byte) ([]byte, error) { return value, nil }
+func (BytesCodec) Decode(data []byte) ([]byte, error)  { return data, nil }
+
+// ClientOption configures a Client.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	client *http.Client
+	codec  interface{}
+}
+
+// WithHTTPClient sets the http.Client used for requests. The default is
+// http.DefaultClient.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(o *clientOptions) {
+		o.client = client
+	}
+}
+
+// WithCodec sets the codec used to serialise values of type V.
+func WithCodec[V any](codec zwis.Codec[V]) ClientOption {
+	return func(o *clientOptions) {
+		o.codec = codec
+	}
+}
+
+// NewClient creates a Client for the Handler served at baseURL, such as
+// "http://localhost:8080" or "http://sidecar/cache" for a Handler mounted
+// under /cache.
+func NewClient(baseURL string, opts ...ClientOption) (*Client, error) {
+	return NewTypedClient[interface{}](baseURL, opts...)
+}
+
+// NewTypedClient creates a TypedClient for the Handler served at baseURL.
+func NewTypedClient[V any](baseURL string, opts ...ClientOption) (*TypedClient[V], error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("httpapi: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("httpapi: invalid base URL %q: scheme must be http or https", baseURL)
+	}
+
+	o := clientOptions{client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var codec zwis.Codec[V] = zwis.GobCodec[V]{}
+	if o.codec != nil {
+		var ok bool
+		if codec, ok = o.codec.(zwis.Codec[V]); !ok {
+			return nil, fmt.Errorf("httpapi: codec %T does not encode %v values", o.codec, reflect.TypeOf((*V)(nil)).Elem())
+		}
+	}
+	return &TypedClient[V]{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  o.client,
+		codec:   codec,
+	}, nil
+}
+
+// Lookup is Get that also reports errors other than the key being missing.
+func (c *TypedClient[V]) Lookup(ctx context.Context, key string) (V, bool, error) {
+	var zero V
+	res, err := c.do(ctx, http.MethodGet, keyPath(key), nil, nil)
+	if err != nil {
+		return zero, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return zero, false, nil
+	}
+	if err := checkStatus(res); err != nil {
+		return zero, false, err
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return zero, false, err
+	}
+	value, err := c.codec.Decode(data)
+	if err != nil {
+		return zero, false, fmt.Errorf("httpapi: decoding %q: %w", key, err)
+	}
+	return value, true, nil
+}
+
+func (c *TypedClient[V]) Get(ctx context.Context, key string) (V, bool) {
+	value, ok, _ := c.Lookup(ctx, key)
+	return value, ok
+}
+
+func (c *TypedClient[V]) Set(ctx context.Context, key string, value V, ttl time.Duration) error {
+	data, err := c.codec.Encode(value)
+	if err != nil {
+		return fmt.Errorf("httpapi: encoding %q: %w", key, err)
+	}
+	header := make(http.Header)
+	if ttl != 0 {
+		header.Set(TTLHeader, ttl.String())
+	}
+	return c.call(ctx, http.MethodPut, keyPath(key), header, data, nil)
+}
+
+func (c *TypedClient[V]) Delete(ctx context.Context, key string) error {
+	return c.call(ctx, http.MethodDelete, keyPath(key), nil, nil, nil)
+}
+
+func (c *TypedClient[V]) Flush(ctx context.Context) error {
+	return c.call(ctx, http.MethodDelete, "/keys", nil, nil, nil)
+}
+
+// GetMany fetches keys in one request. Keys whose values cannot be decoded
+// are left out, as are all keys if the request fails.
+func (c *TypedClient[V]) GetMany(ctx context.Context, keys []string) map[string]V {
+	found := make(map[string]V, len(keys))
+	if len(keys) == 0 {
+		return found
+	}
+	var res bulkResponse
+	if err := c.callJSON(ctx, "/bulk/get", bulkRequest{Keys: keys}, &res); err != nil {
+		return found
+	}
+	for key, data := range res.Values {
+		if value, err := c.codec.Decode(data); err == nil {
+			found[key] = value
+		}
+	}
+	return found
+}
+
+func (c *TypedClient[V]) SetMany(ctx context.Context, items map[string]zwis.TypedItem[V]) error {
+	req := bulkRequest{Items: make(map[string]bulkItem, len(items))}
+	for key, item := range items {
+		data, err := c.codec.Encode(item.Value)
+		if err != nil {
+			return fmt.Errorf("httpapi: encoding %q: %w", key, err)
+		}
+		bi := bulkItem{Value: data}
+		if item.TTL != 0 {
+			bi.TTL = item.TTL.String()
+		}
+		req.Items[key] = bi
+	}
+	return c.callJSON(ctx, "/bulk/set", req, nil)
+}
+
+func (c *TypedClient[V]) DeleteMany(ctx context.Context, keys []string) error {
+	return c.callJSON(ctx, "/bulk/delete", bulkRequest{Keys: keys}, nil)
+}
+
+// Stats returns the remote cache's statistics, or zero Stats if they cannot
+// be fetched.
+func (c *TypedClient[V]) Stats() zwis.Stats {
+	var stats statsJSON
+	if err := c.call(context.Background(), http.MethodGet, "/stats", nil, nil, &stats); err != nil {
+		return zwis.Stats{}
+	}
+	return zwis.Stats(stats)
+}
+
+// ResetStats resets the remote cache's statistics.
+func (c *TypedClient[V]) ResetStats() {
+	c.call(context.Background(), http.MethodDelete, "/stats", nil, nil, nil)
+}
+
+// keyPath returns the path of key's endpoint.
+func keyPath(key string) string {
+	return "/keys/" + url.PathEscape(key)
+}
+
+// callJSON posts body as JSON to path and decodes the reply into out, if
+// out is not nil.
+func (c *TypedClient[V]) callJSON(ctx context.Context, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Content-Type": {"application/json"}}
+	return c.call(ctx, http.MethodPost, path, header, data, out)
+}
+
+// call sends a request and checks its status, decoding a JSON reply into
+// out if out is not nil.
+func (c *TypedClient[V]) call(ctx context.Context, method, path string, header http.Header, body []byte, out interface{}) error {
+	res, err := c.do(ctx, method, path, header, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := checkStatus(res); err != nil {
+		return err
+	}
+	if out == nil {
+		io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("httpapi: decoding reply: %w", err)
+	}
+	return nil
+}
+
+func (c *TypedClient[V]) do(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return c.client.Do(req)
+}
+
+// checkStatus returns a StatusError for replies other than 2xx.
+func checkStatus(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	var e errorJSON
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if json.Unmarshal(data, &e) != nil || e.Error == "" {
+		e.Error = strings.TrimSpace(string(data))
+	}
+	return &StatusError{Code: res.StatusCode, Message: e.Error}
+}
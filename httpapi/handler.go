@@ -0,0 +1,352 @@
+// Package httpapi serves a zwis cache over HTTP and provides a client that
+// implements zwis.Cache against such a server, so a cache can run as a
+// sidecar and application code can switch between a local cache and the
+// remote one without changes.
+package httpapi
+
+/*
+Endpoints:
+
+	GET    /keys/{key}   the value, as raw bytes; 404 if missing
+	PUT    /keys/{key}   store the request body; TTL from the X-Zwis-TTL header or ?ttl=
+	DELETE /keys/{key}   delete the key
+	DELETE /keys         flush the cache
+	GET    /stats        the cache's statistics as JSON
+	DELETE /stats        reset the statistics
+	POST   /bulk/get     {"keys": [...]} -> {"values": {key: base64, ...}}
+	POST   /bulk/set     {"items": {key: {"value": base64, "ttl": "30s"}, ...}}
+	POST   /bulk/delete  {"keys": [...]}
+
+Keys are path-escaped, so they may contain slashes, and /keys/ addresses the empty key; only /keys without the trailing slash flushes. TTLs are Go durations such as "1m30s" or a number of seconds; 0 or no TTL uses the cache's default TTL, and a negative TTL stores the value without expiration. Errors are JSON objects with an "error" field.
+
+Values are stored in the cache as []byte. Values stored as strings by other code are served as is, values implementing encoding.TextMarshaler, such as the server package's records, as their text, and any other value as JSON.
+*/
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// TTLHeader is the request header that carries the TTL of a PUT.
+const TTLHeader = "X-Zwis-TTL"
+
+// Handler serves a zwis cache over HTTP.
+type Handler struct {
+	cache        zwis.Cache
+	batch        zwis.BatchCache
+	maxValueSize int64
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithMaxValueSize limits the size of values stored with PUT, and of bulk
+// request bodies, to n bytes. The default is 32 MB.
+func WithMaxValueSize(n int64) Option {
+	return func(h *Handler) {
+		h.maxValueSize = n
+	}
+}
+
+// NewHandler creates a Handler for cache. Mount it under a path prefix with
+// http.StripPrefix.
+func NewHandler(cache zwis.Cache, opts ...Option) *Handler {
+	h := &Handler{
+		cache:        cache,
+		batch:        zwis.NewBatchCache(cache),
+		maxValueSize: 32 << 20,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	switch {
+	case path == "/keys":
+		if !allow(w, r, http.MethodDelete) {
+			return
+		}
+		h.flush(w, r)
+
+	case strings.HasPrefix(path, "/keys/"):
+		key, err := url.PathUnescape(path[len("/keys/"):])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid key: %v", err)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			h.get(w, r, key)
+		case http.MethodPut:
+			h.put(w, r, key)
+		case http.MethodDelete:
+			h.delete(w, r, key)
+		default:
+			allow(w, r, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete)
+		}
+
+	case path == "/stats":
+		if !allow(w, r, http.MethodGet, http.MethodDelete) {
+			return
+		}
+		h.stats(w, r)
+
+	case path == "/bulk/get" || path == "/bulk/set" || path == "/bulk/delete":
+		if !allow(w, r, http.MethodPost) {
+			return
+		}
+		h.bulk(w, r, path[len("/bulk/"):])
+
+	default:
+		writeError(w, http.StatusNotFound, "no such endpoint: %s", r.URL.Path)
+	}
+}
+
+// allow reports whether r uses one of methods, replying 405 if it does not.
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	return false
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, key string) {
+	value, ok := h.cache.Get(r.Context(), key)
+	if !ok {
+		writeError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	data, contentType, err := encodeValue(value)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "encoding value: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
+
+// encodeValue returns the response body for a cached value.
+func encodeValue(value interface{}) ([]byte, string, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, "application/octet-stream", nil
+	case string:
+		return []byte(v), "text/plain; charset=utf-8", nil
+	case encoding.TextMarshaler:
+		data, err := v.MarshalText()
+		return data, "text/plain; charset=utf-8", err
+	default:
+		data, err := json.Marshal(v)
+		return data, "application/json", err
+	}
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, key string) {
+	ttl, err := requestTTL(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	data, ok := h.readBody(w, r)
+	if !ok {
+		return
+	}
+	if err := h.cache.Set(r.Context(), key, data, ttl); err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestTTL returns the TTL given in the X-Zwis-TTL header or, failing
+// that, the ttl query parameter.
+func requestTTL(r *http.Request) (time.Duration, error) {
+	s := r.Header.Get(TTLHeader)
+	if s == "" {
+		s = r.URL.Query().Get("ttl")
+	}
+	if s == "" {
+		return 0, nil
+	}
+	return parseTTL(s)
+}
+
+// parseTTL parses a TTL given as a Go duration such as "1m30s" or as a
+// number of seconds.
+func parseTTL(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if seconds > int64(1<<63-1)/int64(time.Second) || seconds < -int64(1<<63-1)/int64(time.Second) {
+			return 0, fmt.Errorf("invalid ttl %q: out of range", s)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl %q", s)
+	}
+	return ttl, nil
+}
+
+// readBody reads the request body, replying 413 if it is larger than the
+// configured limit.
+func (h *Handler) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxValueSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "body larger than %d bytes", h.maxValueSize)
+		} else {
+			writeError(w, http.StatusBadRequest, "reading body: %v", err)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, key string) {
+	if err := h.cache.Delete(r.Context(), key); err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) flush(w http.ResponseWriter, r *http.Request) {
+	if err := h.cache.Flush(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// statsJSON is the JSON form of zwis.Stats.
+type statsJSON struct {
+	Hits              uint64  `json:"hits"`
+	Misses            uint64  `json:"misses"`
+	Sets              uint64  `json:"sets"`
+	Deletes           uint64  `json:"deletes"`
+	CapacityEvictions uint64  `json:"capacity_evictions"`
+	ExpiredEvictions  uint64  `json:"expired_evictions"`
+	ExplicitEvictions uint64  `json:"explicit_evictions"`
+	Entries           int64   `json:"entries"`
+	HitRatio          float64 `json:"hit_ratio"`
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	sp, ok := h.cache.(zwis.StatsProvider)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "cache does not keep statistics")
+		return
+	}
+	if r.Method == http.MethodDelete {
+		sp.ResetStats()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, statsJSON(sp.Stats()))
+}
+
+// bulkRequest is the body of the bulk endpoints: keys for get and delete,
+// items for set.
+type bulkRequest struct {
+	Keys  []string            `json:"keys,omitempty"`
+	Items map[string]bulkItem `json:"items,omitempty"`
+}
+
+type bulkItem struct {
+	Value []byte `json:"value"`
+	TTL   string `json:"ttl,omitempty"`
+}
+
+// bulkResponse is the body of a bulk get reply.
+type bulkResponse struct {
+	Values map[string][]byte `json:"values"`
+}
+
+func (h *Handler) bulk(w http.ResponseWriter, r *http.Request, op string) {
+	data, ok := h.readBody(w, r)
+	if !ok {
+		return
+	}
+	var req bulkRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: %v", err)
+		return
+	}
+
+	ctx := r.Context()
+	switch op {
+	case "get":
+		values := make(map[string][]byte)
+		for key, value := range h.batch.GetMany(ctx, req.Keys) {
+			data, _, err := encodeValue(value)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "encoding value of %q: %v", key, err)
+				return
+			}
+			values[key] = data
+		}
+		writeJSON(w, http.StatusOK, bulkResponse{Values: values})
+		return
+
+	case "set":
+		items := make(map[string]zwis.Item, len(req.Items))
+		for key, item := range req.Items {
+			var ttl time.Duration
+			if item.TTL != "" {
+				var err error
+				if ttl, err = parseTTL(item.TTL); err != nil {
+					writeError(w, http.StatusBadRequest, "%q: %v", key, err)
+					return
+				}
+			}
+			items[key] = zwis.Item{Value: item.Value, TTL: ttl}
+		}
+		if err := h.batch.SetMany(ctx, items); err != nil {
+			writeError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+
+	case "delete":
+		if err := h.batch.DeleteMany(ctx, req.Keys); err != nil {
+			writeError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// errorJSON is the body of every error reply.
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	writeJSON(w, code, errorJSON{Error: fmt.Sprintf(format, args...)})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,103 @@
+package resp
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Conn is a client connection to a RESP server. It is not safe for
+// concurrent use.
+type Conn struct {
+	conn   net.Conn
+	r      *Reader
+	w      *Writer
+	broken bool
+}
+
+// Dial connects to the RESP server at address.
+func Dial(ctx context.Context, network, address string) (*Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
+
+// NewConn wraps an established connection.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{conn: conn, r: NewReader(conn), w: NewWriter(conn)}
+}
+
+// Do sends a command and returns its reply. Error replies are returned as a
+// ServerError. ctx bounds the whole round trip; if it ends first, or any I/O
+// fails, the connection is left in an unknown state and Broken reports true.
+func (c *Conn) Do(ctx context.Context, args ...string) (Value, error) {
+	replies, err := c.Pipeline(ctx, args)
+	if err != nil {
+		return Value{}, err
+	}
+	v := replies[0]
+	if v.Type == Error || v.Type == BulkError {
+		return v, ServerError(v.Str)
+	}
+	return v, nil
+}
+
+// Pipeline sends several commands in one write and returns their replies in
+// order. Error replies are returned as values rather than as an error. ctx
+// bounds the whole exchange as it does for Do.
+func (c *Conn) Pipeline(ctx context.Context, cmds ...[]string) ([]Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Deadlines are enforced through ctx alone, so that an I/O timeout is
+	// always reported as ctx's error. The AfterFunc is registered after the
+	// reset so that it cannot be overwritten.
+	c.conn.SetDeadline(time.Time{})
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock any pending read or write.
+		c.conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	replies, err := c.roundTrip(cmds)
+	if err != nil {
+		c.broken = true
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	return replies, nil
+}
+
+func (c *Conn) roundTrip(cmds [][]string) ([]Value, error) {
+	for _, args := range cmds {
+		c.w.WriteCommand(args...)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]Value, len(cmds))
+	for i := range replies {
+		v, err := c.r.ReadValue()
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = v
+	}
+	return replies, nil
+}
+
+// Broken reports whether a previous Do failed in a way that leaves the
+// connection unusable.
+func (c *Conn) Broken() bool {
+	return c.broken
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
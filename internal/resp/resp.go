@@ -0,0 +1,339 @@
+// Package resp reads and writes the Redis serialization protocol, RESP2 and
+// RESP3, and provides a minimal client connection on top of it.
+package resp
+
+/*
+Every RESP value starts with a one-byte type marker and ends with CRLF. Bulk strings carry a length prefix, aggregates an element count. RESP3 adds nulls, booleans, doubles, maps, sets and a few other types; a RESP2 peer sees nulls as "$-1" and maps as flat arrays.
+*/
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Type is the marker byte that starts a RESP value.
+type Type byte
+
+const (
+	SimpleString   Type = '+'
+	Error          Type = '-'
+	Integer        Type = ':'
+	BulkString     Type = '$'
+	Array          Type = '*'
+	Null           Type = '_'
+	Boolean        Type = '#'
+	Double         Type = ','
+	BigNumber      Type = '('
+	BulkError      Type = '!'
+	VerbatimString Type = '='
+	Map            Type = '%'
+	Set            Type = '~'
+	Attribute      Type = '|'
+	Push           Type = '>'
+)
+
+// Limits on what a Reader accepts, matching Redis' defaults.
+const (
+	MaxBulkLen   = 512 << 20
+	MaxArrayLen  = 1 << 20
+	maxInlineLen = 64 << 10
+)
+
+// ErrProtocol is wrapped by every error caused by malformed input.
+var ErrProtocol = errors.New("resp: protocol error")
+
+// Value is a decoded RESP value. RESP2 null bulk strings and arrays decode
+// as Null.
+type Value struct {
+	Type  Type
+	Str   string  // SimpleString, Error, BulkString, Double, BigNumber, BulkError and VerbatimString
+	Int   int64   // Integer, and Boolean as 0 or 1
+	Elems []Value // Array, Set and Push; Map and Attribute as alternating keys and values
+}
+
+// IsNull reports whether v is a null of either protocol version.
+func (v Value) IsNull() bool {
+	return v.Type == Null
+}
+
+// ServerError is an error reply sent by the server.
+type ServerError string
+
+func (e ServerError) Error() string { return string(e) }
+
+// Reader decodes RESP values from a stream.
+type Reader struct {
+	br *bufio.Reader
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{br: bufio.NewReader(r)}
+}
+
+// Buffered returns the number of bytes that can be read without blocking.
+// Servers use it to flush replies only once a pipeline of commands has been
+// processed.
+func (r *Reader) Buffered() int {
+	return r.br.Buffered()
+}
+
+// ReadValue reads the next value.
+func (r *Reader) ReadValue() (Value, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+	if len(line) == 0 {
+		return Value{}, protocolError("empty line")
+	}
+
+	t, rest := Type(line[0]), string(line[1:])
+	switch t {
+	case SimpleString, Error, Double, BigNumber:
+		return Value{Type: t, Str: rest}, nil
+	case Integer:
+		n, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return Value{}, protocolError("invalid integer %q", rest)
+		}
+		return Value{Type: t, Int: n}, nil
+	case Null:
+		return Value{Type: Null}, nil
+	case Boolean:
+		switch rest {
+		case "t":
+			return Value{Type: t, Int: 1}, nil
+		case "f":
+			return Value{Type: t}, nil
+		}
+		return Value{}, protocolError("invalid boolean %q", rest)
+	case BulkString, BulkError, VerbatimString:
+		n, err := parseLen(rest, MaxBulkLen)
+		if err != nil {
+			return Value{}, err
+		}
+		if n < 0 {
+			return Value{Type: Null}, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r.br, data); err != nil {
+			return Value{}, err
+		}
+		if data[n] != '\r' || data[n+1] != '\n' {
+			return Value{}, protocolError("bulk string not terminated by CRLF")
+		}
+		return Value{Type: t, Str: string(data[:n])}, nil
+	case Array, Set, Push, Map, Attribute:
+		n, err := parseLen(rest, MaxArrayLen)
+		if err != nil {
+			return Value{}, err
+		}
+		if n < 0 {
+			return Value{Type: Null}, nil
+		}
+		if t == Map || t == Attribute {
+			n *= 2
+		}
+		elems := make([]Value, n)
+		for i := range elems {
+			if elems[i], err = r.ReadValue(); err != nil {
+				return Value{}, err
+			}
+		}
+		return Value{Type: t, Elems: elems}, nil
+	default:
+		return Value{}, protocolError("unknown type %q", line[0])
+	}
+}
+
+// ReadCommand reads a command sent by a client: an array of bulk strings,
+// or an inline command of space-separated words as typed into telnet. It
+// returns an empty slice for blank inline lines.
+func (r *Reader) ReadCommand() ([]string, error) {
+	b, err := r.br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if Type(b[0]) != Array {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		return splitInline(string(line))
+	}
+
+	v, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, len(v.Elems))
+	for i, elem := range v.Elems {
+		if elem.Type != BulkString {
+			return nil, protocolError("expected bulk string arguments, got %q", byte(elem.Type))
+		}
+		args[i] = elem.Str
+	}
+	return args, nil
+}
+
+// readLine reads a CRLF-terminated line without its terminator.
+func (r *Reader) readLine() ([]byte, error) {
+	line, err := r.br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// Longer than the buffer: only inline commands get that long.
+		long := append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull && len(long) <= maxInlineLen {
+			line, err = r.br.ReadSlice('\n')
+			long = append(long, line...)
+		}
+		line = long
+	}
+	if err != nil {
+		if err == bufio.ErrBufferFull {
+			return nil, protocolError("line too long")
+		}
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, protocolError("line not terminated by CRLF")
+	}
+	return line[:len(line)-2], nil
+}
+
+// splitInline splits an inline command into words, honouring double and
+// single quotes as redis-cli does.
+func splitInline(line string) ([]string, error) {
+	var args []string
+	for i := 0; i < len(line); {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i == len(line) {
+			break
+		}
+		if q := line[i]; q == '"' || q == '\'' {
+			end := i + 1
+			for end < len(line) && line[end] != q {
+				if line[end] == '\\' && q == '"' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, protocolError("unbalanced quotes in inline command")
+			}
+			word := line[i : end+1]
+			if q == '"' {
+				unquoted, err := strconv.Unquote(word)
+				if err != nil {
+					return nil, protocolError("invalid quoted string %s", word)
+				}
+				args = append(args, unquoted)
+			} else {
+				args = append(args, word[1:len(word)-1])
+			}
+			i = end + 1
+			continue
+		}
+		start := i
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		args = append(args, line[start:i])
+	}
+	return args, nil
+}
+
+func parseLen(s string, limit int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < -1 {
+		return 0, protocolError("invalid length %q", s)
+	}
+	if n > limit {
+		return 0, protocolError("length %d exceeds the limit of %d", n, limit)
+	}
+	return n, nil
+}
+
+func protocolError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrProtocol, fmt.Sprintf(format, args...))
+}
+
+// Writer encodes RESP values. Version selects how RESP3-only types are
+// written: as themselves for 3, or as their closest RESP2 form for 2.
+type Writer struct {
+	bw      *bufio.Writer
+	Version int
+}
+
+// NewWriter returns a RESP2 Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{bw: bufio.NewWriter(w), Version: 2}
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.bw.Flush()
+}
+
+func (w *Writer) WriteSimpleString(s string) {
+	w.line(SimpleString, s)
+}
+
+// WriteError writes an error reply. msg should start with an error code
+// such as "ERR" or "WRONGTYPE".
+func (w *Writer) WriteError(msg string) {
+	w.line(Error, msg)
+}
+
+func (w *Writer) WriteInteger(n int64) {
+	w.line(Integer, strconv.FormatInt(n, 10))
+}
+
+func (w *Writer) WriteBulkString(s string) {
+	w.line(BulkString, strconv.Itoa(len(s)))
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+// WriteNull writes a null, as "$-1" to RESP2 peers.
+func (w *Writer) WriteNull() {
+	if w.Version >= 3 {
+		w.line(Null, "")
+		return
+	}
+	w.line(BulkString, "-1")
+}
+
+// WriteArrayHeader starts an array of n elements, which must follow.
+func (w *Writer) WriteArrayHeader(n int) {
+	w.line(Array, strconv.Itoa(n))
+}
+
+// WriteMapHeader starts a map of n key/value pairs, which must follow. RESP2
+// peers receive a flat array of 2n elements.
+func (w *Writer) WriteMapHeader(n int) {
+	if w.Version >= 3 {
+		w.line(Map, strconv.Itoa(n))
+		return
+	}
+	w.WriteArrayHeader(2 * n)
+}
+
+// WriteCommand writes a command as an array of bulk strings.
+func (w *Writer) WriteCommand(args ...string) {
+	w.WriteArrayHeader(len(args))
+	for _, arg := range args {
+		w.WriteBulkString(arg)
+	}
+}
+
+func (w *Writer) line(t Type, s string) {
+	w.bw.WriteByte(byte(t))
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
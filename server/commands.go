@@ -0,0 +1,635 @@
+package server
+
+import (
+	"encoding"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// redisVersion is reported to clients, which use it to pick features.
+const redisVersion = "7.2.0"
+
+// command is a Redis command handler. arity follows Redis: a positive
+// arity is the exact number of arguments including the command name, a
+// negative one the minimum.
+type command struct {
+	arity int
+	run   func(s *Server, c *client, args []string)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":     {-1, (*Server).ping},
+		"echo":     {2, (*Server).echo},
+		"hello":    {-1, (*Server).hello},
+		"quit":     {1, (*Server).quit},
+		"select":   {2, (*Server).selectDB},
+		"client":   {-2, (*Server).clientCmd},
+		"command":  {-1, (*Server).commandCmd},
+		"get":      {2, (*Server).get},
+		"set":      {-3, (*Server).set},
+		"del":      {-2, (*Server).del},
+		"exists":   {-2, (*Server).exists},
+		"ttl":      {2, (*Server).ttl},
+		"pttl":     {2, (*Server).pttl},
+		"expire":   {-3, (*Server).expire},
+		"flushall": {-1, (*Server).flush},
+		"flushdb":  {-1, (*Server).flush},
+		"mget":     {-2, (*Server).mget},
+		"mset":     {-3, (*Server).mset},
+		"dbsize":   {1, (*Server).dbsize},
+		"info":     {-1, (*Server).info},
+	}
+}
+
+// execute runs one command and writes its reply.
+func (s *Server) execute(c *client, args []string) {
+	name := strings.ToLower(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		var quoted []string
+		for _, arg := range args[1:] {
+			quoted = append(quoted, "'"+arg+"'")
+		}
+		c.w.WriteError(fmt.Sprintf("ERR unknown command '%s', with args beginning with: %s", args[0], strings.Join(quoted, " ")))
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.w.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+	cmd.run(s, c, args)
+}
+
+// Record is the value the server stores in the cache for each key set by a
+// Redis or memcached client. It remembers the expiration time, since zwis
+// caches do not report the TTL of an entry, along with memcached's flags
+// and CAS value. Go code sharing the cache can type-assert values to Record
+// and read Value, or call ValueOf.
+//
+// Record implements encoding.TextMarshaler as its Value, so encoders such
+// as encoding/json and the httpapi package serve the value itself.
+type Record struct {
+	Value    string
+	ExpireAt time.Time // Zero if the key does not expire
+	Flags    uint32    // Opaque memcached client flags
+	CAS      uint64    // Changes on every write, for memcached's cas
+}
+
+func init() {
+	gob.Register(Record{})
+}
+
+// MarshalText returns r.Value.
+func (r Record) MarshalText() ([]byte, error) {
+	return []byte(r.Value), nil
+}
+
+// ValueOf returns the value the server serves for v, a value stored in the
+// cache: a Record's Value, a string or []byte as is, the text of an
+// encoding.TextMarshaler, and anything else formatted with fmt.Sprint.
+func ValueOf(v interface{}) string {
+	return toRecord(v).Value
+}
+
+// lookup returns the Record stored under key. Values stored by other users
+// of the cache are converted to records without an expiration.
+func (s *Server) lookup(key string) (Record, bool) {
+	v, ok := s.cache.Get(s.ctx, key)
+	if !ok {
+		return Record{}, false
+	}
+	rec := toRecord(v)
+	if !s.valid(rec) {
+		return Record{}, false
+	}
+	return rec, true
+}
+
+// valid reports whether rec has not expired by the server's clock.
+func (s *Server) valid(rec Record) bool {
+	return rec.ExpireAt.IsZero() || rec.ExpireAt.After(s.now())
+}
+
+func toRecord(v interface{}) Record {
+	switch v := v.(type) {
+	case Record:
+		return v
+	case string:
+		return Record{Value: v}
+	case []byte:
+		return Record{Value: string(v)}
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		if err != nil {
+			return Record{Value: fmt.Sprint(v)}
+		}
+		return Record{Value: string(text)}
+	default:
+		return Record{Value: fmt.Sprint(v)}
+	}
+}
+
+// store saves rec under key with a new CAS value, passing its remaining
+// lifetime to the cache as the TTL. It returns the CAS value.
+func (s *Server) store(key string, rec Record) (uint64, error) {
+	rec.CAS = s.nextCAS.Add(1)
+	return rec.CAS, s.cache.Set(s.ctx, key, rec, s.ttlOf(rec))
+}
+
+// flushNow removes every key and cancels any delayed flush_all.
+func (s *Server) flushNow() error {
+	s.flushMu.Lock()
+	s.cancelFlushLocked()
+	s.flushMu.Unlock()
+	return s.cache.Flush(s.ctx)
+}
+
+// flushAt flushes the cache once the server's clock reaches at, replacing
+// any delayed flush already pending. Flushing the cache itself, rather than
+// hiding older keys from the server, keeps other users of the cache in step.
+func (s *Server) flushAt(at time.Time) error {
+	d := at.Sub(s.now())
+	if d <= 0 {
+		return s.flushNow()
+	}
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.cancelFlushLocked()
+	gen := s.flushGen
+	s.stopFlush = s.clock.Every(d, func() {
+		s.flushMu.Lock()
+		if s.flushGen != gen {
+			s.flushMu.Unlock()
+			return
+		}
+		s.cancelFlushLocked()
+		s.flushMu.Unlock()
+		s.cache.Flush(s.ctx)
+	})
+	return nil
+}
+
+// cancelFlushLocked cancels the pending delayed flush_all, if any.
+// s.flushMu must be held.
+func (s *Server) cancelFlushLocked() {
+	s.flushGen++
+	if stop := s.stopFlush; stop != nil {
+		s.stopFlush = nil
+		// A clock's stop function may wait for a running callback, which
+		// may be waiting for s.flushMu, so it cannot be called here.
+		go stop()
+	}
+}
+
+// ttlOf returns the TTL to give the cache for rec. Records without an
+// expiration get a negative TTL, so the cache's default TTL does not apply
+// to them.
+func (s *Server) ttlOf(rec Record) time.Duration {
+	if rec.ExpireAt.IsZero() {
+		return -1
+	}
+	return max(rec.ExpireAt.Sub(s.now()), time.Nanosecond)
+}
+
+func (s *Server) ping(c *client, args []string) {
+	switch len(args) {
+	case 1:
+		c.w.WriteSimpleString("PONG")
+	case 2:
+		c.w.WriteBulkString(args[1])
+	default:
+		c.w.WriteError("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func (s *Server) echo(c *client, args []string) {
+	c.w.WriteBulkString(args[1])
+}
+
+// hello negotiates the protocol version. AUTH is rejected because the
+// server has no users.
+func (s *Server) hello(c *client, args []string) {
+	version := c.w.Version
+	if len(args) > 1 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil {
+			c.w.WriteError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if v != 2 && v != 3 {
+			c.w.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+		version = v
+	}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "auth":
+			c.w.WriteError("ERR AUTH is not supported by this server")
+			return
+		case "setname":
+			if i+1 >= len(args) {
+				c.w.WriteError("ERR syntax error")
+				return
+			}
+			c.name = args[i+1]
+			i++
+		default:
+			c.w.WriteError("ERR syntax error")
+			return
+		}
+	}
+
+	c.w.Version = version
+	c.w.WriteMapHeader(7)
+	c.w.WriteBulkString("server")
+	c.w.WriteBulkString("redis")
+	c.w.WriteBulkString("version")
+	c.w.WriteBulkString(redisVersion)
+	c.w.WriteBulkString("proto")
+	c.w.WriteInteger(int64(version))
+	c.w.WriteBulkString("id")
+	c.w.WriteInteger(c.id)
+	c.w.WriteBulkString("mode")
+	c.w.WriteBulkString("standalone")
+	c.w.WriteBulkString("role")
+	c.w.WriteBulkString("master")
+	c.w.WriteBulkString("modules")
+	c.w.WriteArrayHeader(0)
+}
+
+func (s *Server) quit(c *client, args []string) {
+	c.w.WriteSimpleString("OK")
+	c.quit = true
+}
+
+// selectDB accepts database 0, the only one there is.
+func (s *Server) selectDB(c *client, args []string) {
+	if args[1] != "0" {
+		c.w.WriteError("ERR DB index is out of range")
+		return
+	}
+	c.w.WriteSimpleString("OK")
+}
+
+// clientCmd supports the CLIENT subcommands that client libraries send when
+// connecting.
+func (s *Server) clientCmd(c *client, args []string) {
+	switch sub := strings.ToLower(args[1]); {
+	case sub == "id" && len(args) == 2:
+		c.w.WriteInteger(c.id)
+	case sub == "getname" && len(args) == 2:
+		if c.name == "" {
+			c.w.WriteNull()
+		} else {
+			c.w.WriteBulkString(c.name)
+		}
+	case sub == "setname" && len(args) == 3:
+		c.name = args[2]
+		c.w.WriteSimpleString("OK")
+	case sub == "setinfo" && len(args) == 4:
+		c.w.WriteSimpleString("OK")
+	default:
+		c.w.WriteError(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'", args[1]))
+	}
+}
+
+// commandCmd answers COMMAND and COMMAND DOCS, which redis-cli sends on
+// start-up, with empty replies.
+func (s *Server) commandCmd(c *client, args []string) {
+	if len(args) > 1 && strings.EqualFold(args[1], "count") {
+		c.w.WriteInteger(int64(len(commands)))
+		return
+	}
+	if len(args) > 1 && strings.EqualFold(args[1], "docs") {
+		c.w.WriteMapHeader(0)
+		return
+	}
+	c.w.WriteArrayHeader(0)
+}
+
+func (s *Server) get(c *client, args []string) {
+	if rec, ok := s.lookup(args[1]); ok {
+		c.w.WriteBulkString(rec.Value)
+	} else {
+		c.w.WriteNull()
+	}
+}
+
+// set implements SET key value [NX | XX] [GET] [EX s | PX ms | EXAT t |
+// PXAT t | KEEPTTL].
+func (s *Server) set(c *client, args []string) {
+	key := args[1]
+	rec := Record{Value: args[2]}
+	var nx, xx, get, keepTTL, expires bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToLower(args[i]); opt {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "get":
+			get = true
+		case "keepttl":
+			keepTTL = true
+		case "ex", "px", "exat", "pxat":
+			if expires || i+1 >= len(args) {
+				c.w.WriteError("ERR syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				c.w.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			at, ok := s.expireTime(opt, n)
+			if !ok {
+				c.w.WriteError("ERR invalid expire time in 'set' command")
+				return
+			}
+			rec.ExpireAt = at
+			expires = true
+			i++
+		default:
+			c.w.WriteError("ERR syntax error")
+			return
+		}
+	}
+	if (nx && xx) || (keepTTL && expires) {
+		c.w.WriteError("ERR syntax error")
+		return
+	}
+
+	defer s.lockKey(key).Unlock()
+	var old Record
+	var exists bool
+	if nx || xx || get || keepTTL {
+		old, exists = s.lookup(key)
+	}
+	if (nx && exists) || (xx && !exists) {
+		if get {
+			s.writeOptional(c, old, exists)
+		} else {
+			c.w.WriteNull()
+		}
+		return
+	}
+	if keepTTL && exists {
+		rec.ExpireAt = old.ExpireAt
+	}
+	if _, err := s.store(key, rec); err != nil {
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	if get {
+		s.writeOptional(c, old, exists)
+	} else {
+		c.w.WriteSimpleString("OK")
+	}
+}
+
+// expireTime converts the argument of an EX, PX, EXAT or PXAT option to an
+// absolute time. It reports false for non-positive or overflowing times.
+func (s *Server) expireTime(unit string, n int64) (time.Time, bool) {
+	if n <= 0 {
+		return time.Time{}, false
+	}
+	switch unit {
+	case "ex":
+		if n > math.MaxInt64/int64(time.Second) {
+			return time.Time{}, false
+		}
+		return s.now().Add(time.Duration(n) * time.Second), true
+	case "px":
+		if n > math.MaxInt64/int64(time.Millisecond) {
+			return time.Time{}, false
+		}
+		return s.now().Add(time.Duration(n) * time.Millisecond), true
+	case "exat":
+		return time.Unix(n, 0), true
+	default:
+		return time.UnixMilli(n), true
+	}
+}
+
+func (s *Server) writeOptional(c *client, rec Record, ok bool) {
+	if ok {
+		c.w.WriteBulkString(rec.Value)
+	} else {
+		c.w.WriteNull()
+	}
+}
+
+func (s *Server) del(c *client, args []string) {
+	keys := args[1:]
+	found := s.batch.GetMany(s.ctx, keys)
+	var n int64
+	for _, v := range found {
+		if s.live(v) {
+			n++
+		}
+	}
+	if err := s.batch.DeleteMany(s.ctx, keys); err != nil {
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	c.w.WriteInteger(n)
+}
+
+// exists counts the keys that exist, counting repeated keys each time as
+// Redis does.
+func (s *Server) exists(c *client, args []string) {
+	found := s.batch.GetMany(s.ctx, args[1:])
+	var n int64
+	for _, key := range args[1:] {
+		if v, ok := found[key]; ok && s.live(v) {
+			n++
+		}
+	}
+	c.w.WriteInteger(n)
+}
+
+// live reports whether a value returned by the cache is valid.
+func (s *Server) live(v interface{}) bool {
+	return s.valid(toRecord(v))
+}
+
+func (s *Server) ttl(c *client, args []string) {
+	s.writeTTL(c, args[1], time.Second)
+}
+
+func (s *Server) pttl(c *client, args []string) {
+	s.writeTTL(c, args[1], time.Millisecond)
+}
+
+// writeTTL replies with the remaining TTL of key in units, rounded to the
+// nearest unit, -1 if it does not expire or -2 if it does not exist.
+func (s *Server) writeTTL(c *client, key string, unit time.Duration) {
+	rec, ok := s.lookup(key)
+	switch {
+	case !ok:
+		c.w.WriteInteger(-2)
+	case rec.ExpireAt.IsZero():
+		c.w.WriteInteger(-1)
+	default:
+		remaining := rec.ExpireAt.Sub(s.now())
+		c.w.WriteInteger(int64((remaining + unit/2) / unit))
+	}
+}
+
+// expire implements EXPIRE key seconds [NX | XX | GT | LT].
+func (s *Server) expire(c *client, args []string) {
+	seconds, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		c.w.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	if seconds > math.MaxInt64/int64(time.Second) || seconds < math.MinInt64/int64(time.Second) {
+		c.w.WriteError("ERR invalid expire time in 'expire' command")
+		return
+	}
+	var cond string
+	if len(args) > 3 {
+		cond = strings.ToLower(args[3])
+		if len(args) > 4 || (cond != "nx" && cond != "xx" && cond != "gt" && cond != "lt") {
+			c.w.WriteError("ERR Unsupported option " + args[3])
+			return
+		}
+	}
+
+	defer s.lockKey(args[1]).Unlock()
+	rec, ok := s.lookup(args[1])
+	if !ok {
+		c.w.WriteInteger(0)
+		return
+	}
+	at := s.now().Add(time.Duration(seconds) * time.Second)
+	persistent := rec.ExpireAt.IsZero()
+	switch {
+	case cond == "nx" && !persistent,
+		cond == "xx" && persistent,
+		cond == "gt" && (persistent || !at.After(rec.ExpireAt)),
+		cond == "lt" && !persistent && !at.Before(rec.ExpireAt):
+		c.w.WriteInteger(0)
+		return
+	}
+
+	if seconds <= 0 {
+		err = s.cache.Delete(s.ctx, args[1])
+	} else {
+		rec.ExpireAt = at
+		_, err = s.store(args[1], rec)
+	}
+	if err != nil {
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	c.w.WriteInteger(1)
+}
+
+func (s *Server) flush(c *client, args []string) {
+	if len(args) > 2 || (len(args) == 2 && !strings.EqualFold(args[1], "sync") && !strings.EqualFold(args[1], "async")) {
+		c.w.WriteError("ERR syntax error")
+		return
+	}
+	if err := s.flushNow(); err != nil {
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	c.w.WriteSimpleString("OK")
+}
+
+func (s *Server) mget(c *client, args []string) {
+	found := s.batch.GetMany(s.ctx, args[1:])
+	c.w.WriteArrayHeader(len(args) - 1)
+	for _, key := range args[1:] {
+		if v, ok := found[key]; ok && s.live(v) {
+			c.w.WriteBulkString(toRecord(v).Value)
+		} else {
+			c.w.WriteNull()
+		}
+	}
+}
+
+func (s *Server) mset(c *client, args []string) {
+	if len(args)%2 != 1 {
+		c.w.WriteError("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+	items := make(map[string]zwis.Item, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		items[args[i]] = zwis.Item{Value: Record{Value: args[i+1], CAS: s.nextCAS.Add(1)}, TTL: -1}
+	}
+	if err := s.batch.SetMany(s.ctx, items); err != nil {
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	c.w.WriteSimpleString("OK")
+}
+
+// dbsize replies with the number of entries, if the cache reports it.
+func (s *Server) dbsize(c *client, args []string) {
+	c.w.WriteInteger(s.entries())
+}
+
+func (s *Server) entries() int64 {
+	if sp, ok := s.cache.(zwis.StatsProvider); ok {
+		return int64(sp.Stats().Entries)
+	}
+	return 0
+}
+
+// info replies with the server, clients, stats and keyspace sections, or
+// only those named in the arguments.
+func (s *Server) info(c *client, args []string) {
+	want := func(section string) bool {
+		if len(args) == 1 {
+			return true
+		}
+		for _, arg := range args[1:] {
+			if a := strings.ToLower(arg); a == section || a == "all" || a == "everything" || a == "default" {
+				return true
+			}
+		}
+		return false
+	}
+
+	var b strings.Builder
+	if want("server") {
+		fmt.Fprintf(&b, "# Server\r\nredis_version:%s\r\nredis_mode:standalone\r\nzwis_cache:%T\r\nos:%s %s\r\nuptime_in_seconds:%d\r\n\r\n",
+			redisVersion, s.cache, runtime.GOOS, runtime.GOARCH, int64(s.now().Sub(s.started)/time.Second))
+	}
+	if want("clients") {
+		s.mu.Lock()
+		connected := len(s.conns)
+		s.mu.Unlock()
+		fmt.Fprintf(&b, "# Clients\r\nconnected_clients:%d\r\n\r\n", connected)
+	}
+	if want("stats") {
+		fmt.Fprintf(&b, "# Stats\r\ntotal_connections_received:%d\r\ntotal_commands_processed:%d\r\n",
+			s.connectionsReceived.Load(), s.commandsProcessed.Load())
+		if sp, ok := s.cache.(zwis.StatsProvider); ok {
+			stats := sp.Stats()
+			fmt.Fprintf(&b, "keyspace_hits:%d\r\nkeyspace_misses:%d\r\nevicted_keys:%d\r\nexpired_keys:%d\r\n",
+				stats.Hits, stats.Misses, stats.CapacityEvictions, stats.ExpiredEvictions)
+		}
+		b.WriteString("\r\n")
+	}
+	if want("keyspace") {
+		b.WriteString("# Keyspace\r\n")
+		if n := s.entries(); n > 0 {
+			fmt.Fprintf(&b, "db0:keys=%d,expires=0,avg_ttl=0\r\n", n)
+		}
+	}
+	c.w.WriteBulkString(strings.TrimSuffix(b.String(), "\r\n"))
+}
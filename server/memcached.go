@@ -0,0 +1,517 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+const (
+	// memcachedVersion is reported by the version command.
+	memcachedVersion = "1.6.21"
+	// maxItemSize is the largest value memcached clients may store.
+	maxItemSize = 1 << 20
+	// maxKeyLen is memcached's limit on key length.
+	maxKeyLen = 250
+	// relativeExptimeLimit is the largest exptime memcached treats as
+	// seconds from now rather than as a Unix time.
+	relativeExptimeLimit = 60 * 60 * 24 * 30
+	// maxLineLen bounds the length of a text protocol command line.
+	maxLineLen = 64 << 10
+)
+
+// ListenAndServeMemcached listens on the TCP address addr and serves
+// memcached clients until Close is called.
+func (s *Server) ListenAndServeMemcached(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeMemcached(l)
+}
+
+// ServeMemcached accepts memcached clients on l, speaking the text or the
+// binary protocol, until Close is called. It always returns a non-nil
+// error, ErrServerClosed after Close.
+func (s *Server) ServeMemcached(l net.Listener) error {
+	return s.serve(l, s.serveMemcached)
+}
+
+func (s *Server) serveMemcached(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	b, err := r.Peek(1)
+	if err != nil {
+		return
+	}
+	if b[0] == binaryRequest {
+		err = s.serveMemcachedBinary(r, w)
+	} else {
+		err = s.serveMemcachedText(r, w)
+	}
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+		s.errorLog.Printf("zwis server: %s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// mcStatus is the outcome of a memcached operation, shared by both
+// protocols.
+type mcStatus int
+
+const (
+	mcOK mcStatus = iota
+	mcNotFound
+	mcExists
+	mcNotStored
+	mcNonNumeric
+	mcTooLarge
+	mcInvalid
+)
+
+// storeMode selects the memcached storage command.
+type storeMode int
+
+const (
+	modeSet storeMode = iota
+	modeAdd
+	modeReplace
+	modeAppend
+	modePrepend
+	modeCAS
+)
+
+// expiry converts a memcached exptime to an absolute expiration time: zero
+// for no expiration, seconds from now up to 30 days, and a Unix time beyond
+// that. It reports false for exptimes that have already passed, including
+// negative ones.
+func (s *Server) expiry(exptime int64) (time.Time, bool) {
+	switch {
+	case exptime == 0:
+		return time.Time{}, true
+	case exptime < 0:
+		return time.Time{}, false
+	case exptime <= relativeExptimeLimit:
+		return s.now().Add(time.Duration(exptime) * time.Second), true
+	default:
+		at := time.Unix(exptime, 0)
+		return at, at.After(s.now())
+	}
+}
+
+// mcStore runs a storage command. A non-zero cas, or modeCAS, makes the
+// write conditional on the key's current CAS value. It returns the new CAS
+// value.
+func (s *Server) mcStore(mode storeMode, key string, flags uint32, exptime int64, data string, cas uint64) (uint64, mcStatus, error) {
+	defer s.lockKey(key).Unlock()
+
+	old, exists := s.lookup(key)
+	switch {
+	case mode == modeAdd && exists:
+		return 0, mcNotStored, nil
+	case (mode == modeReplace || mode == modeAppend || mode == modePrepend) && !exists:
+		return 0, mcNotStored, nil
+	case (mode == modeCAS || cas != 0) && !exists:
+		return 0, mcNotFound, nil
+	case (mode == modeCAS || cas != 0) && old.CAS != cas:
+		return 0, mcExists, nil
+	}
+
+	rec := Record{Value: data, Flags: flags}
+	live := true
+	switch mode {
+	case modeAppend:
+		rec = old
+		rec.Value = old.Value + data
+	case modePrepend:
+		rec = old
+		rec.Value = data + old.Value
+	default:
+		rec.ExpireAt, live = s.expiry(exptime)
+	}
+	if len(rec.Value) > maxItemSize {
+		return 0, mcTooLarge, nil
+	}
+	if !live {
+		return 0, mcOK, s.cache.Delete(s.ctx, key)
+	}
+	newCAS, err := s.store(key, rec)
+	return newCAS, mcOK, err
+}
+
+// mcDelete deletes key if it exists and, for a non-zero cas, has that CAS
+// value.
+func (s *Server) mcDelete(key string, cas uint64) (mcStatus, error) {
+	defer s.lockKey(key).Unlock()
+
+	old, exists := s.lookup(key)
+	switch {
+	case !exists:
+		return mcNotFound, nil
+	case cas != 0 && old.CAS != cas:
+		return mcExists, nil
+	}
+	return mcOK, s.cache.Delete(s.ctx, key)
+}
+
+// mcIncr adds delta to, or subtracts it from, the decimal value of key.
+// Increments wrap at 64 bits and decrements stop at zero. A missing key is
+// created with initial if create is set.
+func (s *Server) mcIncr(key string, delta uint64, incr bool, initial uint64, create bool, exptime int64) (uint64, uint64, mcStatus, error) {
+	defer s.lockKey(key).Unlock()
+
+	rec, exists := s.lookup(key)
+	var n uint64
+	switch {
+	case exists:
+		var err error
+		if n, err = strconv.ParseUint(strings.TrimRight(rec.Value, " "), 10, 64); err != nil {
+			return 0, 0, mcNonNumeric, nil
+		}
+		switch {
+		case incr:
+			n += delta
+		case delta > n:
+			n = 0
+		default:
+			n -= delta
+		}
+	case create:
+		n = initial
+		var live bool
+		if rec.ExpireAt, live = s.expiry(exptime); !live {
+			return 0, 0, mcNotFound, nil
+		}
+	default:
+		return 0, 0, mcNotFound, nil
+	}
+
+	rec.Value = strconv.FormatUint(n, 10)
+	cas, err := s.store(key, rec)
+	return n, cas, mcOK, err
+}
+
+// mcTouch sets a new expiration time for key.
+func (s *Server) mcTouch(key string, exptime int64) (mcStatus, error) {
+	defer s.lockKey(key).Unlock()
+
+	rec, exists := s.lookup(key)
+	if !exists {
+		return mcNotFound, nil
+	}
+	var live bool
+	if rec.ExpireAt, live = s.expiry(exptime); !live {
+		return mcOK, s.cache.Delete(s.ctx, key)
+	}
+	_, err := s.store(key, rec)
+	return mcOK, err
+}
+
+// mcFlush flushes the cache now, or once delay seconds have passed. A
+// delay over 30 days is a Unix time, as for exptime.
+func (s *Server) mcFlush(delay int64) error {
+	if delay <= 0 {
+		return s.flushNow()
+	}
+	at, _ := s.expiry(delay)
+	return s.flushAt(at)
+}
+
+// mcStats returns the stats reported by the stats command: server-wide
+// counters followed by the cache's own statistics.
+func (s *Server) mcStats() [][2]string {
+	s.mu.Lock()
+	connected := len(s.conns)
+	s.mu.Unlock()
+
+	now := s.now()
+	stats := [][2]string{
+		{"pid", strconv.Itoa(os.Getpid())},
+		{"uptime", strconv.FormatInt(int64(now.Sub(s.started)/time.Second), 10)},
+		{"time", strconv.FormatInt(now.Unix(), 10)},
+		{"version", memcachedVersion},
+		{"pointer_size", strconv.Itoa(32 << (^uintptr(0) >> 63))},
+		{"threads", strconv.Itoa(runtime.GOMAXPROCS(0))},
+		{"curr_connections", strconv.Itoa(connected)},
+		{"total_connections", strconv.FormatUint(s.connectionsReceived.Load(), 10)},
+		{"cmd_processed", strconv.FormatUint(s.commandsProcessed.Load(), 10)},
+		{"limit_maxbytes", "0"},
+		{"item_size_max", strconv.Itoa(maxItemSize)},
+	}
+	if sp, ok := s.cache.(zwis.StatsProvider); ok {
+		st := sp.Stats()
+		stats = append(stats,
+			[2]string{"curr_items", strconv.FormatInt(st.Entries, 10)},
+			[2]string{"get_hits", strconv.FormatUint(st.Hits, 10)},
+			[2]string{"get_misses", strconv.FormatUint(st.Misses, 10)},
+			[2]string{"cmd_set", strconv.FormatUint(st.Sets, 10)},
+			[2]string{"cmd_delete", strconv.FormatUint(st.Deletes, 10)},
+			[2]string{"evictions", strconv.FormatUint(st.CapacityEvictions, 10)},
+			[2]string{"reclaimed", strconv.FormatUint(st.ExpiredEvictions, 10)},
+			[2]string{"hit_ratio", strconv.FormatFloat(st.HitRatio, 'f', 4, 64)},
+		)
+	}
+	return stats
+}
+
+// mcResetStats resets the cache's statistics, if it keeps any.
+func (s *Server) mcResetStats() {
+	if sp, ok := s.cache.(zwis.StatsProvider); ok {
+		sp.ResetStats()
+	}
+}
+
+// textReplies are the text protocol replies to each status.
+var textReplies = map[mcStatus]string{
+	mcOK:         "STORED",
+	mcNotFound:   "NOT_FOUND",
+	mcExists:     "EXISTS",
+	mcNotStored:  "NOT_STORED",
+	mcNonNumeric: "CLIENT_ERROR cannot increment or decrement non-numeric value",
+	mcTooLarge:   "SERVER_ERROR object too large for cache",
+	mcInvalid:    "CLIENT_ERROR bad command line format",
+}
+
+var errLineTooLong = errors.New("memcached: line too long")
+
+// serveMemcachedText serves the memcached text protocol until the client
+// quits or the connection fails.
+func (s *Server) serveMemcachedText(r *bufio.Reader, w *bufio.Writer) error {
+	for {
+		line, err := readTextLine(r)
+		if err == errLineTooLong {
+			w.WriteString("CLIENT_ERROR line too long\r\n")
+			w.Flush()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			w.WriteString("ERROR\r\n")
+		} else {
+			s.commandsProcessed.Add(1)
+			quit, err := s.executeText(r, w, fields)
+			if err != nil || quit {
+				w.Flush()
+				return err
+			}
+		}
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readTextLine reads a line terminated by "\r\n" or "\n".
+func readTextLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		long := append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull && len(long) <= maxLineLen {
+			line, err = r.ReadSlice('\n')
+			long = append(long, line...)
+		}
+		if err == bufio.ErrBufferFull {
+			return "", errLineTooLong
+		}
+		line = long
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// executeText runs one text protocol command. It reports whether the client
+// asked to quit; errors are I/O errors that end the connection.
+func (s *Server) executeText(r *bufio.Reader, w *bufio.Writer, fields []string) (quit bool, err error) {
+	name, args := fields[0], fields[1:]
+	noreply := len(args) > 0 && args[len(args)-1] == "noreply"
+	reply := func(msg string) {
+		if !noreply {
+			w.WriteString(msg)
+			w.WriteString("\r\n")
+		}
+	}
+	replyStatus := func(status mcStatus, err error) {
+		if err != nil {
+			reply("SERVER_ERROR " + err.Error())
+		} else {
+			reply(textReplies[status])
+		}
+	}
+
+	switch name {
+	case "get", "gets":
+		if len(args) == 0 {
+			w.WriteString("ERROR\r\n")
+			return false, nil
+		}
+		for _, key := range args {
+			rec, ok := s.lookup(key)
+			if !ok {
+				continue
+			}
+			if name == "gets" {
+				fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, rec.Flags, len(rec.Value), rec.CAS)
+			} else {
+				fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, rec.Flags, len(rec.Value))
+			}
+			w.WriteString(rec.Value)
+			w.WriteString("\r\n")
+		}
+		w.WriteString("END\r\n")
+
+	case "set", "add", "replace", "append", "prepend", "cas":
+		mode := map[string]storeMode{
+			"set": modeSet, "add": modeAdd, "replace": modeReplace,
+			"append": modeAppend, "prepend": modePrepend, "cas": modeCAS,
+		}[name]
+		want := 4
+		if mode == modeCAS {
+			want = 5
+		}
+		if n := len(args); n != want && !(n == want+1 && noreply) {
+			reply(textReplies[mcInvalid])
+			return false, nil
+		}
+		key := args[0]
+		flags, err1 := strconv.ParseUint(args[1], 10, 32)
+		exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+		size, err3 := strconv.Atoi(args[3])
+		var cas uint64
+		var err4 error
+		if mode == modeCAS {
+			cas, err4 = strconv.ParseUint(args[4], 10, 64)
+		}
+		if err3 != nil || size < 0 {
+			reply(textReplies[mcInvalid])
+			return false, nil
+		}
+		if size > maxItemSize {
+			reply(textReplies[mcTooLarge])
+			_, err := r.Discard(size + 2)
+			return false, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return false, err
+		}
+		if data[size] != '\r' || data[size+1] != '\n' {
+			reply("CLIENT_ERROR bad data chunk")
+			if data[size+1] != '\n' {
+				// Skip the rest of the oversized block.
+				_, err := readTextLine(r)
+				return false, err
+			}
+			return false, nil
+		}
+		if len(key) > maxKeyLen || err1 != nil || err2 != nil || err4 != nil {
+			reply(textReplies[mcInvalid])
+			return false, nil
+		}
+		_, status, err := s.mcStore(mode, key, uint32(flags), exptime, string(data[:size]), cas)
+		replyStatus(status, err)
+
+	case "delete":
+		// "delete key 0" is an old form still sent by some clients.
+		if n := len(args); n == 0 || len(args) > 3 || (n >= 2 && args[1] != "0" && args[1] != "noreply") {
+			reply("CLIENT_ERROR bad command line format. Usage: delete <key> [noreply]")
+			return false, nil
+		}
+		status, err := s.mcDelete(args[0], 0)
+		if err == nil && status == mcOK {
+			reply("DELETED")
+		} else {
+			replyStatus(status, err)
+		}
+
+	case "incr", "decr":
+		if n := len(args); n != 2 && !(n == 3 && noreply) {
+			reply("ERROR")
+			return false, nil
+		}
+		delta, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			reply("CLIENT_ERROR invalid numeric delta argument")
+			return false, nil
+		}
+		n, _, status, err := s.mcIncr(args[0], delta, name == "incr", 0, false, 0)
+		if err == nil && status == mcOK {
+			reply(strconv.FormatUint(n, 10))
+		} else {
+			replyStatus(status, err)
+		}
+
+	case "touch":
+		if n := len(args); n != 2 && !(n == 3 && noreply) {
+			reply("ERROR")
+			return false, nil
+		}
+		exptime, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			reply("CLIENT_ERROR invalid exptime argument")
+			return false, nil
+		}
+		status, err := s.mcTouch(args[0], exptime)
+		if err == nil && status == mcOK {
+			reply("TOUCHED")
+		} else {
+			replyStatus(status, err)
+		}
+
+	case "flush_all":
+		var delay int64
+		if len(args) > 0 && args[0] != "noreply" {
+			var err error
+			if delay, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+				reply(textReplies[mcInvalid])
+				return false, nil
+			}
+		}
+		if err := s.mcFlush(delay); err != nil {
+			reply("SERVER_ERROR " + err.Error())
+		} else {
+			reply("OK")
+		}
+
+	case "stats":
+		switch {
+		case len(args) == 0:
+			for _, stat := range s.mcStats() {
+				fmt.Fprintf(w, "STAT %s %s\r\n", stat[0], stat[1])
+			}
+			w.WriteString("END\r\n")
+		case len(args) == 1 && args[0] == "reset":
+			s.mcResetStats()
+			w.WriteString("RESET\r\n")
+		default:
+			w.WriteString("ERROR\r\n")
+		}
+
+	case "version":
+		w.WriteString("VERSION " + memcachedVersion + "\r\n")
+
+	case "verbosity":
+		reply("OK")
+
+	case "quit":
+		return true, nil
+
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return false, nil
+}
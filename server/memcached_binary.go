@@ -0,0 +1,324 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// Magic bytes of the memcached binary protocol.
+const (
+	binaryRequest  = 0x80
+	binaryResponse = 0x81
+)
+
+// Binary protocol opcodes. The quiet variants only reply on failure, or
+// for GETQ and GETKQ on a hit.
+const (
+	opGet       = 0x00
+	opSet       = 0x01
+	opAdd       = 0x02
+	opReplace   = 0x03
+	opDelete    = 0x04
+	opIncrement = 0x05
+	opDecrement = 0x06
+	opQuit      = 0x07
+	opFlush     = 0x08
+	opGetQ      = 0x09
+	opNoop      = 0x0a
+	opVersion   = 0x0b
+	opGetK      = 0x0c
+	opGetKQ     = 0x0d
+	opAppend    = 0x0e
+	opPrepend   = 0x0f
+	opStat      = 0x10
+	opSetQ      = 0x11
+	opAddQ      = 0x12
+	opReplaceQ  = 0x13
+	opDeleteQ   = 0x14
+	opIncrQ     = 0x15
+	opDecrQ     = 0x16
+	opQuitQ     = 0x17
+	opFlushQ    = 0x18
+	opAppendQ   = 0x19
+	opPrependQ  = 0x1a
+	opTouch     = 0x1c
+)
+
+// Binary protocol response statuses.
+const (
+	statusOK             = 0x00
+	statusKeyNotFound    = 0x01
+	statusKeyExists      = 0x02
+	statusValueTooLarge  = 0x03
+	statusInvalidArgs    = 0x04
+	statusNotStored      = 0x05
+	statusNonNumeric     = 0x06
+	statusUnknownCommand = 0x81
+	statusInternalError  = 0x84
+)
+
+// binaryStatuses maps each operation outcome to its response status and
+// message.
+var binaryStatuses = map[mcStatus]struct {
+	code uint16
+	msg  string
+}{
+	mcNotFound:   {statusKeyNotFound, "Not found"},
+	mcExists:     {statusKeyExists, "Data exists for key."},
+	mcNotStored:  {statusNotStored, "Not stored."},
+	mcNonNumeric: {statusNonNumeric, "Non-numeric server-side value for incr or decr"},
+	mcTooLarge:   {statusValueTooLarge, "Too large."},
+	mcInvalid:    {statusInvalidArgs, "Invalid arguments"},
+}
+
+// noExpiration in an increment's expiration field means a missing key is
+// not created.
+const noExpiration = 0xffffffff
+
+const binaryHeaderLen = 24
+
+// binaryPacket is a binary protocol request or response.
+type binaryPacket struct {
+	opcode byte
+	status uint16 // vbucket id in requests
+	opaque uint32
+	cas    uint64
+	extras []byte
+	key    []byte
+	value  []byte
+}
+
+// serveMemcachedBinary serves the memcached binary protocol until the
+// client quits or the connection fails.
+func (s *Server) serveMemcachedBinary(r *bufio.Reader, w *bufio.Writer) error {
+	var header [binaryHeaderLen]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return err
+		}
+		if header[0] != binaryRequest {
+			return nil
+		}
+		keyLen := int(binary.BigEndian.Uint16(header[2:4]))
+		extrasLen := int(header[4])
+		bodyLen := int(binary.BigEndian.Uint32(header[8:12]))
+		req := binaryPacket{
+			opcode: header[1],
+			opaque: binary.BigEndian.Uint32(header[12:16]),
+			cas:    binary.BigEndian.Uint64(header[16:24]),
+		}
+
+		s.commandsProcessed.Add(1)
+		switch {
+		case keyLen+extrasLen > bodyLen:
+			return nil
+		case bodyLen > maxItemSize+maxKeyLen+extrasLen:
+			if _, err := r.Discard(bodyLen); err != nil {
+				return err
+			}
+			s.writeBinaryStatus(w, req, mcTooLarge)
+		default:
+			body := make([]byte, bodyLen)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return err
+			}
+			req.extras = body[:extrasLen]
+			req.key = body[extrasLen : extrasLen+keyLen]
+			req.value = body[extrasLen+keyLen:]
+			if quit := s.executeBinary(w, req); quit {
+				return w.Flush()
+			}
+		}
+
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// executeBinary runs one binary protocol request and reports whether the
+// client asked to quit.
+func (s *Server) executeBinary(w *bufio.Writer, req binaryPacket) (quit bool) {
+	key := string(req.key)
+	quiet := false
+	switch req.opcode {
+	case opGetQ, opGetKQ, opSetQ, opAddQ, opReplaceQ, opDeleteQ, opIncrQ, opDecrQ, opQuitQ, opFlushQ, opAppendQ, opPrependQ:
+		quiet = true
+	}
+	reply := func(res binaryPacket) {
+		if !quiet {
+			s.writeBinary(w, req, res)
+		}
+	}
+	replyStatus := func(status mcStatus, err error) {
+		switch {
+		case err != nil:
+			s.writeBinary(w, req, binaryPacket{status: statusInternalError, value: []byte(err.Error())})
+		case status != mcOK:
+			s.writeBinaryStatus(w, req, status)
+		default:
+			reply(binaryPacket{})
+		}
+	}
+	validate := func(extras int, needKey bool, value bool) bool {
+		if len(req.extras) != extras || (len(req.key) == 0) == needKey || (len(req.value) > 0) != value ||
+			len(req.key) > maxKeyLen {
+			s.writeBinaryStatus(w, req, mcInvalid)
+			return false
+		}
+		return true
+	}
+
+	switch req.opcode {
+	case opGet, opGetQ, opGetK, opGetKQ:
+		if !validate(0, true, false) {
+			return false
+		}
+		rec, ok := s.lookup(key)
+		withKey := req.opcode == opGetK || req.opcode == opGetKQ
+		if !ok {
+			if !quiet {
+				res := binaryPacket{status: statusKeyNotFound, value: []byte("Not found")}
+				if withKey {
+					res.key = req.key
+				}
+				s.writeBinary(w, req, res)
+			}
+			return false
+		}
+		res := binaryPacket{cas: rec.CAS, extras: binary.BigEndian.AppendUint32(nil, rec.Flags), value: []byte(rec.Value)}
+		if withKey {
+			res.key = req.key
+		}
+		s.writeBinary(w, req, res)
+
+	case opSet, opSetQ, opAdd, opAddQ, opReplace, opReplaceQ:
+		if len(req.extras) != 8 || len(req.key) == 0 || len(req.key) > maxKeyLen {
+			s.writeBinaryStatus(w, req, mcInvalid)
+			return false
+		}
+		mode := modeSet
+		switch req.opcode {
+		case opAdd, opAddQ:
+			mode = modeAdd
+		case opReplace, opReplaceQ:
+			mode = modeReplace
+		}
+		flags := binary.BigEndian.Uint32(req.extras[0:4])
+		exptime := int64(binary.BigEndian.Uint32(req.extras[4:8]))
+		cas, status, err := s.mcStore(mode, key, flags, exptime, string(req.value), req.cas)
+		if err == nil && status == mcOK {
+			reply(binaryPacket{cas: cas})
+		} else {
+			replyStatus(status, err)
+		}
+
+	case opAppend, opAppendQ, opPrepend, opPrependQ:
+		if len(req.extras) != 0 || len(req.key) == 0 || len(req.key) > maxKeyLen {
+			s.writeBinaryStatus(w, req, mcInvalid)
+			return false
+		}
+		mode := modeAppend
+		if req.opcode == opPrepend || req.opcode == opPrependQ {
+			mode = modePrepend
+		}
+		cas, status, err := s.mcStore(mode, key, 0, 0, string(req.value), req.cas)
+		if err == nil && status == mcOK {
+			reply(binaryPacket{cas: cas})
+		} else {
+			replyStatus(status, err)
+		}
+
+	case opDelete, opDeleteQ:
+		if !validate(0, true, false) {
+			return false
+		}
+		replyStatus(s.mcDelete(key, req.cas))
+
+	case opIncrement, opIncrQ, opDecrement, opDecrQ:
+		if !validate(20, true, false) {
+			return false
+		}
+		delta := binary.BigEndian.Uint64(req.extras[0:8])
+		initial := binary.BigEndian.Uint64(req.extras[8:16])
+		expiration := binary.BigEndian.Uint32(req.extras[16:20])
+		incr := req.opcode == opIncrement || req.opcode == opIncrQ
+		n, cas, status, err := s.mcIncr(key, delta, incr, initial, expiration != noExpiration, int64(expiration))
+		if err == nil && status == mcOK {
+			reply(binaryPacket{cas: cas, value: binary.BigEndian.AppendUint64(nil, n)})
+		} else {
+			replyStatus(status, err)
+		}
+
+	case opTouch:
+		if !validate(4, true, false) {
+			return false
+		}
+		replyStatus(s.mcTouch(key, int64(binary.BigEndian.Uint32(req.extras))))
+
+	case opFlush, opFlushQ:
+		if (len(req.extras) != 0 && len(req.extras) != 4) || len(req.key) != 0 || len(req.value) != 0 {
+			s.writeBinaryStatus(w, req, mcInvalid)
+			return false
+		}
+		var delay int64
+		if len(req.extras) == 4 {
+			delay = int64(binary.BigEndian.Uint32(req.extras))
+		}
+		replyStatus(mcOK, s.mcFlush(delay))
+
+	case opStat:
+		switch key {
+		case "":
+			for _, stat := range s.mcStats() {
+				s.writeBinary(w, req, binaryPacket{key: []byte(stat[0]), value: []byte(stat[1])})
+			}
+		case "reset":
+			s.mcResetStats()
+		default:
+			s.writeBinaryStatus(w, req, mcNotFound)
+			return false
+		}
+		s.writeBinary(w, req, binaryPacket{})
+
+	case opNoop:
+		s.writeBinary(w, req, binaryPacket{})
+
+	case opVersion:
+		s.writeBinary(w, req, binaryPacket{value: []byte(memcachedVersion)})
+
+	case opQuit, opQuitQ:
+		reply(binaryPacket{})
+		return true
+
+	default:
+		s.writeBinary(w, req, binaryPacket{status: statusUnknownCommand, value: []byte("Unknown command")})
+	}
+	return false
+}
+
+// writeBinaryStatus writes an error response for a failed operation.
+func (s *Server) writeBinaryStatus(w *bufio.Writer, req binaryPacket, status mcStatus) {
+	st := binaryStatuses[status]
+	s.writeBinary(w, req, binaryPacket{status: st.code, value: []byte(st.msg)})
+}
+
+// writeBinary writes res as the response to req.
+func (s *Server) writeBinary(w *bufio.Writer, req, res binaryPacket) {
+	var header [binaryHeaderLen]byte
+	header[0] = binaryResponse
+	header[1] = req.opcode
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(res.key)))
+	header[4] = byte(len(res.extras))
+	binary.BigEndian.PutUint16(header[6:8], res.status)
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(res.extras)+len(res.key)+len(res.value)))
+	binary.BigEndian.PutUint32(header[12:16], req.opaque)
+	binary.BigEndian.PutUint64(header[16:24], res.cas)
+	w.Write(header[:])
+	w.Write(res.extras)
+	w.Write(res.key)
+	w.Write(res.value)
+}
@@ -0,0 +1,255 @@
+// Package server exposes a zwis cache over TCP using the Redis and memcached
+// protocols, so redis-cli, standard Redis clients and memcached clients can
+// share a cache with Go services.
+package server
+
+/*
+Each connection is served by its own goroutine, which reads a command, runs it against the cache and buffers the reply. Replies are flushed once no more pipelined commands are waiting, so a pipeline costs one write rather than one per command.
+
+The server stores values as Records that remember their expiration time, since zwis caches do not report the TTL of an entry, together with the memcached flags and CAS value. Both protocols share the same records, so a key set by a Redis client can be read by a memcached client, and Go code reads them by type-asserting to Record or with ValueOf. Values stored in the cache by other code are served as described by ValueOf: strings and []byte as is, encoding.TextMarshalers as their text and anything else formatted with fmt.Sprint. Their TTL is reported as -1 and their CAS value as 0.
+
+A memcached listener accepts both the text and the binary protocol, telling them apart by the first byte a client sends.
+*/
+
+import (
+	"context"
+	"errors"
+	"hash/maphash"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/internal/resp"
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close.
+var ErrServerClosed = errors.New("server: Server closed")
+
+// Server serves a zwis cache to Redis clients.
+type Server struct {
+	cache    zwis.Cache
+	batch    zwis.BatchCache
+	clock    zwis.Clock
+	errorLog *log.Logger
+	started  time.Time
+
+	// keyLocks serialise single-key writes, so commands that read a key
+	// before writing it, such as SET NX, EXPIRE and memcached's cas, are
+	// atomic with respect to each other. Multi-key commands such as MSET
+	// and DEL do not take them.
+	keyLocks [64]sync.Mutex
+	seed     maphash.Seed
+
+	nextCAS atomic.Uint64
+
+	flushMu   sync.Mutex
+	flushGen  uint64 // Identifies the pending delayed flush_all
+	stopFlush func() // Stops the pending delayed flush_all, or nil
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	nextClientID        atomic.Int64
+	connectionsReceived atomic.Uint64
+	commandsProcessed   atomic.Uint64
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithClock sets the clock used to compute TTLs and to run delayed
+// flush_all commands. Pass the same clock the cache was created with.
+func WithClock(clock zwis.Clock) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithErrorLog sets the logger for errors accepting or serving connections.
+// The default logs to the standard logger.
+func WithErrorLog(l *log.Logger) Option {
+	return func(s *Server) {
+		s.errorLog = l
+	}
+}
+
+// New creates a Server for cache.
+func New(cache zwis.Cache, opts ...Option) *Server {
+	s := &Server{
+		cache:     cache,
+		batch:     zwis.NewBatchCache(cache),
+		clock:     zwis.SystemClock{},
+		errorLog:  log.Default(),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+		seed:      maphash.MakeSeed(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.started = s.now()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// ListenAndServe listens on the TCP address addr and serves Redis clients
+// until Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts Redis clients on l until Close is called. It always returns
+// a non-nil error, ErrServerClosed after Close.
+func (s *Server) Serve(l net.Listener) error {
+	return s.serve(l, s.serveRESP)
+}
+
+// serve accepts connections on l and hands each to handle in its own
+// goroutine.
+func (s *Server) serve(l net.Listener, handle func(net.Conn)) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				s.errorLog.Printf("zwis server: accept: %v", err)
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		s.connectionsReceived.Add(1)
+		go func() {
+			defer s.release(conn)
+			handle(conn)
+		}()
+	}
+}
+
+// release closes conn once its handler returns.
+func (s *Server) release(conn net.Conn) {
+	conn.Close()
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// lockKey locks the stripe of keyLocks that key hashes to and returns it.
+func (s *Server) lockKey(key string) *sync.Mutex {
+	mu := &s.keyLocks[maphash.String(s.seed, key)%uint64(len(s.keyLocks))]
+	mu.Lock()
+	return mu
+}
+
+// Close stops accepting connections, closes every open connection and waits
+// for their goroutines to exit.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.cancel()
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.flushMu.Lock()
+	s.cancelFlushLocked()
+	s.flushMu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) now() time.Time {
+	return s.clock.Now()
+}
+
+// client is the per-connection state of a Redis client.
+type client struct {
+	id   int64
+	name string
+	w    *resp.Writer
+	quit bool
+}
+
+func (s *Server) serveRESP(conn net.Conn) {
+	r := resp.NewReader(conn)
+	c := &client{id: s.nextClientID.Add(1), w: resp.NewWriter(conn)}
+	for !c.quit {
+		args, err := r.ReadCommand()
+		if err != nil {
+			if errors.Is(err, resp.ErrProtocol) {
+				c.w.WriteError("ERR Protocol error: " + strings.TrimPrefix(err.Error(), resp.ErrProtocol.Error()+": "))
+				c.w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.errorLog.Printf("zwis server: %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		s.commandsProcessed.Add(1)
+		s.execute(c, args)
+		if r.Buffered() == 0 || c.quit {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
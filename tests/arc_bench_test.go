@@ -0,0 +1,245 @@
+package zwis_test
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// legacyARCCache is the previous ARC implementation, which located entries by
+// walking T1, T2, B1 and B2. It is kept here only as a benchmark baseline.
+type legacyARCCache struct {
+	capacity int
+	p        int
+	t1       *list.List
+	t2       *list.List
+	b1       *list.List
+	b2       *list.List
+	cache    map[string]*list.Element
+	mu       sync.Mutex
+}
+
+type legacyARCItem struct {
+	key        string
+	value      interface{}
+	expiration int64
+}
+
+func newLegacyARCCache(capacity int) *legacyARCCache {
+	return &legacyARCCache{
+		capacity: capacity,
+		t1:       list.New(),
+		t2:       list.New(),
+		b1:       list.New(),
+		b2:       list.New(),
+		cache:    make(map[string]*list.Element),
+	}
+}
+
+func (c *legacyARCCache) Get(ctx context.Context, key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elt, ok := c.cache[key]; ok {
+		item := elt.Value.(*legacyARCItem)
+		if c.listContains(c.t1, elt) {
+			c.t1.Remove(elt)
+			c.t2.PushFront(item)
+			c.cache[key] = c.t2.Front()
+		} else if c.listContains(c.t2, elt) {
+			c.t2.MoveToFront(elt)
+		}
+		return item.value, true
+	}
+
+	c.request(key)
+	return nil, false
+}
+
+func (c *legacyARCCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elt, ok := c.cache[key]; ok {
+		item := elt.Value.(*legacyARCItem)
+		item.value = value
+		if c.listContains(c.t1, elt) {
+			c.t1.Remove(elt)
+			c.t2.PushFront(item)
+			c.cache[key] = c.t2.Front()
+		} else if c.listContains(c.t2, elt) {
+			c.t2.MoveToFront(elt)
+		}
+		return nil
+	}
+
+	c.request(key)
+	if c.t1.Len()+c.t2.Len() >= c.capacity {
+		c.replace(key)
+	}
+	c.t1.PushFront(&legacyARCItem{key: key, value: value})
+	c.cache[key] = c.t1.Front()
+	return nil
+}
+
+func (c *legacyARCCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elt, ok := c.cache[key]; ok {
+		for _, l := range []*list.List{c.t1, c.t2} {
+			if c.listContains(l, elt) {
+				l.Remove(elt)
+			}
+		}
+		delete(c.cache, key)
+	}
+	return nil
+}
+
+func (c *legacyARCCache) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	*c = legacyARCCache{capacity: c.capacity, t1: list.New(), t2: list.New(), b1: list.New(), b2: list.New(), cache: make(map[string]*list.Element)}
+	return nil
+}
+
+func (c *legacyARCCache) replace(key string) {
+	from, ghosts := c.t2, c.b2
+	if c.t1.Len() > 0 && (c.t1.Len() > c.p || (c.listContainsKey(c.b2, key) && c.t1.Len() == c.p)) {
+		from, ghosts = c.t1, c.b1
+	}
+	lru := from.Back()
+	if lru == nil {
+		return
+	}
+	from.Remove(lru)
+	ghosts.PushFront(lru.Value.(*legacyARCItem).key)
+	if ghosts.Len() > c.capacity {
+		ghosts.Remove(ghosts.Back())
+	}
+	delete(c.cache, lru.Value.(*legacyARCItem).key)
+}
+
+func (c *legacyARCCache) request(key string) {
+	if c.listContainsKey(c.b1, key) {
+		c.p = min(c.capacity, c.p+max(c.b2.Len()/c.b1.Len(), 1))
+	} else if c.listContainsKey(c.b2, key) {
+		c.p = max(0, c.p-max(c.b1.Len()/c.b2.Len(), 1))
+	} else {
+		return
+	}
+	for _, l := range []*list.List{c.b1, c.b2} {
+		for e := l.Front(); e != nil; e = e.Next() {
+			if e.Value.(string) == key {
+				l.Remove(e)
+				return
+			}
+		}
+	}
+}
+
+func (c *legacyARCCache) listContains(l *list.List, element *list.Element) bool {
+	for e := l.Front(); e != nil; e = e.Next() {
+		if e == element {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *legacyARCCache) listContainsKey(l *list.List, key string) bool {
+	for e := l.Front(); e != nil; e = e.Next() {
+		if s, ok := e.Value.(string); ok && s == key {
+			return true
+		}
+	}
+	return false
+}
+
+var arcBenchSizes = []int{1_000, 100_000, 1_000_000}
+
+func arcBenchImplementations() []struct {
+	name string
+	new  func(capacity int) zwis.Cache
+} {
+	return []struct {
+		name string
+		new  func(capacity int) zwis.Cache
+	}{
+		{"legacy", func(capacity int) zwis.Cache { return newLegacyARCCache(capacity) }},
+		{"indexed", func(capacity int) zwis.Cache { return zwis.NewARCCache(capacity) }},
+	}
+}
+
+func benchKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	return keys
+}
+
+func BenchmarkARCGet(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range arcBenchSizes {
+		keys := benchKeys(size)
+		for _, impl := range arcBenchImplementations() {
+			b.Run(fmt.Sprintf("size=%d/%s", size, impl.name), func(b *testing.B) {
+				cache := impl.new(size)
+				for _, key := range keys {
+					cache.Set(ctx, key, key, 0)
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					cache.Get(ctx, keys[i%size])
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkARCSet(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range arcBenchSizes {
+		keys := benchKeys(2 * size)
+		for _, impl := range arcBenchImplementations() {
+			b.Run(fmt.Sprintf("size=%d/%s", size, impl.name), func(b *testing.B) {
+				cache := impl.new(size)
+				for _, key := range keys[:size] {
+					cache.Set(ctx, key, key, 0)
+				}
+
+				// Cycle through twice the capacity so every Set evicts and
+				// populates the ghost lists.
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					key := keys[i%len(keys)]
+					cache.Set(ctx, key, key, 0)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkARCDelete(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range arcBenchSizes {
+		keys := benchKeys(size)
+		b.Run(fmt.Sprintf("size=%d/indexed", size), func(b *testing.B) {
+			cache := zwis.NewARCCache(size)
+			for i := 0; i < b.N; i++ {
+				key := keys[i%size]
+				cache.Set(ctx, key, key, 0)
+				cache.Delete(ctx, key)
+			}
+		})
+	}
+}
@@ -2,15 +2,18 @@ package zwis_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
 )
 
 func TestARCCache(t *testing.T) {
 	ctx := context.Background()
-	cache := zwis.NewARCCache(3)
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewARCCache(3, zwis.WithClock(clock))
 
 	// Test Set and Get
 	cache.Set(ctx, "key1", "value1", 0)
@@ -35,7 +38,7 @@ func TestARCCache(t *testing.T) {
 
 	// Test expiration
 	cache.Set(ctx, "key5", "value5", 50*time.Millisecond)
-	time.Sleep(100 * time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
 	if _, ok := cache.Get(ctx, "key5"); ok {
 		t.Error("key5 should have expired")
 	}
@@ -103,3 +106,77 @@ func TestARCCacheAdaptiveness(t *testing.T) {
 		}
 	}
 }
+
+func TestARCCacheGhostHit(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewARCCache(2)
+
+	cache.Set(ctx, "a", "a", 0)
+	cache.Set(ctx, "b", "b", 0)
+	cache.Get(ctx, "a")
+
+	// b is the least recently used T1 entry and moves to the B1 ghost list.
+	cache.Set(ctx, "c", "c", 0)
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Fatal("b should have been evicted")
+	}
+
+	// A miss on a ghost key must not resurrect it.
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Fatal("b should not be readable from the ghost list")
+	}
+
+	// Setting it again is a ghost hit and makes it a frequent entry.
+	cache.Set(ctx, "b", "b2", 0)
+	if v, ok := cache.Get(ctx, "b"); !ok || v != "b2" {
+		t.Errorf("Expected b2, got %v", v)
+	}
+}
+
+func TestARCCacheFullT1LeavesNoGhost(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewARCCache(3)
+
+	// With T1 full, inserting d drops a outright: keeping it as a B1 ghost
+	// would leave T1 and B1 holding more keys than the capacity.
+	for _, key := range []string{"a", "b", "c", "d"} {
+		cache.Set(ctx, key, key, 0)
+	}
+	if _, ok := cache.Get(ctx, "a"); ok {
+		t.Fatal("a should have been evicted")
+	}
+
+	// Setting a again is therefore a complete miss that lands in T1, not a
+	// ghost hit into T2, and new keys push it out like any recent entry.
+	cache.Set(ctx, "a", "a2", 0)
+	for _, key := range []string{"e", "f", "g"} {
+		cache.Set(ctx, key, key, 0)
+	}
+	if _, ok := cache.Get(ctx, "a"); ok {
+		t.Error("a should have come back as a recent entry, not a frequent one")
+	}
+}
+
+func TestARCCacheRespectsCapacity(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewARCCache(50)
+
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("key%d", (i*7919)%400)
+		if i%3 == 0 {
+			cache.Get(ctx, key)
+		} else {
+			cache.Set(ctx, key, i, 0)
+		}
+	}
+
+	resident := 0
+	for i := 0; i < 400; i++ {
+		if _, ok := cache.Get(ctx, fmt.Sprintf("key%d", i)); ok {
+			resident++
+		}
+	}
+	if resident > 50 {
+		t.Errorf("Expected at most 50 resident entries, got %d", resident)
+	}
+}
@@ -0,0 +1,92 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// BenchmarkGetMany compares looking up a request's worth of keys with one
+// GetMany call against calling Get for each key and collecting the hits,
+// with every core reading.
+func BenchmarkGetMany(b *testing.B) {
+	const capacity = 10_000
+	ctx := context.Background()
+	keys := benchKeys(capacity)
+
+	for _, cacheType := range []zwis.CacheType{zwis.LRUCacheType, zwis.ARCCacheType, zwis.SIEVECacheType} {
+		for _, batchSize := range []int{50, 200} {
+			cache, _ := zwis.NewCache(cacheType, zwis.WithCapacity(capacity))
+			for _, key := range keys {
+				cache.Set(ctx, key, key, 0)
+			}
+			batch := cache.(zwis.BatchCache)
+			// Start each goroutine at its own offset, so they do not read the
+			// same batches in lockstep.
+			var goroutines atomic.Int64
+			start := func() int { return int(goroutines.Add(1)) * 1009 % (len(keys) - batchSize) }
+
+			name := fmt.Sprintf("%s/keys=%d", cacheType, batchSize)
+			b.Run(name+"/loop", func(b *testing.B) {
+				b.RunParallel(func(pb *testing.PB) {
+					i := start()
+					for pb.Next() {
+						found := make(map[string]interface{}, batchSize)
+						for _, key := range keys[i : i+batchSize] {
+							if value, ok := cache.Get(ctx, key); ok {
+								found[key] = value
+							}
+						}
+						i = (i + batchSize) % (len(keys) - batchSize)
+					}
+				})
+			})
+			b.Run(name+"/GetMany", func(b *testing.B) {
+				b.RunParallel(func(pb *testing.PB) {
+					i := start()
+					for pb.Next() {
+						batch.GetMany(ctx, keys[i:i+batchSize])
+						i = (i + batchSize) % (len(keys) - batchSize)
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkSetMany compares storing a request's worth of items with one
+// SetMany call against calling Set for each item.
+func BenchmarkSetMany(b *testing.B) {
+	const capacity = 10_000
+	ctx := context.Background()
+	keys := benchKeys(capacity)
+
+	for _, cacheType := range []zwis.CacheType{zwis.LRUCacheType, zwis.ARCCacheType} {
+		items := make(map[string]zwis.Item, 100)
+		for _, key := range keys[:100] {
+			items[key] = zwis.Item{Value: key}
+		}
+		cache, _ := zwis.NewCache(cacheType, zwis.WithCapacity(capacity))
+		batch := cache.(zwis.BatchCache)
+
+		b.Run(string(cacheType)+"/loop", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					for key, item := range items {
+						cache.Set(ctx, key, item.Value, item.TTL)
+					}
+				}
+			})
+		})
+		b.Run(string(cacheType)+"/SetMany", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					batch.SetMany(ctx, items)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+// newBatchCaches returns every batch-capable cache, each using clock.
+func newBatchCaches(t *testing.T, clock zwis.Clock) map[string]zwis.BatchCache {
+	caches := make(map[string]zwis.BatchCache)
+	for _, cacheType := range policyCacheTypes {
+		cache, err := zwis.NewCache(cacheType, zwis.WithCapacity(100), zwis.WithClock(clock))
+		if err != nil {
+			t.Fatalf("NewCache(%s): %v", cacheType, err)
+		}
+		batch, ok := cache.(zwis.BatchCache)
+		if !ok {
+			t.Fatalf("%s cache does not implement BatchCache", cacheType)
+		}
+		caches[string(cacheType)] = batch
+	}
+
+	disk, err := zwis.NewDiskCache(t.TempDir(), 0, zwis.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewDiskCache: %v", err)
+	}
+	t.Cleanup(func() { disk.Close() })
+	caches["disk"] = disk
+
+	sharded, err := zwis.NewShardedCache(zwis.LRUCacheType, 4, zwis.WithCapacity(100), zwis.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewShardedCache: %v", err)
+	}
+	caches["sharded"] = sharded
+
+	caches["adapter"] = zwis.NewBatchCache(zwis.NewTieredCache(
+		zwis.NewLRUCache(10, zwis.WithClock(clock)),
+		zwis.NewLRUCache(100, zwis.WithClock(clock)),
+	))
+	return caches
+}
+
+func TestBatchOperations(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+
+	for name, cache := range newBatchCaches(t, clock) {
+		t.Run(name, func(t *testing.T) {
+			err := cache.SetMany(ctx, map[string]zwis.Item{
+				"a":     {Value: "1"},
+				"b":     {Value: "2"},
+				"c":     {Value: "3"},
+				"short": {Value: "4", TTL: time.Second},
+			})
+			if err != nil {
+				t.Fatalf("SetMany: %v", err)
+			}
+
+			got := cache.GetMany(ctx, []string{"a", "b", "missing", "short"})
+			want := map[string]interface{}{"a": "1", "b": "2", "short": "4"}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+
+			clock.Advance(2 * time.Second)
+			if got := cache.GetMany(ctx, []string{"short", "c"}); len(got) != 1 || got["c"] != "3" {
+				t.Errorf("Expected only c after short expired, got %v", got)
+			}
+
+			if err := cache.DeleteMany(ctx, []string{"a", "c", "missing"}); err != nil {
+				t.Fatalf("DeleteMany: %v", err)
+			}
+			if got := cache.GetMany(ctx, []string{"a", "b", "c"}); len(got) != 1 || got["b"] != "2" {
+				t.Errorf("Expected only b after DeleteMany, got %v", got)
+			}
+		})
+	}
+}
+
+func TestSetManyRespectsCapacity(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range policyCacheTypes {
+		t.Run(string(cacheType), func(t *testing.T) {
+			cache, _ := zwis.NewCache(cacheType, zwis.WithCapacity(10))
+			items := make(map[string]zwis.Item)
+			keys := make([]string, 0, 100)
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("key%d", i)
+				items[key] = zwis.Item{Value: i}
+				keys = append(keys, key)
+			}
+			cache.(zwis.BatchCache).SetMany(ctx, items)
+
+			if n := len(cache.(zwis.BatchCache).GetMany(ctx, keys)); n > 10 {
+				t.Errorf("Expected at most 10 entries, got %d", n)
+			}
+		})
+	}
+}
+
+func TestSetManyReportsErrors(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewLRUCache(0, zwis.WithMaxCost(100), zwis.WithWeigher(byteWeigher))
+
+	err := cache.SetMany(ctx, map[string]zwis.Item{
+		"small": {Value: "0123456789"},
+		"huge":  {Value: string(make([]byte, 200))},
+	})
+	if err != zwis.ErrEntryTooLarge {
+		t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+	}
+	if _, ok := cache.Get(ctx, "small"); !ok {
+		t.Error("small should have been stored despite the error")
+	}
+}
+
+func TestNewBatchCacheReturnsNativeImplementation(t *testing.T) {
+	cache := zwis.NewARCCache(10)
+	if batch := zwis.NewBatchCache(cache); batch != zwis.BatchCache(cache) {
+		t.Errorf("Expected NewBatchCache to return the cache itself, got %T", batch)
+	}
+}
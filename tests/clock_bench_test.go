@@ -0,0 +1,45 @@
+package zwis_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// BenchmarkParallelGet compares read-only throughput of LRU, whose hits take
+// a write lock to reorder its list, with policies whose hits only set a bit
+// or bump a counter under a read lock.
+func BenchmarkParallelGet(b *testing.B) {
+	const capacity = 100_000
+	ctx := context.Background()
+	keys := benchKeys(capacity)
+
+	for _, cacheType := range []zwis.CacheType{
+		zwis.LRUCacheType,
+		zwis.ClockCacheType,
+		zwis.ClockProCacheType,
+		zwis.SIEVECacheType,
+		zwis.S3FIFOCacheType,
+	} {
+		b.Run(string(cacheType), func(b *testing.B) {
+			cache, _ := zwis.NewCache(cacheType, zwis.WithCapacity(capacity))
+			for _, key := range keys {
+				cache.Set(ctx, key, key, 0)
+			}
+
+			var goroutines atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				// Start each goroutine at its own offset, so they do not
+				// walk the keys in lockstep.
+				i := int(goroutines.Add(1)) * 1009
+				for pb.Next() {
+					cache.Get(ctx, keys[(i*7919)%capacity])
+					i++
+				}
+			})
+		})
+	}
+}
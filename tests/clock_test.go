@@ -0,0 +1,120 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestClockCache(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewClockCache(3, zwis.WithClock(clock))
+
+	// Test Set and Get
+	cache.Set(ctx, "key1", "value1", 0)
+	cache.Set(ctx, "key2", "value2", 0)
+	cache.Set(ctx, "key3", "value3", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	// Test eviction: key1 is referenced, so the hand gives it a second
+	// chance and evicts key2
+	cache.Set(ctx, "key4", "value4", 0)
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have been evicted")
+	}
+	if _, ok := cache.Get(ctx, "key1"); !ok {
+		t.Error("key1 should have survived eviction")
+	}
+
+	// Test updating existing key
+	cache.Set(ctx, "key1", "new_value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "new_value1" {
+		t.Errorf("Expected new_value1, got %v", v)
+	}
+
+	// Test expiration
+	cache.Set(ctx, "key5", "value5", 50*time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
+	if _, ok := cache.Get(ctx, "key5"); ok {
+		t.Error("key5 should have expired")
+	}
+
+	// Test Delete
+	cache.Set(ctx, "key6", "value6", 0)
+	cache.Delete(ctx, "key6")
+	if _, ok := cache.Get(ctx, "key6"); ok {
+		t.Error("key6 should have been deleted")
+	}
+
+	// Test Flush
+	cache.Set(ctx, "key7", "value7", 0)
+	cache.Flush(ctx)
+	if _, ok := cache.Get(ctx, "key7"); ok {
+		t.Error("Cache should be empty after Flush")
+	}
+}
+
+func TestClockCacheReusesFreedSlots(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewClockCache(3)
+
+	cache.Set(ctx, "key1", 1, 0)
+	cache.Set(ctx, "key2", 2, 0)
+	cache.Set(ctx, "key3", 3, 0)
+	cache.Delete(ctx, "key2")
+
+	// The slot freed by Delete is reused without evicting anything.
+	cache.Set(ctx, "key4", 4, 0)
+	for _, key := range []string{"key1", "key3", "key4"} {
+		if _, ok := cache.Get(ctx, key); !ok {
+			t.Errorf("%s should still be cached", key)
+		}
+	}
+}
+
+func TestClockCacheZeroCapacity(t *testing.T) {
+	ctx := context.Background()
+
+	// A capacity below 1 is treated as 1 rather than leaving no slots.
+	cache := zwis.NewClockCache(0)
+	cache.Set(ctx, "a", 1, 0)
+	cache.Set(ctx, "b", 2, 0)
+	if v, ok := cache.Get(ctx, "b"); !ok || v != 2 || cache.Stats().Entries != 1 {
+		t.Errorf("Expected a zero-capacity cache to hold only b, got %v, %v with %d entries", v, ok, cache.Stats().Entries)
+	}
+}
+
+func TestClockCacheConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewClockCache(100)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key%d", (i*w)%200)
+				if _, ok := cache.Get(ctx, key); !ok {
+					cache.Set(ctx, key, i, 0)
+				}
+				if i%50 == 0 {
+					cache.Delete(ctx, key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := cache.Stats().Entries; n > 100 {
+		t.Errorf("Expected at most 100 entries, got %d", n)
+	}
+}
@@ -0,0 +1,123 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestClockProCache(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewClockProCache(3, zwis.WithClock(clock))
+
+	// Test Set and Get
+	cache.Set(ctx, "key1", "value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	// Test updating existing key
+	cache.Set(ctx, "key1", "new_value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "new_value1" {
+		t.Errorf("Expected new_value1, got %v", v)
+	}
+
+	// Test expiration
+	cache.Set(ctx, "key2", "value2", 50*time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have expired")
+	}
+
+	// Test Delete
+	cache.Set(ctx, "key3", "value3", 0)
+	cache.Delete(ctx, "key3")
+	if _, ok := cache.Get(ctx, "key3"); ok {
+		t.Error("key3 should have been deleted")
+	}
+
+	// Test Flush
+	cache.Set(ctx, "key4", "value4", 0)
+	cache.Flush(ctx)
+	if _, ok := cache.Get(ctx, "key4"); ok {
+		t.Error("Cache should be empty after Flush")
+	}
+}
+
+func TestClockProCacheRespectsCapacity(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewClockProCache(100)
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("key%d", r.Intn(500))
+		switch r.Intn(10) {
+		case 0:
+			cache.Delete(ctx, key)
+		case 1:
+			cache.Set(ctx, key, i, time.Duration(r.Intn(2))*time.Nanosecond)
+		default:
+			if _, ok := cache.Get(ctx, key); !ok {
+				cache.Set(ctx, key, i, 0)
+			}
+		}
+		if n := cache.Stats().Entries; n > 100 {
+			t.Fatalf("Expected at most 100 entries, got %d after %d operations", n, i)
+		}
+	}
+
+	// A capacity below 1 is treated as 1 rather than leaving no slots.
+	cache = zwis.NewClockProCache(0)
+	cache.Set(ctx, "a", 1, 0)
+	cache.Set(ctx, "b", 2, 0)
+	if v, ok := cache.Get(ctx, "b"); !ok || v != 2 || cache.Stats().Entries != 1 {
+		t.Errorf("Expected a zero-capacity cache to hold only b, got %v, %v with %d entries", v, ok, cache.Stats().Entries)
+	}
+}
+
+func TestClockProCacheScanResistance(t *testing.T) {
+	clockPro := hotSetSurvivors(zwis.NewClockProCache(100), 50, 1000)
+	if clockPro < 45 {
+		t.Errorf("Expected most hot keys to survive the scan in CLOCK-Pro, got %d/50", clockPro)
+	}
+}
+
+func TestClockProCacheBeatsLRUOnZipf(t *testing.T) {
+	lru := zipfHitRatio(zwis.NewLRUCache(1000), 1.01, 100000, 200000)
+	clockPro := zipfHitRatio(zwis.NewClockProCache(1000), 1.01, 100000, 200000)
+	if clockPro <= lru {
+		t.Errorf("Expected CLOCK-Pro hit ratio above LRU, got %.3f vs %.3f", clockPro, lru)
+	}
+	t.Logf("hit ratio: lru=%.3f clockpro=%.3f", lru, clockPro)
+}
+
+func TestClockProCacheConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewClockProCache(100)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key%d", (i*w)%200)
+				if _, ok := cache.Get(ctx, key); !ok {
+					cache.Set(ctx, key, i, 0)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := cache.Stats().Entries; n > 100 {
+		t.Errorf("Expected at most 100 entries, got %d", n)
+	}
+}
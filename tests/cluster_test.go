@@ -0,0 +1,327 @@
+package zwis_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/cluster"
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestRingDistribution(t *testing.T) {
+	nodes := []string{"http://a", "http://b", "http://c"}
+	ring := cluster.NewRing(0)
+	ring.Add(nodes...)
+
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[ring.Owner(fmt.Sprintf("key-%d", i))]++
+	}
+	for _, node := range nodes {
+		if counts[node] < 2000 || counts[node] > 4700 {
+			t.Errorf("%s owns %d of 10000 keys, want roughly a third", node, counts[node])
+		}
+	}
+
+	// Rings built in a different order agree on every key.
+	other := cluster.NewRing(0)
+	other.Add("http://c")
+	other.Add("http://b", "http://a")
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if ring.Owner(key) != other.Owner(key) {
+			t.Fatalf("rings disagree on the owner of %s", key)
+		}
+	}
+
+	// Removing a node only moves the keys it owned.
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = ring.Owner(key)
+	}
+	ring.Remove("http://b")
+	for key, owner := range before {
+		if got := ring.Owner(key); owner != "http://b" && got != owner {
+			t.Errorf("%s moved from %s to %s", key, owner, got)
+		} else if got == "http://b" {
+			t.Errorf("%s is still owned by the removed node", key)
+		}
+	}
+
+	if got := cluster.NewRing(10).Owner("k"); got != "" {
+		t.Errorf("Owner on an empty ring = %q", got)
+	}
+}
+
+type testNode struct {
+	node  *cluster.Node
+	local *zwis.LRUCache
+	srv   *httptest.Server
+}
+
+// startCluster starts n nodes served by in-process HTTP servers, all aware
+// of each other.
+func startCluster(t *testing.T, n int, opts ...cluster.Option) []testNode {
+	t.Helper()
+	nodes := make([]testNode, n)
+	urls := make([]string, n)
+	for i := range nodes {
+		nodes[i] = startNode(t, opts...)
+		urls[i] = nodes[i].srv.URL
+	}
+	for _, tn := range nodes {
+		if err := tn.node.SetPeers(urls...); err != nil {
+			t.Fatalf("SetPeers: %v", err)
+		}
+	}
+	return nodes
+}
+
+func startNode(t *testing.T, opts ...cluster.Option) testNode {
+	t.Helper()
+	var tn testNode
+	tn.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tn.node.ServeHTTP(w, r)
+	}))
+	t.Cleanup(tn.srv.Close)
+	tn.local = zwis.NewLRUCache(1000)
+	node, err := cluster.NewNode(tn.srv.URL, tn.local, opts...)
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	tn.node = node
+	return tn
+}
+
+// ownerOf returns the test node that owns key.
+func ownerOf(nodes []testNode, key string) testNode {
+	owner := nodes[0].node.Owner(key)
+	for _, tn := range nodes {
+		if tn.srv.URL == owner {
+			return tn
+		}
+	}
+	panic("no owner for " + key)
+}
+
+func TestClusterRoutesKeysToOwners(t *testing.T) {
+	nodes := startCluster(t, 3)
+	ctx := context.Background()
+
+	keys := make([]string, 30)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user:%d", i)
+		if err := nodes[i%3].node.Set(ctx, keys[i], i, 0); err != nil {
+			t.Fatalf("Set(%s): %v", keys[i], err)
+		}
+	}
+
+	for i, key := range keys {
+		owner := ownerOf(nodes, key)
+		for _, tn := range nodes {
+			_, stored := tn.local.Get(ctx, key)
+			if stored != (tn.srv.URL == owner.srv.URL) {
+				t.Errorf("%s stored on %s: %v; owner is %s", key, tn.srv.URL, stored, owner.srv.URL)
+			}
+			if v, ok := tn.node.Get(ctx, key); !ok || v != i {
+				t.Errorf("Get(%s) on %s = %v, %v; want %d", key, tn.srv.URL, v, ok, i)
+			}
+		}
+	}
+	if _, ok := nodes[0].node.Get(ctx, "missing"); ok {
+		t.Error("Get(missing) found a value")
+	}
+
+	// The second read of another node's key is served from the hot cache.
+	var stats cluster.Stats
+	for _, tn := range nodes {
+		for _, key := range keys {
+			tn.node.Get(ctx, key)
+		}
+		s := tn.node.Stats()
+		stats.LocalHits += s.LocalHits
+		stats.HotHits += s.HotHits
+		stats.PeerHits += s.PeerHits
+	}
+	if stats.LocalHits != 60 || stats.PeerHits != 60 || stats.HotHits != 60 {
+		t.Errorf("Stats = %+v, want 60 local, peer and hot hits", stats)
+	}
+
+	key := keys[0]
+	var other testNode
+	for _, tn := range nodes {
+		if tn.srv.URL != ownerOf(nodes, key).srv.URL {
+			other = tn
+		}
+	}
+	if err := other.node.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := ownerOf(nodes, key).local.Get(ctx, key); ok {
+		t.Error("Delete through another node left the key on its owner")
+	}
+	if _, ok := other.node.Get(ctx, key); ok {
+		t.Error("Delete left a hot copy on the deleting node")
+	}
+
+	// Deleting the empty key through a node that does not own it only
+	// deletes that key.
+	emptyOwner := ownerOf(nodes, "")
+	for _, tn := range nodes {
+		if tn.srv.URL != emptyOwner.srv.URL {
+			other = tn
+		}
+	}
+	other.node.Set(ctx, "", "empty", 0)
+	if err := other.node.Delete(ctx, ""); err != nil {
+		t.Fatalf("Delete(\"\"): %v", err)
+	}
+	if _, ok := emptyOwner.local.Get(ctx, ""); ok {
+		t.Error("Delete(\"\") left the empty key on its owner")
+	}
+	if n := emptyOwner.local.Stats().Entries; n == 0 {
+		t.Error("Delete(\"\") through another node flushed the owner")
+	}
+
+	if err := nodes[0].node.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	for _, tn := range nodes {
+		if n := tn.local.Stats().Entries; n != 0 {
+			t.Errorf("%s holds %d entries after Flush", tn.srv.URL, n)
+		}
+		if _, ok := tn.node.Get(ctx, keys[1]); ok {
+			t.Errorf("%s still serves %s after Flush", tn.srv.URL, keys[1])
+		}
+	}
+}
+
+func TestClusterHotTTL(t *testing.T) {
+	clock := zwistest.NewFakeClock(time.Now())
+	hot := zwis.NewLRUCache(10, zwis.WithClock(clock))
+	nodes := startCluster(t, 2)
+	reader := startNode(t, cluster.WithHotCache[interface{}](hot), cluster.WithHotTTL(time.Minute))
+	reader.node.SetPeers(nodes[0].srv.URL, nodes[1].srv.URL, reader.srv.URL)
+	for _, tn := range nodes {
+		tn.node.SetPeers(nodes[0].srv.URL, nodes[1].srv.URL, reader.srv.URL)
+	}
+	ctx := context.Background()
+
+	var key string
+	for i := 0; ; i++ {
+		key = fmt.Sprintf("k%d", i)
+		if reader.node.Owner(key) != reader.srv.URL {
+			break
+		}
+	}
+	owner := ownerOf(nodes, key)
+	owner.node.Set(ctx, key, "v1", 0)
+	if v, _ := reader.node.Get(ctx, key); v != "v1" {
+		t.Fatalf("Get = %v, want v1", v)
+	}
+
+	// The reader serves its hot copy until the hot TTL runs out.
+	owner.node.Set(ctx, key, "v2", 0)
+	if v, _ := reader.node.Get(ctx, key); v != "v1" {
+		t.Errorf("Get before the hot TTL = %v, want the hot copy v1", v)
+	}
+	clock.Advance(2 * time.Minute)
+	if v, _ := reader.node.Get(ctx, key); v != "v2" {
+		t.Errorf("Get after the hot TTL = %v, want v2", v)
+	}
+}
+
+func TestClusterMembershipChanges(t *testing.T) {
+	nodes := startCluster(t, 2)
+	ctx := context.Background()
+	joiner := startNode(t)
+
+	urls := []string{nodes[0].srv.URL, nodes[1].srv.URL, joiner.srv.URL}
+	for _, tn := range append(nodes, joiner) {
+		if err := tn.node.SetPeers(urls...); err != nil {
+			t.Fatalf("SetPeers: %v", err)
+		}
+	}
+	if got := joiner.node.Peers(); len(got) != 3 {
+		t.Errorf("Peers = %v, want all three nodes", got)
+	}
+
+	moved := 0
+	for i := 0; i < 60; i++ {
+		key := fmt.Sprintf("k%d", i)
+		if nodes[0].node.Owner(key) != joiner.node.Owner(key) {
+			t.Fatalf("nodes disagree on the owner of %s", key)
+		}
+		nodes[0].node.Set(ctx, key, i, 0)
+		if nodes[0].node.Owner(key) == joiner.srv.URL {
+			moved++
+			if _, ok := joiner.local.Get(ctx, key); !ok {
+				t.Errorf("%s was not stored on the node that joined", key)
+			}
+		}
+	}
+	if moved == 0 {
+		t.Error("the node that joined owns none of 60 keys")
+	}
+
+	// After the node leaves, its keys are owned by the others again.
+	urls = urls[:2]
+	for _, tn := range nodes {
+		tn.node.SetPeers(urls...)
+	}
+	for i := 0; i < 60; i++ {
+		if owner := nodes[1].node.Owner(fmt.Sprintf("k%d", i)); owner == joiner.srv.URL {
+			t.Fatalf("k%d is still owned by the node that left", i)
+		}
+	}
+}
+
+func TestClusterPeerErrors(t *testing.T) {
+	nodes := startCluster(t, 2)
+	ctx := context.Background()
+
+	var key string
+	for i := 0; ; i++ {
+		key = fmt.Sprintf("k%d", i)
+		if nodes[0].node.Owner(key) == nodes[1].srv.URL {
+			break
+		}
+	}
+	nodes[1].srv.Close()
+
+	if _, ok, err := nodes[0].node.Lookup(ctx, key); ok || err == nil {
+		t.Errorf("Lookup of a key on a stopped node = %v, %v; want an error", ok, err)
+	}
+	if _, ok := nodes[0].node.Get(ctx, key); ok {
+		t.Error("Get of a key on a stopped node found a value")
+	}
+	if err := nodes[0].node.Set(ctx, key, "v", 0); err == nil {
+		t.Error("Set of a key on a stopped node succeeded")
+	}
+	if stats := nodes[0].node.Stats(); stats.PeerErrors != 3 || stats.Misses != 2 {
+		t.Errorf("Stats = %+v, want 3 peer errors and 2 misses", stats)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := nodes[0].node.Lookup(cancelled, key); !errors.Is(err, context.Canceled) {
+		t.Errorf("Lookup with a cancelled context returned %v", err)
+	}
+
+	if _, err := cluster.NewNode("localhost:8080", zwis.NewLRUCache(10)); err == nil {
+		t.Error("NewNode accepted a URL without a scheme")
+	}
+	if err := nodes[0].node.SetPeers("not a url"); err == nil {
+		t.Error("SetPeers accepted an invalid URL")
+	}
+	if _, err := cluster.NewNode("http://a", zwis.NewLRUCache(10), cluster.WithCodec[string](zwis.GobCodec[string]{})); err == nil {
+		t.Error("NewNode accepted a codec for the wrong value type")
+	}
+}
@@ -0,0 +1,150 @@
+package zwis_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// byteWeigher weighs string values by their length.
+func byteWeigher(key string, value interface{}) int64 {
+	return int64(len(value.(string)))
+}
+
+func newCostCaches(maxCost int64, opts ...zwis.Option) map[string]zwis.CostCache {
+	opts = append(opts, zwis.WithMaxCost(maxCost))
+	return map[string]zwis.CostCache{
+		"memory": zwis.NewMemoryCache(opts...),
+		"lru":    zwis.NewLRUCache(0, opts...),
+		"lfu":    zwis.NewLFUCache(0, opts...),
+		"arc":    zwis.NewARCCache(1000, opts...),
+	}
+}
+
+func TestCostBudgetEvictsUntilItFits(t *testing.T) {
+	ctx := context.Background()
+	for name, cache := range newCostCaches(100, zwis.WithWeigher(byteWeigher)) {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				cache.Set(ctx, fmt.Sprintf("small%d", i), "0123456789", 0)
+			}
+			if cost := cache.Cost(); cost != 100 {
+				t.Fatalf("Expected cost 100, got %d", cost)
+			}
+
+			// A 60-byte value pushes out at least six small entries.
+			if err := cache.Set(ctx, "big", string(make([]byte, 60)), 0); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if cost := cache.Cost(); cost > 100 {
+				t.Errorf("Expected cost within budget, got %d", cost)
+			}
+			if _, ok := cache.Get(ctx, "big"); !ok {
+				t.Error("big should be cached")
+			}
+			if n := cache.(zwis.StatsProvider).Stats().Entries; n > 5 {
+				t.Errorf("Expected at most 5 entries, got %d", n)
+			}
+		})
+	}
+}
+
+func TestCostBudgetRejectsOversizedEntries(t *testing.T) {
+	ctx := context.Background()
+	for name, cache := range newCostCaches(100) {
+		t.Run(name, func(t *testing.T) {
+			cache.Set(ctx, "key1", "value1", 0)
+
+			err := cache.SetWithCost(ctx, "huge", "value", 101, 0)
+			if !errors.Is(err, zwis.ErrEntryTooLarge) {
+				t.Fatalf("Expected ErrEntryTooLarge, got %v", err)
+			}
+			if _, ok := cache.Get(ctx, "huge"); ok {
+				t.Error("huge should not have been stored")
+			}
+			if _, ok := cache.Get(ctx, "key1"); !ok {
+				t.Error("key1 should not have been evicted by a rejected entry")
+			}
+		})
+	}
+}
+
+func TestCostBudgetTracksUpdatesAndRemovals(t *testing.T) {
+	ctx := context.Background()
+	for name, cache := range newCostCaches(1000) {
+		t.Run(name, func(t *testing.T) {
+			cache.SetWithCost(ctx, "key1", "value1", 10, 0)
+			cache.SetWithCost(ctx, "key2", "value2", 20, 0)
+			cache.SetWithCost(ctx, "key1", "value1", 50, 0)
+			if cost := cache.Cost(); cost != 70 {
+				t.Errorf("Expected cost 70 after update, got %d", cost)
+			}
+
+			cache.Delete(ctx, "key2")
+			if cost := cache.Cost(); cost != 50 {
+				t.Errorf("Expected cost 50 after delete, got %d", cost)
+			}
+
+			cache.Flush(ctx)
+			if cost := cache.Cost(); cost != 0 {
+				t.Errorf("Expected cost 0 after flush, got %d", cost)
+			}
+		})
+	}
+}
+
+func TestCostBudgetKeepsGrownEntry(t *testing.T) {
+	ctx := context.Background()
+	for name, cache := range newCostCaches(100) {
+		t.Run(name, func(t *testing.T) {
+			cache.SetWithCost(ctx, "key1", "value1", 40, 0)
+			cache.SetWithCost(ctx, "key2", "value2", 40, 0)
+
+			// Growing key2 evicts key1 rather than key2 itself.
+			cache.SetWithCost(ctx, "key2", "value2", 90, 0)
+			if _, ok := cache.Get(ctx, "key2"); !ok {
+				t.Error("key2 should still be cached")
+			}
+			if _, ok := cache.Get(ctx, "key1"); ok {
+				t.Error("key1 should have been evicted")
+			}
+			if cost := cache.Cost(); cost != 90 {
+				t.Errorf("Expected cost 90, got %d", cost)
+			}
+		})
+	}
+}
+
+func TestCostBudgetRestore(t *testing.T) {
+	ctx := context.Background()
+	source := zwis.NewLRUCache(10)
+	for i := 0; i < 10; i++ {
+		source.Set(ctx, fmt.Sprintf("key%d", i), "0123456789", 0)
+	}
+	var buf bytes.Buffer
+	if err := source.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	restored := zwis.NewLRUCache(0, zwis.WithMaxCost(35), zwis.WithWeigher(byteWeigher))
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if cost := restored.Cost(); cost != 30 {
+		t.Errorf("Expected the three most recent entries to be restored, got cost %d", cost)
+	}
+	if _, ok := restored.Get(ctx, "key9"); !ok {
+		t.Error("key9 should have been restored")
+	}
+}
+
+func TestWeigherTypeMismatch(t *testing.T) {
+	cache := zwis.NewLRUCache(10, zwis.WithWeigher(func(key int, value string) int64 { return 1 }))
+	if err := cache.Set(context.Background(), "key", "value", 0); err == nil {
+		t.Error("Expected an error for a weigher of the wrong type")
+	}
+}
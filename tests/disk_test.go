@@ -0,0 +1,297 @@
+package zwis_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestDiskCache(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache, err := zwis.NewDiskCache(t.TempDir(), 0, zwis.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewDiskCache: %v", err)
+	}
+	defer cache.Close()
+
+	cache.Set(ctx, "key1", "value1", 0)
+	cache.Set(ctx, "key2", 42, 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+	if v, ok := cache.Get(ctx, "key2"); !ok || v != 42 {
+		t.Errorf("Expected 42, got %v", v)
+	}
+
+	cache.Set(ctx, "key1", "new_value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "new_value1" {
+		t.Errorf("Expected new_value1, got %v", v)
+	}
+
+	cache.Set(ctx, "key3", "value3", 20*time.Millisecond)
+	clock.Advance(30 * time.Millisecond)
+	if _, ok := cache.Get(ctx, "key3"); ok {
+		t.Error("key3 should have expired")
+	}
+
+	cache.Delete(ctx, "key2")
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have been deleted")
+	}
+
+	cache.Flush(ctx)
+	if _, ok := cache.Get(ctx, "key1"); ok {
+		t.Error("Cache should be empty after Flush")
+	}
+	if size := cache.DiskSize(); size != 0 {
+		t.Errorf("Expected an empty log after Flush, got %d bytes", size)
+	}
+}
+
+func TestDiskCacheReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	clock := zwistest.NewFakeClock(time.Now())
+	cache, _ := zwis.NewDiskCache(dir, 0, zwis.WithClock(clock))
+	cache.Set(ctx, "kept", "value", 0)
+	cache.Set(ctx, "deleted", "value", 0)
+	cache.Set(ctx, "expiring", "value", 20*time.Millisecond)
+	cache.Delete(ctx, "deleted")
+	cache.Close()
+
+	clock.Advance(30 * time.Millisecond)
+
+	cache, err := zwis.NewDiskCache(dir, 0, zwis.WithClock(clock))
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer cache.Close()
+
+	if v, ok := cache.Get(ctx, "kept"); !ok || v != "value" {
+		t.Errorf("Expected kept to survive a reopen, got %v", v)
+	}
+	for _, key := range []string{"deleted", "expiring"} {
+		if _, ok := cache.Get(ctx, key); ok {
+			t.Errorf("%s should not survive a reopen", key)
+		}
+	}
+}
+
+func TestDiskCacheRecoversTornTail(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	cache, _ := zwis.NewDiskCache(dir, 0)
+	cache.Set(ctx, "a", "1", 0)
+	cache.Set(ctx, "b", "2", 0)
+	size := cache.DiskSize()
+	cache.Close()
+
+	// Simulate a crash in the middle of writing a record.
+	f, _ := os.OpenFile(filepath.Join(dir, "zwis.log"), os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{1, 2, 3, 4, 1, 0, 0})
+	f.Close()
+
+	cache, err := zwis.NewDiskCache(dir, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer cache.Close()
+
+	if got := cache.DiskSize(); got != size {
+		t.Errorf("Expected the torn record to be truncated to %d bytes, got %d", size, got)
+	}
+	for key, want := range map[string]string{"a": "1", "b": "2"} {
+		if v, ok := cache.Get(ctx, key); !ok || v != want {
+			t.Errorf("Expected %s for %s, got %v", want, key, v)
+		}
+	}
+
+	// New writes land after the recovered records.
+	cache.Set(ctx, "c", "3", 0)
+	if v, ok := cache.Get(ctx, "c"); !ok || v != "3" {
+		t.Errorf("Expected 3, got %v", v)
+	}
+}
+
+func TestDiskCacheCompaction(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	cache, _ := zwis.NewDiskCache(dir, 0)
+	value := strings.Repeat("x", 1024)
+	for i := 0; i < 1000; i++ {
+		cache.Set(ctx, "key", value, 0)
+	}
+
+	// A thousand overwrites of a 1 KiB value would be ~1 MiB without
+	// compaction.
+	if size := cache.DiskSize(); size > 200<<10 {
+		t.Errorf("Expected automatic compaction to bound the log, got %d bytes", size)
+	}
+
+	if err := cache.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if size := cache.DiskSize(); size > 2<<10 {
+		t.Errorf("Expected a single live record after Compact, got %d bytes", size)
+	}
+	cache.Close()
+
+	cache, _ = zwis.NewDiskCache(dir, 0)
+	defer cache.Close()
+	if v, ok := cache.Get(ctx, "key"); !ok || v != value {
+		t.Error("Expected the value to survive compaction and reopen")
+	}
+}
+
+func TestDiskCacheMaxSizeEvictsLRU(t *testing.T) {
+	ctx := context.Background()
+	value := strings.Repeat("x", 100)
+
+	cache, _ := zwis.NewDiskCache(t.TempDir(), 0, zwis.WithMaxDiskSize(500))
+	defer cache.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		cache.Set(ctx, key, value, 0)
+	}
+	cache.Get(ctx, "a")
+	cache.Set(ctx, "d", value, 0)
+
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Error("b should have been evicted as the least recently used entry")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := cache.Get(ctx, key); !ok {
+			t.Errorf("%s should still be in the cache", key)
+		}
+	}
+
+	if err := cache.Set(ctx, "huge", strings.Repeat("x", 1000), 0); err != zwis.ErrEntryTooLarge {
+		t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+	}
+}
+
+func TestDiskCacheMaxSizeBoundsTheLog(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	value := strings.Repeat("x", 100)
+
+	cache, _ := zwis.NewDiskCache(dir, 0, zwis.WithMaxDiskSize(4<<10))
+	for i := 0; i < 1000; i++ {
+		cache.Set(ctx, fmt.Sprintf("key%d", i%50), value, 0)
+		if i%3 == 0 {
+			cache.Delete(ctx, fmt.Sprintf("key%d", (i+7)%50))
+		}
+		if size := cache.DiskSize(); size > 4<<10 {
+			t.Fatalf("log grew to %d bytes after %d writes, limit is %d", size, i+1, 4<<10)
+		}
+	}
+	if v, ok := cache.Get(ctx, "key49"); !ok || v != value {
+		t.Errorf("Expected the latest write to be kept, got %v", v)
+	}
+	cache.Close()
+
+	// A log written without a limit is compacted down to one on reopen.
+	cache, _ = zwis.NewDiskCache(dir, 0)
+	for i := 0; i < 100; i++ {
+		cache.Set(ctx, fmt.Sprintf("key%d", i), value, 0)
+	}
+	cache.Close()
+	cache, _ = zwis.NewDiskCache(dir, 0, zwis.WithMaxDiskSize(4<<10))
+	defer cache.Close()
+	if size := cache.DiskSize(); size > 4<<10 {
+		t.Errorf("Expected reopening with a limit to shrink the log, got %d bytes", size)
+	}
+}
+
+func TestDiskCacheRejectsOversizedRecordHeader(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, _ := zwis.NewDiskCache(dir, 0)
+	cache.Set(context.Background(), "a", "1", 0)
+	size := cache.DiskSize()
+	cache.Close()
+
+	// A corrupt header claiming a 1 GiB value must not be allocated for.
+	header := make([]byte, 21)
+	header[4] = 1
+	binary.LittleEndian.PutUint32(header[13:], 1)
+	binary.LittleEndian.PutUint32(header[17:], 1<<30)
+	f, _ := os.OpenFile(filepath.Join(dir, "zwis.log"), os.O_WRONLY|os.O_APPEND, 0)
+	f.Write(append(header, 'k', 'v'))
+	f.Close()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	cache, err := zwis.NewDiskCache(dir, 0)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer cache.Close()
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("reopen allocated %d bytes for a corrupt header", allocated)
+	}
+	if got := cache.DiskSize(); got != size {
+		t.Errorf("Expected the corrupt record to be truncated to %d bytes, got %d", size, got)
+	}
+}
+
+type jsonCodec[V any] struct{}
+
+func (jsonCodec[V]) Encode(value V) ([]byte, error) { return json.Marshal(value) }
+
+func (jsonCodec[V]) Decode(data []byte) (V, error) {
+	var value V
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+func TestDiskCacheFactoryAndCodec(t *testing.T) {
+	ctx := context.Background()
+
+	cache, err := zwis.NewCache(zwis.DiskCacheType, zwis.WithCapacity(10), zwis.WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	cache.Set(ctx, "key", "value", 0)
+	if v, ok := cache.Get(ctx, "key"); !ok || v != "value" {
+		t.Errorf("Expected value, got %v", v)
+	}
+	cache.(*zwis.DiskCache).Close()
+
+	if _, err := zwis.NewCache(zwis.DiskCacheType, zwis.WithCapacity(10)); err == nil {
+		t.Error("Expected an error without a directory")
+	}
+	if _, err := zwis.NewTypedCache[int, string](zwis.DiskCacheType, zwis.WithCapacity(10), zwis.WithDirectory(t.TempDir())); err == nil {
+		t.Error("Expected an error for non-string keys")
+	}
+
+	users, err := zwis.NewTypedDiskCache[user](t.TempDir(), 0, zwis.WithCodec[user](jsonCodec[user]{}))
+	if err != nil {
+		t.Fatalf("NewTypedDiskCache: %v", err)
+	}
+	defer users.Close()
+	users.Set(ctx, "ada", user{ID: 1, Name: "Ada"}, 0)
+	if u, ok := users.Get(ctx, "ada"); !ok || u.Name != "Ada" {
+		t.Errorf("Expected Ada, got %+v", u)
+	}
+
+	if _, err := zwis.NewTypedDiskCache[int](t.TempDir(), 0, zwis.WithCodec[user](jsonCodec[user]{})); err == nil {
+		t.Error("Expected an error for a mismatched codec")
+	}
+}
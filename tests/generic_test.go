@@ -0,0 +1,50 @@
+package zwis_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+func TestTypedCaches(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range []zwis.CacheType{zwis.MemoryCacheType, zwis.LRUCacheType, zwis.LFUCacheType, zwis.ARCCacheType} {
+		t.Run(string(cacheType), func(t *testing.T) {
+			cache, err := zwis.NewTypedCache[int, user](cacheType, zwis.WithCapacity(10))
+			if err != nil {
+				t.Fatalf("NewTypedCache: %v", err)
+			}
+
+			cache.Set(ctx, 1, user{ID: 1, Name: "ada"}, 0)
+
+			u, ok := cache.Get(ctx, 1)
+			if !ok || u.Name != "ada" {
+				t.Errorf("Expected ada, got %+v", u)
+			}
+
+			cache.Delete(ctx, 1)
+			if u, ok := cache.Get(ctx, 1); ok || u != (user{}) {
+				t.Errorf("Expected zero value after delete, got %+v", u)
+			}
+		})
+	}
+}
+
+func TestNonGenericAdapters(t *testing.T) {
+	var _ zwis.Cache = zwis.NewMemoryCache()
+	var _ zwis.Cache = zwis.NewLRUCache(1)
+	var _ zwis.Cache = zwis.NewLFUCache(1)
+	var _ zwis.Cache = zwis.NewARCCache(1)
+	var _ zwis.TypedCache[string, interface{}] = zwis.NewLRUCache(1)
+
+	if _, err := zwis.NewTypedCache[string, int]("unknown", zwis.WithCapacity(1)); err == nil {
+		t.Error("Expected error for unknown cache type")
+	}
+}
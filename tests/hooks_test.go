@@ -0,0 +1,110 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+type evictionRecord struct {
+	key    string
+	value  interface{}
+	reason zwis.EvictReason
+}
+
+func TestEvictionCallbacks(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range []zwis.CacheType{zwis.LRUCacheType, zwis.LFUCacheType, zwis.ARCCacheType} {
+		t.Run(string(cacheType), func(t *testing.T) {
+			clock := zwistest.NewFakeClock(time.Now())
+			cache, _ := zwis.NewCache(cacheType, zwis.WithCapacity(2), zwis.WithClock(clock))
+
+			var mu sync.Mutex
+			var got []evictionRecord
+			var expired []string
+			notifier := cache.(zwis.EvictionNotifier)
+			notifier.OnEvict(func(key string, value interface{}, reason zwis.EvictReason) {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, evictionRecord{key, value, reason})
+			})
+			notifier.OnExpire(func(key string, value interface{}) {
+				expired = append(expired, key)
+			})
+
+			cache.Set(ctx, "a", 1, 0)
+			cache.Set(ctx, "a", 2, 0)
+			cache.Set(ctx, "b", 3, 10*time.Millisecond)
+			clock.Advance(20 * time.Millisecond)
+			cache.Get(ctx, "b")
+			cache.Set(ctx, "c", 4, 0)
+			cache.Set(ctx, "d", 5, 0)
+			cache.Delete(ctx, "d")
+			cache.Flush(ctx)
+
+			// Which of a and c is dropped for capacity depends on the policy;
+			// the other one goes with the Flush.
+			if len(got) != 5 {
+				t.Fatalf("Expected 5 evictions, got %v", got)
+			}
+			if got[2].key == "c" {
+				got[2], got[4] = got[4], got[2]
+				got[2].reason, got[4].reason = got[4].reason, got[2].reason
+			}
+			want := []evictionRecord{
+				{"a", 1, zwis.EvictReasonReplaced},
+				{"b", 3, zwis.EvictReasonExpired},
+				{"a", 2, zwis.EvictReasonCapacity},
+				{"d", 5, zwis.EvictReasonDeleted},
+				{"c", 4, zwis.EvictReasonFlushed},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+			if !reflect.DeepEqual(expired, []string{"b"}) {
+				t.Errorf("Expected OnExpire for b only, got %v", expired)
+			}
+		})
+	}
+}
+
+func TestEvictionCallbackCanUseCache(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range []zwis.CacheType{zwis.MemoryCacheType, zwis.LRUCacheType, zwis.LFUCacheType, zwis.ARCCacheType} {
+		t.Run(string(cacheType), func(t *testing.T) {
+			cache, _ := zwis.NewCache(cacheType, zwis.WithCapacity(1))
+
+			// Re-inserting evicted values under a new key would deadlock if
+			// callbacks ran with the cache lock held.
+			cache.(zwis.EvictionNotifier).OnEvict(func(key string, value interface{}, reason zwis.EvictReason) {
+				if reason == zwis.EvictReasonDeleted {
+					cache.Set(ctx, "graveyard", fmt.Sprint(key, "=", value), 0)
+				}
+			})
+
+			cache.Set(ctx, "a", 1, 0)
+			cache.Delete(ctx, "a")
+
+			if v, ok := cache.Get(ctx, "graveyard"); !ok || v != "a=1" {
+				t.Errorf("Expected a=1 in graveyard, got %v", v)
+			}
+		})
+	}
+}
+
+func TestEvictReasonString(t *testing.T) {
+	if s := zwis.EvictReasonCapacity.String(); s != "capacity" {
+		t.Errorf("Expected capacity, got %s", s)
+	}
+	if s := zwis.EvictReason(0).String(); s != "unknown" {
+		t.Errorf("Expected unknown, got %s", s)
+	}
+}
@@ -0,0 +1,276 @@
+package zwis_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/httpapi"
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+// newHTTPAPIServer serves an LRU cache over HTTP until the test ends.
+func newHTTPAPIServer(t *testing.T, opts ...httpapi.Option) (*httptest.Server, zwis.Cache, *zwistest.FakeClock) {
+	t.Helper()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewLRUCache(100, zwis.WithClock(clock))
+	srv := httptest.NewServer(httpapi.NewHandler(cache, opts...))
+	t.Cleanup(srv.Close)
+	return srv, cache, clock
+}
+
+func newHTTPAPIClient(t *testing.T, baseURL string) *httpapi.Client {
+	t.Helper()
+	client, err := httpapi.NewClient(baseURL)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+// TestHTTPAPIClientMatchesLocalCache runs the same code against a local
+// LRU cache and a remote one through the client.
+func TestHTTPAPIClientMatchesLocalCache(t *testing.T) {
+	srv, _, remoteClock := newHTTPAPIServer(t)
+	localClock := zwistest.NewFakeClock(time.Now())
+
+	caches := map[string]struct {
+		cache zwis.Cache
+		clock *zwistest.FakeClock
+	}{
+		"local":  {zwis.NewLRUCache(100, zwis.WithClock(localClock)), localClock},
+		"remote": {newHTTPAPIClient(t, srv.URL), remoteClock},
+	}
+	for name, c := range caches {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			cache := c.cache
+
+			if err := cache.Set(ctx, "str", "hello", 0); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			cache.Set(ctx, "int", 42, 0)
+			cache.Set(ctx, "short", "soon gone", time.Second)
+			cache.Set(ctx, "a/b c", "odd key", 0)
+
+			if v, ok := cache.Get(ctx, "str"); !ok || v != "hello" {
+				t.Errorf("Get(str) = %v, %v; want hello", v, ok)
+			}
+			if v, ok := cache.Get(ctx, "int"); !ok || v != 42 {
+				t.Errorf("Get(int) = %v (%T), %v; want 42", v, v, ok)
+			}
+			if v, ok := cache.Get(ctx, "a/b c"); !ok || v != "odd key" {
+				t.Errorf("Get(a/b c) = %v, %v; want odd key", v, ok)
+			}
+			if _, ok := cache.Get(ctx, "missing"); ok {
+				t.Error("Get(missing) found a value")
+			}
+
+			c.clock.Advance(2 * time.Second)
+			if _, ok := cache.Get(ctx, "short"); ok {
+				t.Error("short should have expired")
+			}
+
+			// The empty key is a key like any other, and deleting it leaves
+			// the rest of the cache alone.
+			cache.Set(ctx, "", "empty key", 0)
+			if v, ok := cache.Get(ctx, ""); !ok || v != "empty key" {
+				t.Errorf("Get(\"\") = %v, %v; want empty key", v, ok)
+			}
+			if err := cache.Delete(ctx, ""); err != nil {
+				t.Fatalf("Delete(\"\"): %v", err)
+			}
+			if _, ok := cache.Get(ctx, ""); ok {
+				t.Error("the empty key should have been deleted")
+			}
+			if _, ok := cache.Get(ctx, "int"); !ok {
+				t.Error("Delete(\"\") removed other keys")
+			}
+
+			if err := cache.Delete(ctx, "str"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, ok := cache.Get(ctx, "str"); ok {
+				t.Error("str should have been deleted")
+			}
+			if err := cache.Flush(ctx); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+			if _, ok := cache.Get(ctx, "int"); ok {
+				t.Error("int should have been flushed")
+			}
+		})
+	}
+}
+
+func TestHTTPAPIClientBatchAndStats(t *testing.T) {
+	srv, _, clock := newHTTPAPIServer(t)
+	client := newHTTPAPIClient(t, srv.URL)
+	ctx := context.Background()
+
+	err := client.SetMany(ctx, map[string]zwis.Item{
+		"a":     {Value: "1"},
+		"b":     {Value: 2},
+		"short": {Value: "3", TTL: time.Second},
+	})
+	if err != nil {
+		t.Fatalf("SetMany: %v", err)
+	}
+	clock.Advance(2 * time.Second)
+	got := client.GetMany(ctx, []string{"a", "b", "short", "missing"})
+	if len(got) != 2 || got["a"] != "1" || got["b"] != 2 {
+		t.Errorf("GetMany = %v, want a=1 b=2", got)
+	}
+	if err := client.DeleteMany(ctx, []string{"a", "missing"}); err != nil {
+		t.Fatalf("DeleteMany: %v", err)
+	}
+	if _, ok := client.Get(ctx, "a"); ok {
+		t.Error("a should have been deleted")
+	}
+
+	stats := client.Stats()
+	if stats.Entries != 1 || stats.Hits == 0 || stats.Misses == 0 {
+		t.Errorf("Stats = %+v, want 1 entry with hits and misses", stats)
+	}
+	client.ResetStats()
+	if stats := client.Stats(); stats.Hits != 0 {
+		t.Errorf("Stats after ResetStats = %+v", stats)
+	}
+}
+
+func TestHTTPAPIClientErrors(t *testing.T) {
+	srv, _, _ := newHTTPAPIServer(t, httpapi.WithMaxValueSize(100))
+	client := newHTTPAPIClient(t, srv.URL)
+
+	var serr *httpapi.StatusError
+	err := client.Set(context.Background(), "big", strings.Repeat("x", 200), 0)
+	if !errors.As(err, &serr) || serr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Set of a large value returned %v, want a 413 StatusError", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := client.Lookup(ctx, "k"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Lookup with a cancelled context returned %v, want context.Canceled", err)
+	}
+
+	if _, err := httpapi.NewClient("localhost:8080"); err == nil {
+		t.Error("NewClient accepted a URL without a scheme")
+	}
+	if _, err := httpapi.NewClient(srv.URL, httpapi.WithCodec[string](zwis.GobCodec[string]{})); err == nil {
+		t.Error("NewClient accepted a codec for the wrong value type")
+	}
+}
+
+// request sends a raw request to the server and returns the status and
+// body.
+func request(t *testing.T, method, url string, header http.Header, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	return res.StatusCode, string(data)
+}
+
+func TestHTTPAPIEndpoints(t *testing.T) {
+	srv, cache, clock := newHTTPAPIServer(t)
+	ctx := context.Background()
+
+	expect := func(method, path string, header http.Header, body string, wantCode int, wantBody string) {
+		t.Helper()
+		code, got := request(t, method, srv.URL+path, header, body)
+		if code != wantCode || (wantBody != "" && strings.TrimSpace(got) != wantBody) {
+			t.Errorf("%s %s = %d %q, want %d %q", method, path, code, got, wantCode, wantBody)
+		}
+	}
+
+	expect("PUT", "/keys/k", nil, "raw bytes", http.StatusNoContent, "")
+	expect("GET", "/keys/k", nil, "", http.StatusOK, "raw bytes")
+	expect("GET", "/keys/missing", nil, "", http.StatusNotFound, `{"error":"key not found"}`)
+
+	expect("PUT", "/keys/header", http.Header{"X-Zwis-Ttl": {"1m"}}, "h", http.StatusNoContent, "")
+	expect("PUT", "/keys/query?ttl=10", nil, "q", http.StatusNoContent, "")
+	expect("PUT", "/keys/bad?ttl=soon", nil, "b", http.StatusBadRequest, `{"error":"invalid ttl \"soon\""}`)
+	clock.Advance(11 * time.Second)
+	expect("GET", "/keys/query", nil, "", http.StatusNotFound, "")
+	expect("GET", "/keys/header", nil, "", http.StatusOK, "h")
+
+	// Values stored by Go code are served as strings or JSON.
+	cache.Set(ctx, "go-string", "s", 0)
+	cache.Set(ctx, "go-struct", map[string]int{"n": 1}, 0)
+	expect("GET", "/keys/go-string", nil, "", http.StatusOK, "s")
+	expect("GET", "/keys/go-struct", nil, "", http.StatusOK, `{"n":1}`)
+
+	expect("PUT", "/keys/a%2Fb", nil, "slash", http.StatusNoContent, "")
+	if v, ok := cache.Get(ctx, "a/b"); !ok || string(v.([]byte)) != "slash" {
+		t.Errorf("escaped key stored as %v, %v; want a/b", v, ok)
+	}
+
+	expect("POST", "/bulk/set", nil, `{"items":{"x":{"value":"MQ=="},"y":{"value":"Mg==","ttl":"1s"}}}`, http.StatusNoContent, "")
+	expect("POST", "/bulk/get", nil, `{"keys":["x","y","missing"]}`, http.StatusOK, `{"values":{"x":"MQ==","y":"Mg=="}}`)
+	expect("POST", "/bulk/delete", nil, `{"keys":["x"]}`, http.StatusNoContent, "")
+	expect("POST", "/bulk/get", nil, `{"keys":["x"]}`, http.StatusOK, `{"values":{}}`)
+	expect("POST", "/bulk/get", nil, `not json`, http.StatusBadRequest, "")
+
+	code, body := request(t, "GET", srv.URL+"/stats", nil, "")
+	var stats map[string]float64
+	if err := json.Unmarshal([]byte(body), &stats); code != http.StatusOK || err != nil || stats["entries"] == 0 {
+		t.Errorf("GET /stats = %d %s", code, body)
+	}
+
+	expect("DELETE", "/keys/k", nil, "", http.StatusNoContent, "")
+	expect("GET", "/keys/k", nil, "", http.StatusNotFound, "")
+	expect("DELETE", "/keys", nil, "", http.StatusNoContent, "")
+	expect("GET", "/keys/header", nil, "", http.StatusNotFound, "")
+
+	expect("POST", "/keys/k", nil, "", http.StatusMethodNotAllowed, "")
+	expect("GET", "/bulk/get", nil, "", http.StatusMethodNotAllowed, "")
+	expect("GET", "/nope", nil, "", http.StatusNotFound, "")
+}
+
+// TestHTTPAPISharesKeysWithServer serves one cache over both RESP and HTTP,
+// as zwis-server does.
+func TestHTTPAPISharesKeysWithServer(t *testing.T) {
+	srv, cache, clock := newHTTPAPIServer(t)
+	conn := dialServer(t, startServer(t, cache, clock))
+
+	expectString(t, conn, "OK", "SET", "k", "v", "EX", "10")
+	code, body := request(t, "GET", srv.URL+"/keys/k", nil, "")
+	if code != http.StatusOK || body != "v" {
+		t.Errorf("GET /keys/k = %d %q, want 200 v", code, body)
+	}
+	clock.Advance(11 * time.Second)
+	if code, _ := request(t, "GET", srv.URL+"/keys/k", nil, ""); code != http.StatusNotFound {
+		t.Errorf("GET /keys/k after the key expired = %d, want 404", code)
+	}
+
+	// Raw values need a client that does not decode them.
+	request(t, "PUT", srv.URL+"/keys/raw", nil, "from curl")
+	if _, ok, err := newHTTPAPIClient(t, srv.URL).Lookup(context.Background(), "raw"); ok || err == nil {
+		t.Errorf("gob client Lookup of a raw value = %v, %v; want a decoding error", ok, err)
+	}
+	raw, err := httpapi.NewTypedClient[[]byte](srv.URL, httpapi.WithCodec[[]byte](httpapi.BytesCodec{}))
+	if err != nil {
+		t.Fatalf("NewTypedClient: %v", err)
+	}
+	if v, ok := raw.Get(context.Background(), "raw"); !ok || string(v) != "from curl" {
+		t.Errorf("raw client Get = %q, %v", v, ok)
+	}
+}
@@ -0,0 +1,77 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestJanitorRemovesExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range policyCacheTypes {
+		t.Run(string(cacheType), func(t *testing.T) {
+			clock := zwistest.NewFakeClock(time.Now())
+			cache, _ := zwis.NewCache(cacheType,
+				zwis.WithCapacity(100),
+				zwis.WithJanitorInterval(10*time.Millisecond),
+				zwis.WithClock(clock),
+			)
+			defer cache.(io.Closer).Close()
+
+			for i := 0; i < 10; i++ {
+				cache.Set(ctx, fmt.Sprintf("short%d", i), i, 20*time.Millisecond)
+			}
+			cache.Set(ctx, "long", "long", time.Hour)
+			cache.Set(ctx, "forever", "forever", 0)
+
+			// Overwriting without a TTL must cancel the pending expiration.
+			cache.Set(ctx, "short0", "kept", 0)
+
+			clock.Advance(30 * time.Millisecond)
+
+			// The janitor, not Get, must have removed the expired keys.
+			stats := cache.(zwis.StatsProvider).Stats()
+			if stats.ExpiredEvictions != 9 || stats.Entries != 3 {
+				t.Fatalf("Expected 9 expirations and 3 entries, got %+v", stats)
+			}
+
+			for _, key := range []string{"short0", "long", "forever"} {
+				if _, ok := cache.Get(ctx, key); !ok {
+					t.Errorf("%s should still be in the cache", key)
+				}
+			}
+		})
+	}
+}
+
+func TestCloseWithoutJanitor(t *testing.T) {
+	cache := zwis.NewLRUCache(1)
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+func TestMemoryCacheGetDeletesExpired(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewMemoryCache(zwis.WithClock(clock))
+
+	cache.Set(ctx, "key", "value", 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+
+	if _, ok := cache.Get(ctx, "key"); ok {
+		t.Fatal("key should have expired")
+	}
+	if stats := cache.Stats(); stats.Entries != 0 || stats.ExpiredEvictions != 1 {
+		t.Errorf("Expected the expired entry to be removed, got %+v", stats)
+	}
+}
@@ -0,0 +1,48 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// BenchmarkLFUDynamicAging compares LFU with and without dynamic aging on a
+// Zipf workload over ten times more keys than fit. Dynamic aging spreads
+// priorities over many distinct buckets, and a hit moves its entry past every
+// bucket between its old and new priority.
+func BenchmarkLFUDynamicAging(b *testing.B) {
+	const capacity = 10_000
+	ctx := context.Background()
+	keys := benchKeys(10 * capacity)
+	zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.1, 1, uint64(len(keys)-1))
+	workload := make([]string, 1<<18)
+	for i := range workload {
+		workload[i] = keys[zipf.Uint64()]
+	}
+
+	for _, aged := range []bool{false, true} {
+		b.Run(fmt.Sprintf("dynamicAging=%v", aged), func(b *testing.B) {
+			var opts []zwis.Option
+			if aged {
+				opts = append(opts, zwis.WithDynamicAging())
+			}
+			cache := zwis.NewLFUCache(capacity, opts...)
+			access := func(key string) {
+				if _, ok := cache.Get(ctx, key); !ok {
+					cache.Set(ctx, key, key, 0)
+				}
+			}
+			for _, key := range workload {
+				access(key)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				access(workload[i%len(workload)])
+			}
+		})
+	}
+}
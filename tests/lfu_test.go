@@ -0,0 +1,174 @@
+package zwis_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestLFUCacheEvictsLeastFrequent(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewLFUCache(3)
+
+	cache.Set(ctx, "key1", 1, 0)
+	cache.Set(ctx, "key2", 2, 0)
+	cache.Set(ctx, "key3", 3, 0)
+	cache.Get(ctx, "key1")
+	cache.Get(ctx, "key1")
+	cache.Get(ctx, "key3")
+
+	cache.Set(ctx, "key4", 4, 0)
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have been evicted")
+	}
+	for _, key := range []string{"key1", "key3", "key4"} {
+		if _, ok := cache.Get(ctx, key); !ok {
+			t.Errorf("%s should still be cached", key)
+		}
+	}
+}
+
+func TestLFUCacheEvictsAfterDeletingLeastFrequent(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewLFUCache(2)
+
+	cache.Set(ctx, "key1", 1, 0)
+	cache.Set(ctx, "key2", 2, 0)
+	cache.Get(ctx, "key2")
+	cache.Delete(ctx, "key1")
+
+	// The bucket of the deleted key is gone; eviction must find the next one.
+	cache.Set(ctx, "key3", 3, 0)
+	cache.Set(ctx, "key4", 4, 0)
+	if n := cache.Stats().Entries; n != 2 {
+		t.Errorf("Expected 2 entries, got %d", n)
+	}
+	if _, ok := cache.Get(ctx, "key4"); !ok {
+		t.Error("key4 should be cached")
+	}
+}
+
+// stalePopularityRecovers makes one key very popular, then cycles through a
+// new working set that exactly fills the cache, and reports whether the
+// cache ended up holding the new working set.
+func stalePopularityRecovers(cache zwis.Cache, between func()) bool {
+	ctx := context.Background()
+	cache.Set(ctx, "stale", 0, 0)
+	for i := 0; i < 50; i++ {
+		cache.Get(ctx, "stale")
+	}
+
+	keys := []string{"a", "b", "c"}
+	for round := 0; round < 200; round++ {
+		for _, key := range keys {
+			if _, ok := cache.Get(ctx, key); !ok {
+				cache.Set(ctx, key, round, 0)
+			}
+		}
+		between()
+	}
+
+	for _, key := range keys {
+		if _, ok := cache.Get(ctx, key); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLFUCacheDecay(t *testing.T) {
+	noop := func() {}
+
+	if stalePopularityRecovers(zwis.NewLFUCache(3), noop) {
+		t.Error("Expected plain LFU to keep the stale key")
+	}
+	if !stalePopularityRecovers(zwis.NewLFUCache(3, zwis.WithFrequencyHalving(30)), noop) {
+		t.Error("Expected halving to let the new working set in")
+	}
+	if !stalePopularityRecovers(zwis.NewLFUCache(3, zwis.WithDynamicAging()), noop) {
+		t.Error("Expected dynamic aging to let the new working set in")
+	}
+
+	clock := zwistest.NewFakeClock(time.Now())
+	tick := func() { clock.Advance(time.Millisecond) }
+	if !stalePopularityRecovers(zwis.NewLFUCache(3, zwis.WithFrequencyHalfLife(5*time.Millisecond), zwis.WithClock(clock)), tick) {
+		t.Error("Expected a frequency half-life to let the new working set in")
+	}
+}
+
+func TestLFUCacheHalvingKeepsOrder(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewLFUCache(3, zwis.WithFrequencyHalving(10))
+
+	cache.Set(ctx, "key1", 1, 0)
+	cache.Set(ctx, "key2", 2, 0)
+	cache.Set(ctx, "key3", 3, 0)
+	for i := 0; i < 8; i++ {
+		cache.Get(ctx, "key1")
+	}
+	for i := 0; i < 4; i++ {
+		cache.Get(ctx, "key3")
+	}
+
+	// Halving scales every frequency, so key2 is still the least used.
+	cache.Set(ctx, "key4", 4, 0)
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have been evicted")
+	}
+}
+
+func TestLFUCacheDynamicAgingSnapshot(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewLFUCache(10, zwis.WithDynamicAging())
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key%d", i)
+		cache.Set(ctx, key, i, 0)
+		cache.Get(ctx, key)
+	}
+
+	var buf bytes.Buffer
+	if err := cache.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := zwis.NewLFUCache(10, zwis.WithDynamicAging())
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// A new key must be able to displace a restored one.
+	restored.Set(ctx, "new", 0, 0)
+	restored.Get(ctx, "new")
+	restored.Set(ctx, "newer", 0, 0)
+	if _, ok := restored.Get(ctx, "new"); !ok {
+		t.Error("new should have displaced a restored entry")
+	}
+}
+
+// TestLFUCacheDynamicAgingRekeysHits checks that a hit raises an entry's
+// priority to the current age plus its reference count, so an entry that
+// keeps being used survives a stream of new keys that have since aged past
+// its old priority.
+func TestLFUCacheDynamicAgingRekeysHits(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewLFUCache(2, zwis.WithDynamicAging())
+	cache.Set(ctx, "a", 0, 0)
+	for i := 0; i < 4; i++ {
+		cache.Get(ctx, "a")
+	}
+	cache.Set(ctx, "b", 0, 0)
+
+	for i := 1; i <= 7; i++ {
+		cache.Set(ctx, fmt.Sprintf("n%d", i), i, 0)
+		if i == 3 {
+			cache.Get(ctx, "a")
+		}
+	}
+	if _, ok := cache.Get(ctx, "a"); !ok {
+		t.Error("a was evicted although its hit re-keyed it above the new keys")
+	}
+}
@@ -0,0 +1,173 @@
+package zwis_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestLoadingCacheDeduplicatesLoads(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := zwis.NewCache(zwis.ARCCacheType, zwis.WithCapacity(10))
+	loading := zwis.NewLoadingCache(cache)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return "loaded " + key, 0, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 50)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := loading.GetOrLoad(ctx, "key", loader)
+			if err != nil {
+				t.Errorf("GetOrLoad: %v", err)
+			}
+			results[i] = v
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 loader call, got %d", n)
+	}
+	for _, v := range results {
+		if v != "loaded key" {
+			t.Fatalf("Expected every caller to get the loaded value, got %v", v)
+		}
+	}
+	if v, ok := cache.Get(ctx, "key"); !ok || v != "loaded key" {
+		t.Errorf("Expected the value to be stored in the wrapped cache, got %v", v)
+	}
+}
+
+func TestLoadingCacheContextCancellation(t *testing.T) {
+	cache := zwis.NewTypedLoadingCache[string, int](zwis.NewTypedLRUCache[string, int](10))
+
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (int, time.Duration, error) {
+		<-release
+		return 42, 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cache.GetOrLoad(ctx, "key", loader); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+
+	// The abandoned load still completes and populates the cache.
+	close(release)
+	v, err := cache.GetOrLoad(context.Background(), "key", loader)
+	if err != nil || v != 42 {
+		t.Errorf("Expected 42, got %v, %v", v, err)
+	}
+}
+
+func TestLoadingCacheLoaderTTL(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewTypedLoadingCache[string, int](zwis.NewTypedMemoryCache[string, int](zwis.WithClock(clock)))
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context, key string) (int, time.Duration, error) {
+		return int(calls.Add(1)), 20 * time.Millisecond, nil
+	}
+
+	cache.GetOrLoad(ctx, "key", loader)
+	if v, _ := cache.GetOrLoad(ctx, "key", loader); v != 1 {
+		t.Errorf("Expected cached value 1, got %d", v)
+	}
+
+	clock.Advance(30 * time.Millisecond)
+	if v, _ := cache.GetOrLoad(ctx, "key", loader); v != 2 {
+		t.Errorf("Expected reloaded value 2 after TTL, got %d", v)
+	}
+}
+
+func TestLoadingCacheNegativeTTL(t *testing.T) {
+	ctx := context.Background()
+	errBackend := errors.New("backend down")
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewTypedLoadingCache[string, int](
+		zwis.NewTypedLRUCache[string, int](10),
+		zwis.WithNegativeTTL(20*time.Millisecond),
+		zwis.WithClock(clock),
+	)
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context, key string) (int, time.Duration, error) {
+		if calls.Add(1) == 1 {
+			return 0, 0, errBackend
+		}
+		return 7, 0, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.GetOrLoad(ctx, "key", loader); !errors.Is(err, errBackend) {
+			t.Fatalf("Expected cached error, got %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected the error to be cached, got %d loader calls", n)
+	}
+
+	clock.Advance(30 * time.Millisecond)
+	if v, err := cache.GetOrLoad(ctx, "key", loader); err != nil || v != 7 {
+		t.Errorf("Expected 7 after the negative TTL, got %v, %v", v, err)
+	}
+}
+
+func TestLoadingCacheBoundsNegativeCache(t *testing.T) {
+	ctx := context.Background()
+	errBackend := errors.New("backend down")
+	cache := zwis.NewTypedLoadingCache[int, int](
+		zwis.NewTypedLRUCache[int, int](10),
+		zwis.WithNegativeTTL(time.Hour),
+	)
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context, key int) (int, time.Duration, error) {
+		calls.Add(1)
+		return 0, 0, errBackend
+	}
+
+	// Failing more keys than the negative cache holds forgets the oldest.
+	for key := 0; key <= 10000; key++ {
+		cache.GetOrLoad(ctx, key, loader)
+	}
+	calls.Store(0)
+	cache.GetOrLoad(ctx, 10000, loader)
+	if n := calls.Load(); n != 0 {
+		t.Errorf("Expected the newest error to be cached, got %d loader calls", n)
+	}
+	cache.GetOrLoad(ctx, 0, loader)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected the oldest error to be forgotten, got %d loader calls", n)
+	}
+}
+
+func TestLoadingCacheRecoversLoaderPanic(t *testing.T) {
+	cache := zwis.NewTypedLoadingCache[string, int](zwis.NewTypedLRUCache[string, int](10))
+	_, err := cache.GetOrLoad(context.Background(), "key", func(ctx context.Context, key string) (int, time.Duration, error) {
+		panic("boom")
+	})
+	if err == nil {
+		t.Fatal("Expected an error from a panicking loader")
+	}
+}
@@ -0,0 +1,425 @@
+package zwis_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+// memcachedConn is a raw client connection to the memcached listener.
+type memcachedConn struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// newMemcachedConn serves an LRU cache on a memcached listener and
+// connects to it.
+func newMemcachedConn(t *testing.T) (*memcachedConn, zwis.Cache, *zwistest.FakeClock) {
+	t.Helper()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewLRUCache(100, zwis.WithClock(clock))
+	srv := newTestServer(cache, clock)
+	return dialMemcached(t, listen(t, srv, srv.ServeMemcached)), cache, clock
+}
+
+func dialMemcached(t *testing.T, addr string) *memcachedConn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &memcachedConn{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// roundTrip sends a text protocol request and checks the exact reply.
+func (c *memcachedConn) roundTrip(request, want string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.conn, request); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(c.r, got); err != nil {
+		c.t.Fatalf("%q: reading reply: %v (got %q, want %q)", request, err, got, want)
+	}
+	if string(got) != want {
+		c.t.Errorf("%q replied %q, want %q", request, got, want)
+	}
+}
+
+// readLine reads one reply line without its terminator.
+func (c *memcachedConn) readLine() string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("reading reply: %v", err)
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+// gets returns the CAS value of key.
+func (c *memcachedConn) gets(key string) uint64 {
+	c.t.Helper()
+	fmt.Fprintf(c.conn, "gets %s\r\n", key)
+	var k string
+	var flags, size int
+	var cas uint64
+	if _, err := fmt.Sscanf(c.readLine(), "VALUE %s %d %d %d", &k, &flags, &size, &cas); err != nil {
+		c.t.Fatalf("gets %s: %v", key, err)
+	}
+	c.readLine()
+	if end := c.readLine(); end != "END" {
+		c.t.Fatalf("gets %s ended with %q", key, end)
+	}
+	return cas
+}
+
+func TestMemcachedTextStorage(t *testing.T) {
+	c, _, _ := newMemcachedConn(t)
+
+	c.roundTrip("get k\r\n", "END\r\n")
+	c.roundTrip("set k 42 0 5\r\nhello\r\n", "STORED\r\n")
+	c.roundTrip("get k\r\n", "VALUE k 42 5\r\nhello\r\nEND\r\n")
+	c.roundTrip("set other 0 0 1\r\nx\r\n", "STORED\r\n")
+	c.roundTrip("get k missing other\r\n", "VALUE k 42 5\r\nhello\r\nVALUE other 0 1\r\nx\r\nEND\r\n")
+
+	c.roundTrip("add k 0 0 1\r\nz\r\n", "NOT_STORED\r\n")
+	c.roundTrip("add new 0 0 1\r\nn\r\n", "STORED\r\n")
+	c.roundTrip("replace missing 0 0 1\r\nz\r\n", "NOT_STORED\r\n")
+	c.roundTrip("replace new 1 0 2\r\nnn\r\n", "STORED\r\n")
+	c.roundTrip("append k 0 0 6\r\n world\r\n", "STORED\r\n")
+	c.roundTrip("prepend k 0 0 3\r\n>> \r\n", "STORED\r\n")
+	c.roundTrip("append missing 0 0 1\r\nz\r\n", "NOT_STORED\r\n")
+	c.roundTrip("get k new\r\n", "VALUE k 42 14\r\n>> hello world\r\nVALUE new 1 2\r\nnn\r\nEND\r\n")
+
+	cas := c.gets("k")
+	c.roundTrip(fmt.Sprintf("cas k 0 0 1 %d\r\na\r\n", cas+1), "EXISTS\r\n")
+	c.roundTrip(fmt.Sprintf("cas k 7 0 1 %d\r\nb\r\n", cas), "STORED\r\n")
+	c.roundTrip(fmt.Sprintf("cas k 7 0 1 %d\r\nc\r\n", cas), "EXISTS\r\n")
+	c.roundTrip("cas missing 0 0 1 1\r\nc\r\n", "NOT_FOUND\r\n")
+	c.roundTrip("get k\r\n", "VALUE k 7 1\r\nb\r\nEND\r\n")
+
+	c.roundTrip("delete k\r\n", "DELETED\r\n")
+	c.roundTrip("delete k\r\n", "NOT_FOUND\r\n")
+
+	// noreply suppresses the reply, so the next reply is the version's.
+	c.roundTrip("set quiet 0 0 1 noreply\r\nq\r\ndelete other noreply\r\nversion\r\n", "VERSION 1.6.21\r\n")
+	c.roundTrip("get quiet other\r\n", "VALUE quiet 0 1\r\nq\r\nEND\r\n")
+
+	c.roundTrip("set k 0 0 2\r\ntoo long\r\n", "CLIENT_ERROR bad data chunk\r\n")
+	c.roundTrip("set k 0 0\r\n", "CLIENT_ERROR bad command line format\r\n")
+	c.roundTrip("bogus\r\n", "ERROR\r\n")
+	c.roundTrip(fmt.Sprintf("set big 0 0 %d\r\n%s\r\n", 2<<20, strings.Repeat("x", 2<<20)), "SERVER_ERROR object too large for cache\r\n")
+	c.roundTrip("get big\r\n", "END\r\n")
+}
+
+func TestMemcachedTextCounters(t *testing.T) {
+	c, _, _ := newMemcachedConn(t)
+
+	c.roundTrip("incr n 1\r\n", "NOT_FOUND\r\n")
+	c.roundTrip("set n 5 0 2\r\n10\r\n", "STORED\r\n")
+	c.roundTrip("incr n 5\r\n", "15\r\n")
+	c.roundTrip("decr n 20\r\n", "0\r\n")
+	c.roundTrip("set n 0 0 20\r\n18446744073709551615\r\n", "STORED\r\n")
+	c.roundTrip("incr n 2\r\n", "1\r\n")
+	c.roundTrip("get n\r\n", "VALUE n 0 1\r\n1\r\nEND\r\n")
+
+	c.roundTrip("set s 0 0 3\r\nabc\r\n", "STORED\r\n")
+	c.roundTrip("incr s 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+	c.roundTrip("incr n x\r\n", "CLIENT_ERROR invalid numeric delta argument\r\n")
+}
+
+func TestMemcachedExptime(t *testing.T) {
+	c, _, clock := newMemcachedConn(t)
+	now := clock.Now()
+
+	c.roundTrip("set relative 0 10 1\r\nr\r\n", "STORED\r\n")
+	c.roundTrip(fmt.Sprintf("set absolute 0 %d 1\r\na\r\n", now.Add(time.Hour).Unix()), "STORED\r\n")
+	c.roundTrip(fmt.Sprintf("set past 0 %d 1\r\np\r\n", now.Add(-time.Hour).Unix()), "STORED\r\n")
+	c.roundTrip("set negative 0 -1 1\r\nn\r\n", "STORED\r\n")
+	c.roundTrip("set forever 0 0 1\r\nf\r\n", "STORED\r\n")
+	c.roundTrip("get past negative\r\n", "END\r\n")
+
+	clock.Advance(11 * time.Second)
+	c.roundTrip("get relative absolute\r\n", "VALUE absolute 0 1\r\na\r\nEND\r\n")
+
+	c.roundTrip("touch absolute 5\r\n", "TOUCHED\r\n")
+	c.roundTrip("touch missing 5\r\n", "NOT_FOUND\r\n")
+	clock.Advance(6 * time.Second)
+	c.roundTrip("get absolute forever\r\n", "VALUE forever 0 1\r\nf\r\nEND\r\n")
+
+	c.roundTrip("touch forever -1\r\n", "TOUCHED\r\n")
+	c.roundTrip("get forever\r\n", "END\r\n")
+}
+
+// TestMemcachedExptimeZeroIgnoresDefaultTTL checks that exptime 0 means
+// never expire, even in a cache with a default TTL.
+func TestMemcachedExptimeZeroIgnoresDefaultTTL(t *testing.T) {
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewLRUCache(100, zwis.WithClock(clock), zwis.WithDefaultTTL(time.Second))
+	srv := newTestServer(cache, clock)
+	c := dialMemcached(t, listen(t, srv, srv.ServeMemcached))
+
+	c.roundTrip("set forever 0 0 1\r\nf\r\n", "STORED\r\n")
+	c.roundTrip("set short 0 1 1\r\ns\r\n", "STORED\r\n")
+	c.roundTrip("incr counter 1\r\n", "NOT_FOUND\r\n")
+	c.roundTrip("set counter 0 0 1\r\n1\r\n", "STORED\r\n")
+	c.roundTrip("incr counter 1\r\n", "2\r\n")
+	c.roundTrip("append forever 0 0 1\r\n+\r\n", "STORED\r\n")
+
+	clock.Advance(time.Hour)
+	c.roundTrip("get forever short counter\r\n", "VALUE forever 0 2\r\nf+\r\nVALUE counter 0 1\r\n2\r\nEND\r\n")
+}
+
+func TestMemcachedFlushAll(t *testing.T) {
+	c, cache, clock := newMemcachedConn(t)
+
+	c.roundTrip("set a 0 0 1\r\na\r\n", "STORED\r\n")
+	c.roundTrip("flush_all\r\n", "OK\r\n")
+	c.roundTrip("get a\r\n", "END\r\n")
+
+	c.roundTrip("set a 0 0 1\r\na\r\n", "STORED\r\n")
+	c.roundTrip("flush_all 10\r\n", "OK\r\n")
+	c.roundTrip("get a\r\n", "VALUE a 0 1\r\na\r\nEND\r\n")
+	clock.Advance(5 * time.Second)
+	c.roundTrip("set c 0 0 1\r\nc\r\n", "STORED\r\n")
+	clock.Advance(5 * time.Second)
+	c.roundTrip("get a c\r\n", "END\r\n")
+	if _, ok := cache.Get(context.Background(), "a"); ok {
+		t.Error("a delayed flush_all left a in the cache")
+	}
+	c.roundTrip("set b 0 0 1\r\nb\r\n", "STORED\r\n")
+	c.roundTrip("get b\r\n", "VALUE b 0 1\r\nb\r\nEND\r\n")
+
+	// A later flush_all replaces a pending one.
+	c.roundTrip("flush_all 10\r\n", "OK\r\n")
+	c.roundTrip("flush_all 0\r\n", "OK\r\n")
+	c.roundTrip("set d 0 0 1\r\nd\r\n", "STORED\r\n")
+	clock.Advance(time.Minute)
+	c.roundTrip("get d\r\n", "VALUE d 0 1\r\nd\r\nEND\r\n")
+}
+
+func TestMemcachedStats(t *testing.T) {
+	c, _, _ := newMemcachedConn(t)
+
+	c.roundTrip("set a 0 0 1\r\na\r\n", "STORED\r\n")
+	c.roundTrip("get a\r\n", "VALUE a 0 1\r\na\r\nEND\r\n")
+
+	io.WriteString(c.conn, "stats\r\n")
+	stats := make(map[string]string)
+	for {
+		line := c.readLine()
+		if line == "END" {
+			break
+		}
+		var name, value string
+		fmt.Sscanf(line, "STAT %s %s", &name, &value)
+		stats[name] = value
+	}
+	for name, want := range map[string]string{"curr_items": "1", "cmd_set": "1", "curr_connections": "1", "version": "1.6.21"} {
+		if stats[name] != want {
+			t.Errorf("stat %s = %q, want %q", name, stats[name], want)
+		}
+	}
+	if stats["get_hits"] == "" || stats["get_hits"] == "0" {
+		t.Errorf("stat get_hits = %q, want hits counted", stats["get_hits"])
+	}
+	c.roundTrip("stats reset\r\n", "RESET\r\n")
+}
+
+// binaryRequest builds a memcached binary protocol request.
+func binaryRequest(opcode byte, opaque uint32, cas uint64, extras, key, value []byte) []byte {
+	header := make([]byte, 24)
+	header[0] = 0x80
+	header[1] = opcode
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(key)))
+	header[4] = byte(len(extras))
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(extras)+len(key)+len(value)))
+	binary.BigEndian.PutUint32(header[12:16], opaque)
+	binary.BigEndian.PutUint64(header[16:24], cas)
+	return append(append(append(header, extras...), key...), value...)
+}
+
+type binaryResponse struct {
+	opcode byte
+	status uint16
+	opaque uint32
+	cas    uint64
+	extras []byte
+	key    string
+	value  string
+}
+
+func (c *memcachedConn) readBinary() binaryResponse {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		c.t.Fatalf("reading response: %v", err)
+	}
+	if header[0] != 0x81 {
+		c.t.Fatalf("response magic = %#x, want 0x81", header[0])
+	}
+	body := make([]byte, binary.BigEndian.Uint32(header[8:12]))
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		c.t.Fatalf("reading response body: %v", err)
+	}
+	extrasLen, keyLen := int(header[4]), int(binary.BigEndian.Uint16(header[2:4]))
+	return binaryResponse{
+		opcode: header[1],
+		status: binary.BigEndian.Uint16(header[6:8]),
+		opaque: binary.BigEndian.Uint32(header[12:16]),
+		cas:    binary.BigEndian.Uint64(header[16:24]),
+		extras: body[:extrasLen],
+		key:    string(body[extrasLen : extrasLen+keyLen]),
+		value:  string(body[extrasLen+keyLen:]),
+	}
+}
+
+func (c *memcachedConn) doBinary(opcode byte, cas uint64, extras []byte, key, value string) binaryResponse {
+	c.t.Helper()
+	if _, err := c.conn.Write(binaryRequest(opcode, 7, cas, extras, []byte(key), []byte(value))); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+	res := c.readBinary()
+	if res.opcode != opcode || res.opaque != 7 {
+		c.t.Fatalf("response opcode %#x opaque %d, want %#x and 7", res.opcode, res.opaque, opcode)
+	}
+	return res
+}
+
+func setExtras(flags, exptime uint32) []byte {
+	return binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, flags), exptime)
+}
+
+func TestMemcachedBinary(t *testing.T) {
+	c, _, clock := newMemcachedConn(t)
+
+	if res := c.doBinary(0x00, 0, nil, "k", ""); res.status != 0x01 {
+		t.Errorf("GET missing status = %#x, want key not found", res.status)
+	}
+	set := c.doBinary(0x01, 0, setExtras(42, 0), "k", "hello")
+	if set.status != 0 || set.cas == 0 {
+		t.Fatalf("SET = %+v, want success with a CAS value", set)
+	}
+	get := c.doBinary(0x0c, 0, nil, "k", "")
+	if get.status != 0 || get.value != "hello" || get.key != "k" || get.cas != set.cas || binary.BigEndian.Uint32(get.extras) != 42 {
+		t.Errorf("GETK = %+v, want hello with flags 42 and the SET's CAS", get)
+	}
+
+	if res := c.doBinary(0x02, 0, setExtras(0, 0), "k", "x"); res.status != 0x02 && res.status != 0x05 {
+		t.Errorf("ADD existing status = %#x, want not stored", res.status)
+	}
+	if res := c.doBinary(0x01, set.cas+1, setExtras(0, 0), "k", "x"); res.status != 0x02 {
+		t.Errorf("SET with a stale CAS status = %#x, want key exists", res.status)
+	}
+	if res := c.doBinary(0x01, set.cas, setExtras(0, 0), "k", "world"); res.status != 0 {
+		t.Errorf("SET with the current CAS status = %#x, want success", res.status)
+	}
+	if res := c.doBinary(0x0e, 0, nil, "k", "!"); res.status != 0 {
+		t.Errorf("APPEND status = %#x, want success", res.status)
+	}
+	if res := c.doBinary(0x00, 0, nil, "k", ""); res.value != "world!" {
+		t.Errorf("GET = %q, want world!", res.value)
+	}
+
+	incr := func(key string, delta, initial uint64, exptime uint32) binaryResponse {
+		extras := binary.BigEndian.AppendUint64(nil, delta)
+		extras = binary.BigEndian.AppendUint64(extras, initial)
+		extras = binary.BigEndian.AppendUint32(extras, exptime)
+		return c.doBinary(0x05, 0, extras, key, "")
+	}
+	if res := incr("n", 1, 0, 0xffffffff); res.status != 0x01 {
+		t.Errorf("INCR missing without create status = %#x, want key not found", res.status)
+	}
+	if res := incr("n", 1, 10, 0); res.status != 0 || binary.BigEndian.Uint64([]byte(res.value)) != 10 {
+		t.Errorf("INCR creating = %+v, want 10", res)
+	}
+	if res := incr("n", 5, 10, 0); binary.BigEndian.Uint64([]byte(res.value)) != 15 {
+		t.Errorf("INCR = %+v, want 15", res)
+	}
+	if res := incr("k", 1, 0, 0); res.status != 0x06 {
+		t.Errorf("INCR non-numeric status = %#x, want 0x06", res.status)
+	}
+
+	if res := c.doBinary(0x1c, 0, binary.BigEndian.AppendUint32(nil, 5), "k", ""); res.status != 0 {
+		t.Errorf("TOUCH status = %#x, want success", res.status)
+	}
+	clock.Advance(6 * time.Second)
+	if res := c.doBinary(0x04, 0, nil, "k", ""); res.status != 0x01 {
+		t.Errorf("DELETE of an expired key status = %#x, want key not found", res.status)
+	}
+	if res := c.doBinary(0x04, 0, nil, "n", ""); res.status != 0 {
+		t.Errorf("DELETE status = %#x, want success", res.status)
+	}
+
+	if res := c.doBinary(0x0b, 0, nil, "", ""); res.value != "1.6.21" {
+		t.Errorf("VERSION = %q", res.value)
+	}
+	if res := c.doBinary(0x3f, 0, nil, "", ""); res.status != 0x81 {
+		t.Errorf("unknown opcode status = %#x, want 0x81", res.status)
+	}
+}
+
+// TestMemcachedBinaryQuiet checks that quiet requests only reply on hits
+// or failures, with NOOP ending the pipeline.
+func TestMemcachedBinaryQuiet(t *testing.T) {
+	c, _, _ := newMemcachedConn(t)
+
+	var pipeline []byte
+	pipeline = append(pipeline, binaryRequest(0x11, 1, 0, setExtras(0, 0), []byte("a"), []byte("1"))...)
+	pipeline = append(pipeline, binaryRequest(0x11, 2, 0, setExtras(0, 0), []byte("b"), []byte("2"))...)
+	pipeline = append(pipeline, binaryRequest(0x0d, 3, 0, nil, []byte("a"), nil)...)
+	pipeline = append(pipeline, binaryRequest(0x0d, 4, 0, nil, []byte("missing"), nil)...)
+	pipeline = append(pipeline, binaryRequest(0x0d, 5, 0, nil, []byte("b"), nil)...)
+	pipeline = append(pipeline, binaryRequest(0x12, 6, 0, setExtras(0, 0), []byte("a"), []byte("x"))...)
+	pipeline = append(pipeline, binaryRequest(0x0a, 7, 0, nil, nil, nil)...)
+	if _, err := c.conn.Write(pipeline); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var got []string
+	for {
+		res := c.readBinary()
+		got = append(got, fmt.Sprintf("%d:%s=%s", res.opaque, res.key, res.value))
+		if res.opcode == 0x0a {
+			break
+		}
+	}
+	want := []string{"3:a=1", "5:b=2", "6:=Not stored.", "7:="}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("quiet pipeline replies = %v, want %v", got, want)
+	}
+}
+
+// TestMemcachedSharesKeysWithRESP checks that both protocols see the same
+// keys when served by one server.
+func TestMemcachedSharesKeysWithRESP(t *testing.T) {
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewLRUCache(100, zwis.WithClock(clock))
+	srv := newTestServer(cache, clock)
+	mc := dialMemcached(t, listen(t, srv, srv.ServeMemcached))
+	redis := dialServer(t, listen(t, srv, srv.Serve))
+
+	do(t, redis, "SET", "from-redis", "r", "EX", "100")
+	mc.roundTrip("get from-redis\r\n", "VALUE from-redis 0 1\r\nr\r\nEND\r\n")
+	mc.roundTrip("set from-memcached 0 50 1\r\nm\r\n", "STORED\r\n")
+	expectString(t, redis, "m", "GET", "from-memcached")
+	expectInt(t, redis, 50, "TTL", "from-memcached")
+
+	// Values stored by Go code are served as strings.
+	cache.Set(context.Background(), "from-go", "g", 0)
+	mc.roundTrip("get from-go\r\n", "VALUE from-go 0 1\r\ng\r\nEND\r\n")
+}
@@ -2,14 +2,18 @@ package zwis_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
 )
 
 func TestMemoryCache(t *testing.T) {
-	cache := zwis.NewMemoryCache()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewMemoryCache(zwis.WithClock(clock))
 	ctx := context.Background()
 
 	// Test Set and Get with TTL
@@ -22,7 +26,7 @@ func TestMemoryCache(t *testing.T) {
 	}
 
 	// Test expiration after waiting
-	time.Sleep(60 * time.Millisecond)
+	clock.Advance(60 * time.Millisecond)
 	if _, ok := cache.Get(ctx, "key2"); ok {
 		t.Error("Expected key2 to be expired")
 	}
@@ -34,3 +38,80 @@ func TestMemoryCache(t *testing.T) {
 		t.Error("key5 should have been deleted")
 	}
 }
+
+func TestMemoryCacheCapacity(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewMemoryCache(zwis.WithCapacity(10))
+
+	for i := 0; i < 100; i++ {
+		cache.Set(ctx, fmt.Sprintf("key%d", i), i, 0)
+	}
+	if stats := cache.Stats(); stats.Entries != 10 || stats.CapacityEvictions != 90 {
+		t.Errorf("Expected 10 entries and 90 evictions, got %+v", stats)
+	}
+	if v, ok := cache.Get(ctx, "key99"); !ok || v != 99 {
+		t.Errorf("Expected the newest key to be kept, got %v", v)
+	}
+
+	// Overwriting an existing key never needs room.
+	cache.Set(ctx, "key99", "new", 0)
+	if stats := cache.Stats(); stats.CapacityEvictions != 90 {
+		t.Errorf("Expected no eviction on overwrite, got %+v", stats)
+	}
+}
+
+func TestMemoryCacheEvictsExpiredFirst(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewMemoryCache(zwis.WithCapacity(3), zwis.WithClock(clock))
+
+	cache.Set(ctx, "a", 1, 0)
+	cache.Set(ctx, "b", 2, time.Second)
+	cache.Set(ctx, "c", 3, 0)
+	clock.Advance(2 * time.Second)
+
+	cache.Set(ctx, "d", 4, 0)
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := cache.Get(ctx, key); !ok {
+			t.Errorf("%s should have survived; only the expired b needed to go", key)
+		}
+	}
+	if stats := cache.Stats(); stats.ExpiredEvictions != 1 || stats.CapacityEvictions != 0 {
+		t.Errorf("Expected one expiration and no capacity evictions, got %+v", stats)
+	}
+}
+
+func TestMemoryCacheVolatileStrategies(t *testing.T) {
+	ctx := context.Background()
+
+	cache := zwis.NewMemoryCache(zwis.WithCapacity(3), zwis.WithEvictionStrategy(zwis.EvictVolatileTTL))
+	cache.Set(ctx, "forever", 1, 0)
+	cache.Set(ctx, "hour", 2, time.Hour)
+	cache.Set(ctx, "minute", 3, time.Minute)
+	cache.Set(ctx, "new", 4, time.Hour)
+	if _, ok := cache.Get(ctx, "minute"); ok {
+		t.Error("volatile-ttl should evict the entry closest to expiring")
+	}
+	for _, key := range []string{"forever", "hour", "new"} {
+		if _, ok := cache.Get(ctx, key); !ok {
+			t.Errorf("%s should have survived", key)
+		}
+	}
+
+	cache = zwis.NewMemoryCache(zwis.WithCapacity(2), zwis.WithEvictionStrategy(zwis.EvictVolatileRandom))
+	cache.Set(ctx, "a", 1, 0)
+	cache.Set(ctx, "b", 2, 0)
+	if err := cache.Set(ctx, "c", 3, time.Minute); !errors.Is(err, zwis.ErrCacheFull) {
+		t.Errorf("Expected ErrCacheFull without volatile keys, got %v", err)
+	}
+	cache.Delete(ctx, "b")
+	cache.Set(ctx, "c", 3, time.Minute)
+	cache.Set(ctx, "d", 4, 0)
+	if _, ok := cache.Get(ctx, "c"); ok {
+		t.Error("volatile-random should only evict entries with a TTL")
+	}
+	if _, ok := cache.Get(ctx, "a"); !ok {
+		t.Error("a has no TTL and should have survived")
+	}
+}
+
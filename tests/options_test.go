@@ -0,0 +1,136 @@
+package zwis_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+var policyCacheTypes = []zwis.CacheType{
+	zwis.MemoryCacheType, zwis.LRUCacheType, zwis.LFUCacheType, zwis.ARCCacheType,
+	zwis.TinyLFUCacheType, zwis.SIEVECacheType, zwis.S3FIFOCacheType, zwis.TwoQueueCacheType,
+	zwis.SLRUCacheType, zwis.ClockCacheType, zwis.ClockProCacheType,
+}
+
+func TestNewCacheRejectsInvalidOptions(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cacheType zwis.CacheType
+		opts      []zwis.Option
+	}{
+		"missing capacity":     {zwis.ARCCacheType, nil},
+		"zero capacity":        {zwis.SIEVECacheType, []zwis.Option{zwis.WithCapacity(0)}},
+		"negative capacity":    {zwis.LRUCacheType, []zwis.Option{zwis.WithCapacity(-1)}},
+		"negative max cost":    {zwis.LRUCacheType, []zwis.Option{zwis.WithCapacity(10), zwis.WithMaxCost(-1)}},
+		"unsupported max cost": {zwis.ClockCacheType, []zwis.Option{zwis.WithCapacity(10), zwis.WithMaxCost(100)}},
+		"negative default TTL": {zwis.LFUCacheType, []zwis.Option{zwis.WithCapacity(10), zwis.WithDefaultTTL(-time.Second)}},
+		"unsupported strategy": {zwis.LRUCacheType, []zwis.Option{zwis.WithCapacity(10), zwis.WithEvictionStrategy(zwis.EvictVolatileTTL)}},
+		"callback type": {zwis.LRUCacheType, []zwis.Option{
+			zwis.WithCapacity(10),
+			zwis.WithOnEvict(func(key int, value string, reason zwis.EvictReason) {}),
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if cache, err := zwis.NewCache(tc.cacheType, tc.opts...); err == nil {
+				t.Errorf("Expected an error, got a %T", cache)
+			}
+		})
+	}
+
+	if _, err := zwis.NewCache(zwis.LRUCacheType, zwis.WithMaxCost(1024)); err != nil {
+		t.Errorf("Expected WithMaxCost alone to bound an LRU cache, got %v", err)
+	}
+}
+
+func TestDefaultTTLWithClock(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range policyCacheTypes {
+		t.Run(string(cacheType), func(t *testing.T) {
+			clock := zwistest.NewFakeClock(time.Unix(1_700_000_000, 0))
+			cache, err := zwis.NewCache(cacheType,
+				zwis.WithCapacity(10),
+				zwis.WithDefaultTTL(time.Minute),
+				zwis.WithClock(clock),
+			)
+			if err != nil {
+				t.Fatalf("NewCache: %v", err)
+			}
+
+			cache.Set(ctx, "default", 1, 0)
+			cache.Set(ctx, "short", 2, time.Second)
+			cache.Set(ctx, "forever", 3, -1)
+
+			clock.Advance(2 * time.Second)
+			if _, ok := cache.Get(ctx, "short"); ok {
+				t.Error("Expected short to expire after its own TTL")
+			}
+			if _, ok := cache.Get(ctx, "default"); !ok {
+				t.Error("Expected default to outlive short")
+			}
+
+			clock.Advance(time.Minute)
+			if _, ok := cache.Get(ctx, "default"); ok {
+				t.Error("Expected default to expire after the default TTL")
+			}
+			if v, ok := cache.Get(ctx, "forever"); !ok || v != 3 {
+				t.Errorf("Expected a negative TTL to never expire, got %v", v)
+			}
+		})
+	}
+}
+
+func TestWithOnEvict(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range policyCacheTypes[1:] {
+		t.Run(string(cacheType), func(t *testing.T) {
+			var mu sync.Mutex
+			var evicted []string
+			cache, err := zwis.NewCache(cacheType,
+				zwis.WithCapacity(10),
+				zwis.WithOnEvict(func(key string, value interface{}, reason zwis.EvictReason) {
+					mu.Lock()
+					evicted = append(evicted, key)
+					mu.Unlock()
+				}),
+			)
+			if err != nil {
+				t.Fatalf("NewCache: %v", err)
+			}
+
+			cache.Set(ctx, "a", 1, 0)
+			cache.Delete(ctx, "a")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(evicted) != 1 || evicted[0] != "a" {
+				t.Errorf("Expected the callback to see a, got %v", evicted)
+			}
+		})
+	}
+}
+
+func TestWithStatsDisabled(t *testing.T) {
+	ctx := context.Background()
+
+	cache, err := zwis.NewCache(zwis.LRUCacheType, zwis.WithCapacity(1), zwis.WithStats(false))
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	cache.Set(ctx, "a", 1, 0)
+	cache.Get(ctx, "a")
+	cache.Get(ctx, "missing")
+	cache.Set(ctx, "b", 2, 0)
+
+	stats := cache.(zwis.StatsProvider).Stats()
+	if stats.Hits != 0 || stats.Misses != 0 || stats.Sets != 0 {
+		t.Errorf("Expected no access counters, got %+v", stats)
+	}
+	if stats.CapacityEvictions != 1 || stats.Entries != 1 {
+		t.Errorf("Expected evictions and entries to still be tracked, got %+v", stats)
+	}
+}
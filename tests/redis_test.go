@@ -0,0 +1,434 @@
+package zwis_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/internal/resp"
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+// fakeRedis is an in-process RESP server implementing the commands
+// RedisCache uses. SCAN returns two keys per call to exercise cursors, and
+// GET of the key "slow" blocks until the connection is closed.
+type fakeRedis struct {
+	clock    *zwistest.FakeClock
+	password string
+
+	mu       sync.Mutex
+	data     map[string]fakeRedisEntry
+	commands [][]string
+}
+
+type fakeRedisEntry struct {
+	value    string
+	expireAt time.Time
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	f := &fakeRedis{
+		clock:    zwistest.NewFakeClock(time.Now()),
+		password: password,
+		data:     make(map[string]fakeRedisEntry),
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	var wg sync.WaitGroup
+	var connsMu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			connsMu.Lock()
+			conns = append(conns, conn)
+			connsMu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f.serve(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		connsMu.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		connsMu.Unlock()
+		wg.Wait()
+	})
+	return f, l.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := resp.NewReader(conn)
+	w := resp.NewWriter(conn)
+	authed := f.password == ""
+	for {
+		args, err := r.ReadCommand()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		f.mu.Unlock()
+
+		switch name := strings.ToUpper(args[0]); {
+		case name == "AUTH":
+			if args[len(args)-1] != f.password {
+				w.WriteError("WRONGPASS invalid username-password pair")
+			} else {
+				authed = true
+				w.WriteSimpleString("OK")
+			}
+		case !authed:
+			w.WriteError("NOAUTH Authentication required.")
+		case name == "GET" && args[1] == "slow":
+			conn.Read(make([]byte, 1))
+			return
+		default:
+			f.execute(w, name, args[1:])
+		}
+		if r.Buffered() == 0 {
+			w.Flush()
+		}
+	}
+}
+
+func (f *fakeRedis) execute(w *resp.Writer, name string, args []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch name {
+	case "SELECT":
+		w.WriteSimpleString("OK")
+	case "GET":
+		if e, ok := f.lookup(args[0]); ok {
+			w.WriteBulkString(e.value)
+		} else {
+			w.WriteNull()
+		}
+	case "MGET":
+		w.WriteArrayHeader(len(args))
+		for _, key := range args {
+			if e, ok := f.lookup(key); ok {
+				w.WriteBulkString(e.value)
+			} else {
+				w.WriteNull()
+			}
+		}
+	case "SET":
+		e := fakeRedisEntry{value: args[1]}
+		if len(args) == 4 && strings.ToUpper(args[2]) == "PX" {
+			ms, err := strconv.ParseInt(args[3], 10, 64)
+			if err != nil || ms <= 0 {
+				w.WriteError("ERR invalid expire time in 'set' command")
+				return
+			}
+			e.expireAt = f.clock.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		f.data[args[0]] = e
+		w.WriteSimpleString("OK")
+	case "DEL":
+		var n int64
+		for _, key := range args {
+			if _, ok := f.lookup(key); ok {
+				n++
+			}
+			delete(f.data, key)
+		}
+		w.WriteInteger(n)
+	case "SCAN":
+		// The cursor is the last key returned, so keys deleted between
+		// calls cannot make the scan skip others.
+		after := strings.TrimPrefix(args[0], "after:")
+		pattern := args[2]
+		var keys []string
+		for key := range f.data {
+			if args[0] == "0" || key > after {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		end := min(2, len(keys))
+		w.WriteArrayHeader(2)
+		if end == len(keys) {
+			w.WriteBulkString("0")
+		} else {
+			w.WriteBulkString("after:" + keys[end-1])
+		}
+		var matched []string
+		for _, key := range keys[:end] {
+			if ok, _ := path.Match(pattern, key); ok {
+				matched = append(matched, key)
+			}
+		}
+		w.WriteArrayHeader(len(matched))
+		for _, key := range matched {
+			w.WriteBulkString(key)
+		}
+	default:
+		w.WriteError("ERR unknown command '" + name + "'")
+	}
+}
+
+// lookup returns the live entry for key. f.mu must be held.
+func (f *fakeRedis) lookup(key string) (fakeRedisEntry, bool) {
+	e, ok := f.data[key]
+	if ok && !e.expireAt.IsZero() && !e.expireAt.After(f.clock.Now()) {
+		delete(f.data, key)
+		return fakeRedisEntry{}, false
+	}
+	return e, ok
+}
+
+// lastCommand returns the most recent command named name.
+func (f *fakeRedis) lastCommand(name string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.commands) - 1; i >= 0; i-- {
+		if strings.EqualFold(f.commands[i][0], name) {
+			return f.commands[i]
+		}
+	}
+	return nil
+}
+
+func (f *fakeRedis) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for key := range f.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newRedisCache(t *testing.T, addr string, opts ...zwis.Option) *zwis.RedisCache {
+	t.Helper()
+	cache, err := zwis.NewRedisCache(addr, opts...)
+	if err != nil {
+		t.Fatalf("NewRedisCache: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestRedisCache(t *testing.T) {
+	f, addr := startFakeRedis(t, "")
+	cache := newRedisCache(t, addr, zwis.WithKeyPrefix("app:"), zwis.WithDefaultTTL(time.Minute))
+	ctx := context.Background()
+
+	if err := cache.Set(ctx, "a", "1", 1500*time.Microsecond); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got := f.lastCommand("SET"); strings.Join(got[3:], " ") != "PX 2" || got[1] != "app:a" {
+		t.Errorf("SET sent %q, want key app:a with PX rounded up to 2", got)
+	}
+	cache.Set(ctx, "default", "2", 0)
+	if got := f.lastCommand("SET"); strings.Join(got[3:], " ") != "PX 60000" {
+		t.Errorf("SET with ttl 0 sent %q, want the default TTL", got)
+	}
+	cache.Set(ctx, "forever", 3, -1)
+	if got := f.lastCommand("SET"); len(got) != 3 {
+		t.Errorf("SET with a negative ttl sent %q, want no PX", got)
+	}
+
+	if v, ok := cache.Get(ctx, "forever"); !ok || v != 3 {
+		t.Errorf("Get(forever) = %v, %v; want 3", v, ok)
+	}
+	if v, ok := cache.Get(ctx, "a"); !ok || v != "1" {
+		t.Errorf("Get(a) = %v, %v; want 1", v, ok)
+	}
+	f.clock.Advance(time.Second)
+	if _, ok := cache.Get(ctx, "a"); ok {
+		t.Error("a should have expired on the server")
+	}
+
+	if err := cache.Delete(ctx, "forever"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, err := cache.Lookup(ctx, "forever"); ok || err != nil {
+		t.Errorf("Lookup after Delete = %v, %v; want a miss", ok, err)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Sets != 3 || stats.Deletes != 1 {
+		t.Errorf("Stats = %+v", stats)
+	}
+}
+
+func TestRedisCacheFlushIsPrefixScoped(t *testing.T) {
+	f, addr := startFakeRedis(t, "")
+	ctx := context.Background()
+	ours := newRedisCache(t, addr, zwis.WithKeyPrefix("app[1]:"))
+	other := newRedisCache(t, addr, zwis.WithKeyPrefix("app1:"))
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		ours.Set(ctx, key, key, 0)
+		other.Set(ctx, key, key, 0)
+	}
+	if err := ours.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := f.keys(); len(got) != 5 || got[0] != "app1:a" {
+		t.Errorf("keys after Flush = %v, want only app1:'s", got)
+	}
+	if f.lastCommand("FLUSHDB") != nil || f.lastCommand("FLUSHALL") != nil {
+		t.Error("Flush flushed the whole database")
+	}
+
+	unprefixed := newRedisCache(t, addr)
+	if err := unprefixed.Flush(ctx); err == nil {
+		t.Error("Flush without a key prefix succeeded")
+	}
+	if got := f.keys(); len(got) != 5 {
+		t.Errorf("Flush without a key prefix left keys %v, want all 5", got)
+	}
+}
+
+func TestRedisCacheBatch(t *testing.T) {
+	f, addr := startFakeRedis(t, "")
+	cache := newRedisCache(t, addr, zwis.WithKeyPrefix("p:"))
+	ctx := context.Background()
+
+	err := cache.SetMany(ctx, map[string]zwis.Item{
+		"a":     {Value: "1"},
+		"b":     {Value: "2"},
+		"short": {Value: "3", TTL: time.Second},
+	})
+	if err != nil {
+		t.Fatalf("SetMany: %v", err)
+	}
+	f.clock.Advance(2 * time.Second)
+	got := cache.GetMany(ctx, []string{"a", "b", "short", "missing"})
+	if len(got) != 2 || got["a"] != "1" || got["b"] != "2" {
+		t.Errorf("GetMany = %v, want a=1 b=2", got)
+	}
+	if err := cache.DeleteMany(ctx, []string{"a", "missing"}); err != nil {
+		t.Fatalf("DeleteMany: %v", err)
+	}
+	if got := f.keys(); len(got) != 1 || got[0] != "p:b" {
+		t.Errorf("keys after DeleteMany = %v, want [p:b]", got)
+	}
+}
+
+// stringCodec stores strings as their raw bytes.
+type stringCodec struct{}
+
+func (stringCodec) Encode(value string) ([]byte, error) { return []byte(value), nil }
+func (stringCodec) Decode(data []byte) (string, error)  { return string(data), nil }
+
+func TestRedisCacheCodec(t *testing.T) {
+	f, addr := startFakeRedis(t, "")
+	cache, err := zwis.NewTypedRedisCache[string](addr, zwis.WithCodec[string](stringCodec{}))
+	if err != nil {
+		t.Fatalf("NewTypedRedisCache: %v", err)
+	}
+	defer cache.Close()
+
+	cache.Set(context.Background(), "k", "plain text", 0)
+	if got := f.lastCommand("SET"); got[2] != "plain text" {
+		t.Errorf("SET sent value %q, want the codec's encoding", got[2])
+	}
+	if v, ok := cache.Get(context.Background(), "k"); !ok || v != "plain text" {
+		t.Errorf("Get = %q, %v", v, ok)
+	}
+
+	if _, err := zwis.NewTypedRedisCache[int](addr, zwis.WithCodec[string](stringCodec{})); err == nil {
+		t.Error("NewTypedRedisCache accepted a codec for the wrong value type")
+	}
+}
+
+func TestRedisCacheContext(t *testing.T) {
+	_, addr := startFakeRedis(t, "")
+	cache := newRedisCache(t, addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := cache.Lookup(ctx, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Lookup past the deadline returned %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Lookup took %v to honour the deadline", elapsed)
+	}
+
+	// The timed-out connection is discarded rather than reused.
+	if err := cache.Set(context.Background(), "k", "v", 0); err != nil {
+		t.Fatalf("Set after a timeout: %v", err)
+	}
+	if v, ok := cache.Get(context.Background(), "k"); !ok || v != "v" {
+		t.Errorf("Get after a timeout = %v, %v", v, ok)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cache.Set(cancelled, "k", "v", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Set with a cancelled context returned %v", err)
+	}
+}
+
+func TestRedisCacheAuth(t *testing.T) {
+	f, addr := startFakeRedis(t, "secret")
+	ctx := context.Background()
+
+	cache := newRedisCache(t, addr, zwis.WithRedisAuth("", "secret"), zwis.WithRedisDB(2))
+	if err := cache.Set(ctx, "k", "v", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got := f.lastCommand("SELECT"); len(got) != 2 || got[1] != "2" {
+		t.Errorf("SELECT sent %q, want database 2", got)
+	}
+
+	wrong := newRedisCache(t, addr, zwis.WithRedisAuth("", "wrong"))
+	var serr resp.ServerError
+	if err := wrong.Set(ctx, "k", "v", 0); !errors.As(err, &serr) {
+		t.Errorf("Set with the wrong password returned %v, want a server error", err)
+	}
+}
+
+func TestRedisCacheThroughNewCache(t *testing.T) {
+	_, addr := startFakeRedis(t, "")
+
+	cache, err := zwis.NewCache(zwis.RedisCacheType, zwis.WithRedisAddress(addr), zwis.WithKeyPrefix("nc:"))
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	defer cache.(*zwis.RedisCache).Close()
+	cache.Set(context.Background(), "k", "v", 0)
+	if v, ok := cache.Get(context.Background(), "k"); !ok || v != "v" {
+		t.Errorf("Get = %v, %v", v, ok)
+	}
+
+	for name, opts := range map[string][]zwis.Option{
+		"no address": nil,
+		"capacity":   {zwis.WithRedisAddress(addr), zwis.WithCapacity(10)},
+		"janitor":    {zwis.WithRedisAddress(addr), zwis.WithJanitorInterval(time.Second)},
+	} {
+		if _, err := zwis.NewCache(zwis.RedisCacheType, opts...); err == nil {
+			t.Errorf("NewCache with %s succeeded", name)
+		}
+	}
+	if _, err := zwis.NewTypedCache[int, string](zwis.RedisCacheType, zwis.WithRedisAddress(addr)); err == nil {
+		t.Error("NewTypedCache accepted int keys")
+	}
+}
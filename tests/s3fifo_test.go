@@ -0,0 +1,129 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestS3FIFOCache(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewS3FIFOCache(10, zwis.WithClock(clock))
+
+	// Test Set and Get
+	cache.Set(ctx, "key1", "value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	// Test updating existing key
+	cache.Set(ctx, "key1", "new_value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "new_value1" {
+		t.Errorf("Expected new_value1, got %v", v)
+	}
+
+	// Test expiration
+	cache.Set(ctx, "key2", "value2", 50*time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have expired")
+	}
+
+	// Test Delete
+	cache.Set(ctx, "key3", "value3", 0)
+	cache.Delete(ctx, "key3")
+	if _, ok := cache.Get(ctx, "key3"); ok {
+		t.Error("key3 should have been deleted")
+	}
+
+	// Test Flush
+	cache.Set(ctx, "key4", "value4", 0)
+	cache.Flush(ctx)
+	if _, ok := cache.Get(ctx, "key4"); ok {
+		t.Error("Cache should be empty after Flush")
+	}
+}
+
+func TestS3FIFOCacheRespectsCapacity(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewS3FIFOCache(100)
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i%300)
+		if _, ok := cache.Get(ctx, key); !ok {
+			cache.Set(ctx, key, i, 0)
+		}
+	}
+	if n := cache.Stats().Entries; n != 100 {
+		t.Errorf("Expected 100 entries, got %d", n)
+	}
+
+	// A capacity below 1 is treated as 1 rather than evicting forever.
+	cache = zwis.NewS3FIFOCache(0)
+	cache.Set(ctx, "a", 1, 0)
+	cache.Set(ctx, "b", 2, 0)
+	if v, ok := cache.Get(ctx, "b"); !ok || v != 2 || cache.Stats().Entries != 1 {
+		t.Errorf("Expected a zero-capacity cache to hold only b, got %v, %v with %d entries", v, ok, cache.Stats().Entries)
+	}
+}
+
+func TestS3FIFOCacheFiltersOneHitWonders(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewS3FIFOCache(100)
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("hot%d", i)
+		cache.Set(ctx, key, i, 0)
+		cache.Get(ctx, key)
+	}
+	// Keys seen once are evicted from the small queue before they can push
+	// the hot keys out of the main queue.
+	for i := 0; i < 10000; i++ {
+		cache.Set(ctx, fmt.Sprintf("scan%d", i), i, 0)
+	}
+
+	for i := 0; i < 50; i++ {
+		if _, ok := cache.Get(ctx, fmt.Sprintf("hot%d", i)); !ok {
+			t.Errorf("hot%d should have survived the scan", i)
+		}
+	}
+}
+
+func TestS3FIFOCacheReadmitsGhosts(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewS3FIFOCache(10)
+
+	cache.Set(ctx, "ghost", 0, 0)
+	for i := 0; i < 10; i++ {
+		cache.Set(ctx, fmt.Sprintf("key%d", i), i, 0)
+	}
+	if _, ok := cache.Get(ctx, "ghost"); ok {
+		t.Fatal("ghost should have been evicted from the small queue")
+	}
+
+	// A key that returns while remembered goes straight to the main queue
+	// and so survives a burst of new keys.
+	cache.Set(ctx, "ghost", 0, 0)
+	for i := 10; i < 20; i++ {
+		cache.Set(ctx, fmt.Sprintf("key%d", i), i, 0)
+	}
+	if _, ok := cache.Get(ctx, "ghost"); !ok {
+		t.Error("ghost should have been readmitted to the main queue")
+	}
+}
+
+func TestS3FIFOCacheBeatsLRUOnZipf(t *testing.T) {
+	for _, s := range []float64{1.01, 1.2} {
+		lru := zipfHitRatio(zwis.NewLRUCache(1000), s, 100000, 200000)
+		s3fifo := zipfHitRatio(zwis.NewS3FIFOCache(1000), s, 100000, 200000)
+		if s3fifo <= lru {
+			t.Errorf("s=%.2f: expected S3-FIFO hit ratio above LRU, got %.3f vs %.3f", s, s3fifo, lru)
+		}
+		t.Logf("s=%.2f hit ratio: lru=%.3f s3fifo=%.3f", s, lru, s3fifo)
+	}
+}
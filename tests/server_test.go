@@ -0,0 +1,342 @@
+package zwis_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/internal/resp"
+	"github.com/NonsoAmadi10/zwis/server"
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+// startServer serves cache to Redis clients on a loopback port until the
+// test ends and returns its address.
+func startServer(t *testing.T, cache zwis.Cache, clock zwis.Clock) string {
+	t.Helper()
+	srv := newTestServer(cache, clock)
+	return listen(t, srv, srv.Serve)
+}
+
+func newTestServer(cache zwis.Cache, clock zwis.Clock) *server.Server {
+	return server.New(cache, server.WithClock(clock), server.WithErrorLog(log.New(io.Discard, "", 0)))
+}
+
+// listen calls serve with a loopback listener and returns its address. srv
+// is closed when the test ends.
+func listen(t *testing.T, srv *server.Server, serve func(net.Listener) error) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- serve(l) }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-done; !errors.Is(err, server.ErrServerClosed) {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	})
+	return l.Addr().String()
+}
+
+// newServerClient starts a server for an LRU cache and connects to it.
+func newServerClient(t *testing.T) (*resp.Conn, *zwistest.FakeClock) {
+	t.Helper()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache, err := zwis.NewCache(zwis.LRUCacheType, zwis.WithCapacity(100), zwis.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	return dialServer(t, startServer(t, cache, clock)), clock
+}
+
+func dialServer(t *testing.T, addr string) *resp.Conn {
+	t.Helper()
+	conn, err := resp.Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// do runs a command and fails the test if it returns an error.
+func do(t *testing.T, conn *resp.Conn, args ...string) resp.Value {
+	t.Helper()
+	v, err := conn.Do(context.Background(), args...)
+	if err != nil {
+		t.Fatalf("%s: %v", strings.Join(args, " "), err)
+	}
+	return v
+}
+
+func expectString(t *testing.T, conn *resp.Conn, want string, args ...string) {
+	t.Helper()
+	if v := do(t, conn, args...); v.IsNull() || v.Str != want {
+		t.Errorf("%s = %+v, want %q", strings.Join(args, " "), v, want)
+	}
+}
+
+func expectInt(t *testing.T, conn *resp.Conn, want int64, args ...string) {
+	t.Helper()
+	if v := do(t, conn, args...); v.Type != resp.Integer || v.Int != want {
+		t.Errorf("%s = %+v, want %d", strings.Join(args, " "), v, want)
+	}
+}
+
+func expectNull(t *testing.T, conn *resp.Conn, args ...string) {
+	t.Helper()
+	if v := do(t, conn, args...); !v.IsNull() {
+		t.Errorf("%s = %+v, want null", strings.Join(args, " "), v)
+	}
+}
+
+func expectError(t *testing.T, conn *resp.Conn, prefix string, args ...string) {
+	t.Helper()
+	_, err := conn.Do(context.Background(), args...)
+	var serr resp.ServerError
+	if !errors.As(err, &serr) || !strings.HasPrefix(string(serr), prefix) {
+		t.Errorf("%s returned %v, want an error starting with %q", strings.Join(args, " "), err, prefix)
+	}
+}
+
+func TestServerBasicCommands(t *testing.T) {
+	conn, _ := newServerClient(t)
+
+	expectString(t, conn, "PONG", "PING")
+	expectString(t, conn, "hello", "PING", "hello")
+	expectString(t, conn, "hi", "ECHO", "hi")
+
+	expectNull(t, conn, "GET", "k")
+	expectString(t, conn, "OK", "SET", "k", "v")
+	expectString(t, conn, "v", "GET", "k")
+	expectString(t, conn, "v", "SET", "k", "v2", "GET")
+	expectString(t, conn, "v2", "get", "k")
+
+	expectString(t, conn, "OK", "SET", "other", "x")
+	expectInt(t, conn, 3, "EXISTS", "k", "other", "k", "missing")
+	expectInt(t, conn, 2, "DEL", "k", "other", "missing")
+	expectInt(t, conn, 0, "EXISTS", "k", "other")
+
+	expectError(t, conn, "ERR unknown command 'NOPE'", "NOPE", "a")
+	expectError(t, conn, "ERR wrong number of arguments for 'get' command", "GET")
+	expectError(t, conn, "ERR syntax error", "SET", "k", "v", "NX", "XX")
+	expectError(t, conn, "ERR invalid expire time", "SET", "k", "v", "EX", "0")
+	expectError(t, conn, "ERR value is not an integer", "SET", "k", "v", "PX", "soon")
+}
+
+func TestServerSetConditions(t *testing.T) {
+	conn, _ := newServerClient(t)
+
+	expectNull(t, conn, "SET", "k", "v", "XX")
+	expectNull(t, conn, "GET", "k")
+	expectString(t, conn, "OK", "SET", "k", "v", "NX")
+	expectNull(t, conn, "SET", "k", "other", "NX")
+	expectString(t, conn, "v", "GET", "k")
+	expectString(t, conn, "OK", "SET", "k", "v2", "XX")
+	expectString(t, conn, "v2", "GET", "k")
+}
+
+func TestServerExpiration(t *testing.T) {
+	conn, clock := newServerClient(t)
+
+	expectString(t, conn, "OK", "SET", "ex", "v", "EX", "10")
+	expectString(t, conn, "OK", "SET", "px", "v", "PX", "1500")
+	expectString(t, conn, "OK", "SET", "forever", "v")
+
+	expectInt(t, conn, 10, "TTL", "ex")
+	expectInt(t, conn, 10000, "PTTL", "ex")
+	expectInt(t, conn, 1500, "PTTL", "px")
+	expectInt(t, conn, -1, "TTL", "forever")
+	expectInt(t, conn, -2, "TTL", "missing")
+
+	clock.Advance(2 * time.Second)
+	expectNull(t, conn, "GET", "px")
+	expectInt(t, conn, -2, "PTTL", "px")
+	expectInt(t, conn, 8, "TTL", "ex")
+
+	expectInt(t, conn, 1, "EXPIRE", "forever", "5")
+	expectInt(t, conn, 5, "TTL", "forever")
+	expectInt(t, conn, 0, "EXPIRE", "forever", "20", "NX")
+	expectInt(t, conn, 0, "EXPIRE", "forever", "1", "GT")
+	expectInt(t, conn, 1, "EXPIRE", "forever", "20", "GT")
+	expectInt(t, conn, 20, "TTL", "forever")
+	expectInt(t, conn, 0, "EXPIRE", "missing", "5")
+
+	expectString(t, conn, "OK", "SET", "ex", "v2", "KEEPTTL")
+	expectInt(t, conn, 8, "TTL", "ex")
+	expectString(t, conn, "OK", "SET", "ex", "v3")
+	expectInt(t, conn, -1, "TTL", "ex")
+
+	expectInt(t, conn, 1, "EXPIRE", "ex", "0")
+	expectNull(t, conn, "GET", "ex")
+
+	clock.Advance(20 * time.Second)
+	expectNull(t, conn, "GET", "forever")
+}
+
+// TestServerSharesValuesWithGo checks that Go code can read keys set by
+// clients, and that keys set without an expiration outlive the cache's
+// default TTL.
+func TestServerSharesValuesWithGo(t *testing.T) {
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewLRUCache(100, zwis.WithClock(clock), zwis.WithDefaultTTL(50*time.Millisecond))
+	conn := dialServer(t, startServer(t, cache, clock))
+	ctx := context.Background()
+
+	expectString(t, conn, "OK", "SET", "k", "v")
+	expectString(t, conn, "OK", "MSET", "m", "1")
+	clock.Advance(80 * time.Millisecond)
+	expectString(t, conn, "v", "GET", "k")
+	expectString(t, conn, "1", "GET", "m")
+	expectInt(t, conn, -1, "TTL", "k")
+
+	v, ok := cache.Get(ctx, "k")
+	if rec, isRecord := v.(server.Record); !ok || !isRecord || rec.Value != "v" {
+		t.Errorf("cache.Get(k) = %#v, %v; want a server.Record holding v", v, ok)
+	}
+	if got := server.ValueOf(v); got != "v" {
+		t.Errorf("ValueOf = %q, want v", got)
+	}
+
+	cache.Set(ctx, "bytes", []byte("raw"), -1)
+	cache.Set(ctx, "number", 42, -1)
+	expectString(t, conn, "raw", "GET", "bytes")
+	expectString(t, conn, "42", "GET", "number")
+}
+
+func TestServerMultiKeyCommands(t *testing.T) {
+	conn, _ := newServerClient(t)
+
+	expectString(t, conn, "OK", "MSET", "a", "1", "b", "2")
+	v := do(t, conn, "MGET", "a", "missing", "b")
+	if v.Type != resp.Array || len(v.Elems) != 3 {
+		t.Fatalf("MGET = %+v, want an array of 3", v)
+	}
+	if v.Elems[0].Str != "1" || !v.Elems[1].IsNull() || v.Elems[2].Str != "2" {
+		t.Errorf("MGET = %+v, want [1 nil 2]", v.Elems)
+	}
+	expectError(t, conn, "ERR wrong number of arguments for 'mset' command", "MSET", "a", "1", "b")
+
+	expectInt(t, conn, 2, "DBSIZE")
+	expectString(t, conn, "OK", "FLUSHALL")
+	expectInt(t, conn, 0, "DBSIZE")
+	expectNull(t, conn, "GET", "a")
+}
+
+func TestServerInfo(t *testing.T) {
+	conn, _ := newServerClient(t)
+
+	do(t, conn, "SET", "k", "v")
+	do(t, conn, "GET", "k")
+	do(t, conn, "GET", "missing")
+
+	info := do(t, conn, "INFO").Str
+	for _, want := range []string{"# Server", "redis_version:", "connected_clients:1", "keyspace_hits:1", "keyspace_misses:1", "db0:keys=1"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("INFO does not contain %q:\n%s", want, info)
+		}
+	}
+	if stats := do(t, conn, "INFO", "stats").Str; strings.Contains(stats, "# Server") {
+		t.Errorf("INFO stats includes other sections:\n%s", stats)
+	}
+}
+
+func TestServerRESP3(t *testing.T) {
+	conn, _ := newServerClient(t)
+
+	hello := do(t, conn, "HELLO", "3")
+	if hello.Type != resp.Map {
+		t.Fatalf("HELLO 3 = %+v, want a map", hello)
+	}
+	fields := make(map[string]resp.Value)
+	for i := 0; i+1 < len(hello.Elems); i += 2 {
+		fields[hello.Elems[i].Str] = hello.Elems[i+1]
+	}
+	if fields["proto"].Int != 3 || fields["server"].Str != "redis" {
+		t.Errorf("HELLO 3 = %+v", fields)
+	}
+
+	v := do(t, conn, "GET", "missing")
+	if v.Type != resp.Null {
+		t.Errorf("GET missing = %+v, want a RESP3 null", v)
+	}
+	expectError(t, conn, "NOPROTO", "HELLO", "4")
+}
+
+func TestServerPipelineAndInline(t *testing.T) {
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewLRUCache(100, zwis.WithClock(clock))
+	addr := startServer(t, cache, clock)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Send a pipeline of commands, ending with inline commands as typed
+	// into telnet.
+	w := resp.NewWriter(conn)
+	for i := 0; i < 100; i++ {
+		w.WriteCommand("SET", fmt.Sprintf("key%d", i), fmt.Sprint(i))
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := io.WriteString(conn, "SET spaced \"hello world\"\r\nGET spaced\r\nQUIT\r\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	r := resp.NewReader(conn)
+	for i := 0; i < 101; i++ {
+		if v, err := r.ReadValue(); err != nil || v.Str != "OK" {
+			t.Fatalf("reply %d = %+v, %v; want OK", i, v, err)
+		}
+	}
+	if v, err := r.ReadValue(); err != nil || v.Str != "hello world" {
+		t.Fatalf("GET spaced = %+v, %v; want \"hello world\"", v, err)
+	}
+	if v, err := r.ReadValue(); err != nil || v.Str != "OK" {
+		t.Fatalf("QUIT = %+v, %v; want OK", v, err)
+	}
+	if _, err := r.ReadValue(); err != io.EOF {
+		t.Errorf("read after QUIT returned %v, want EOF", err)
+	}
+
+	if v, ok := cache.Get(context.Background(), "key42"); !ok {
+		t.Errorf("key42 missing from the cache, got %v", v)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewLRUCache(10, zwis.WithClock(clock))
+	srv := server.New(cache, server.WithClock(clock))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(l) }()
+
+	conn := dialServer(t, l.Addr().String())
+	do(t, conn, "PING")
+
+	srv.Close()
+	if err := <-done; !errors.Is(err, server.ErrServerClosed) {
+		t.Errorf("Serve returned %v, want ErrServerClosed", err)
+	}
+	if _, err := conn.Do(context.Background(), "PING"); err == nil {
+		t.Error("PING succeeded after Close")
+	}
+}
@@ -0,0 +1,51 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// BenchmarkParallel compares throughput of a single-lock cache against the
+// same policy split across shards, with a 90% read / 10% write workload.
+func BenchmarkParallel(b *testing.B) {
+	const capacity = 100_000
+	ctx := context.Background()
+	keys := benchKeys(capacity)
+
+	for _, cacheType := range []zwis.CacheType{zwis.LRUCacheType, zwis.LFUCacheType, zwis.ARCCacheType} {
+		for _, shards := range []int{1, 16, 64} {
+			b.Run(fmt.Sprintf("%s/shards=%d", cacheType, shards), func(b *testing.B) {
+				var cache zwis.Cache
+				if shards == 1 {
+					cache, _ = zwis.NewCache(cacheType, zwis.WithCapacity(capacity))
+				} else {
+					cache, _ = zwis.NewShardedCache(cacheType, shards, zwis.WithCapacity(capacity))
+				}
+				for _, key := range keys {
+					cache.Set(ctx, key, key, 0)
+				}
+
+				var goroutines atomic.Int64
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					// Start each goroutine at its own offset, so they do not
+					// walk the keys in lockstep.
+					i := int(goroutines.Add(1)) * 1009
+					for pb.Next() {
+						key := keys[(i*7919)%capacity]
+						if i%10 == 0 {
+							cache.Set(ctx, key, key, 0)
+						} else {
+							cache.Get(ctx, key)
+						}
+						i++
+					}
+				})
+			})
+		}
+	}
+}
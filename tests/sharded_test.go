@@ -0,0 +1,118 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+func TestShardedCache(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range []zwis.CacheType{zwis.MemoryCacheType, zwis.LRUCacheType, zwis.LFUCacheType, zwis.ARCCacheType} {
+		t.Run(string(cacheType), func(t *testing.T) {
+			cache, err := zwis.NewShardedCache(cacheType, 8, zwis.WithCapacity(800))
+			if err != nil {
+				t.Fatalf("NewShardedCache: %v", err)
+			}
+			defer cache.Close()
+
+			var wg sync.WaitGroup
+			for w := 0; w < 8; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < 50; i++ {
+						key := fmt.Sprintf("w%d-key%d", w, i)
+						cache.Set(ctx, key, i, 0)
+						if v, ok := cache.Get(ctx, key); !ok || v != i {
+							t.Errorf("Expected %d for %s, got %v", i, key, v)
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			stats := cache.Stats()
+			if stats.Sets != 400 || stats.Hits != 400 || stats.Entries != 400 {
+				t.Errorf("Expected aggregated stats for 400 entries, got %+v", stats)
+			}
+
+			cache.Delete(ctx, "w0-key0")
+			if _, ok := cache.Get(ctx, "w0-key0"); ok {
+				t.Error("w0-key0 should have been deleted")
+			}
+
+			cache.Flush(ctx)
+			if stats := cache.Stats(); stats.Entries != 0 {
+				t.Errorf("Expected every shard to be flushed, got %d entries", stats.Entries)
+			}
+
+			cache.ResetStats()
+			if stats := cache.Stats(); stats.Hits != 0 || stats.Sets != 0 {
+				t.Errorf("Expected stats to be reset, got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestShardedCacheTypedKeys(t *testing.T) {
+	ctx := context.Background()
+	cache, err := zwis.NewTypedShardedCache[int, string](zwis.LRUCacheType, 4, zwis.WithCapacity(200))
+	if err != nil {
+		t.Fatalf("NewTypedShardedCache: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		cache.Set(ctx, i, fmt.Sprint(i), 0)
+	}
+	for i := 0; i < 100; i++ {
+		if v, ok := cache.Get(ctx, i); !ok || v != fmt.Sprint(i) {
+			t.Errorf("Expected %d, got %q", i, v)
+		}
+	}
+}
+
+type shardKey struct {
+	x    float64
+	name string
+}
+
+func TestShardedCacheHashesEqualKeysAlike(t *testing.T) {
+	ctx := context.Background()
+
+	// -0 and +0 compare equal, so they must find the same shard.
+	cache, _ := zwis.NewTypedShardedCache[shardKey, int](zwis.LRUCacheType, 64, zwis.WithCapacity(640))
+	cache.Set(ctx, shardKey{x: math.Copysign(0, -1), name: "origin"}, 1, 0)
+	if v, ok := cache.Get(ctx, shardKey{x: 0, name: "origin"}); !ok || v != 1 {
+		t.Errorf("Expected 1 for the +0 key, got %v, %v", v, ok)
+	}
+
+	// Pointer keys are found by address, even after what they point to
+	// changes.
+	pointers, _ := zwis.NewTypedShardedCache[*shardKey, int](zwis.LRUCacheType, 64, zwis.WithCapacity(640))
+	keys := make([]*shardKey, 100)
+	for i := range keys {
+		keys[i] = &shardKey{name: fmt.Sprint(i)}
+		pointers.Set(ctx, keys[i], i, 0)
+		keys[i].name += "-changed"
+	}
+	for i, key := range keys {
+		if v, ok := pointers.Get(ctx, key); !ok || v != i {
+			t.Errorf("Expected %d for pointer key %d, got %v, %v", i, i, v, ok)
+		}
+	}
+}
+
+func TestShardedCacheInvalidConfig(t *testing.T) {
+	if _, err := zwis.NewShardedCache(zwis.LRUCacheType, 0, zwis.WithCapacity(100)); err == nil {
+		t.Error("Expected error for zero shards")
+	}
+	if _, err := zwis.NewShardedCache("unknown", 4, zwis.WithCapacity(100)); err == nil {
+		t.Error("Expected error for unknown cache type")
+	}
+}
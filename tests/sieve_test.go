@@ -0,0 +1,129 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+// zipfHitRatio replays a Zipf-distributed trace of n requests over keys
+// distinct keys against the cache, setting every missed key, and returns the
+// resulting hit ratio.
+func zipfHitRatio(cache zwis.Cache, s float64, keys uint64, n int) float64 {
+	ctx := context.Background()
+	zipf := rand.NewZipf(rand.New(rand.NewSource(42)), s, 1, keys-1)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("z%d", zipf.Uint64())
+		if _, ok := cache.Get(ctx, key); !ok {
+			cache.Set(ctx, key, i, 0)
+		}
+	}
+	return cache.(zwis.StatsProvider).Stats().HitRatio
+}
+
+func TestSIEVECache(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewSIEVECache(3, zwis.WithClock(clock))
+
+	// Test Set and Get
+	cache.Set(ctx, "key1", "value1", 0)
+	cache.Set(ctx, "key2", "value2", 0)
+	cache.Set(ctx, "key3", "value3", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	// Test eviction: key1 was visited, so the hand skips it and evicts key2
+	cache.Set(ctx, "key4", "value4", 0)
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have been evicted")
+	}
+	if _, ok := cache.Get(ctx, "key1"); !ok {
+		t.Error("key1 should have survived eviction")
+	}
+
+	// Test updating existing key
+	cache.Set(ctx, "key1", "new_value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "new_value1" {
+		t.Errorf("Expected new_value1, got %v", v)
+	}
+
+	// Test expiration
+	cache.Set(ctx, "key5", "value5", 50*time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
+	if _, ok := cache.Get(ctx, "key5"); ok {
+		t.Error("key5 should have expired")
+	}
+
+	// Test Delete
+	cache.Set(ctx, "key6", "value6", 0)
+	cache.Delete(ctx, "key6")
+	if _, ok := cache.Get(ctx, "key6"); ok {
+		t.Error("key6 should have been deleted")
+	}
+
+	// Test Flush
+	cache.Set(ctx, "key7", "value7", 0)
+	cache.Flush(ctx)
+	if _, ok := cache.Get(ctx, "key7"); ok {
+		t.Error("Cache should be empty after Flush")
+	}
+}
+
+func TestSIEVECacheRespectsCapacity(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewSIEVECache(100)
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		cache.Set(ctx, key, i, 0)
+		if i%3 == 0 {
+			cache.Get(ctx, key)
+		}
+	}
+	if n := cache.Stats().Entries; n != 100 {
+		t.Errorf("Expected 100 entries, got %d", n)
+	}
+}
+
+func TestSIEVECacheConcurrentGets(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewSIEVECache(100)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key%d", (i*w)%200)
+				if _, ok := cache.Get(ctx, key); !ok {
+					cache.Set(ctx, key, i, 0)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := cache.Stats().Entries; n > 100 {
+		t.Errorf("Expected at most 100 entries, got %d", n)
+	}
+}
+
+func TestSIEVECacheBeatsLRUOnZipf(t *testing.T) {
+	for _, s := range []float64{1.01, 1.2} {
+		lru := zipfHitRatio(zwis.NewLRUCache(1000), s, 100000, 200000)
+		sieve := zipfHitRatio(zwis.NewSIEVECache(1000), s, 100000, 200000)
+		if sieve <= lru {
+			t.Errorf("s=%.2f: expected SIEVE hit ratio above LRU, got %.3f vs %.3f", s, sieve, lru)
+		}
+		t.Logf("s=%.2f hit ratio: lru=%.3f sieve=%.3f", s, lru, sieve)
+	}
+}
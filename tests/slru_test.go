@@ -0,0 +1,106 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestSLRUCache(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewSLRUCache(4, zwis.WithClock(clock))
+
+	// Test Set and Get
+	cache.Set(ctx, "key1", "value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	// Test updating existing key
+	cache.Set(ctx, "key1", "new_value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "new_value1" {
+		t.Errorf("Expected new_value1, got %v", v)
+	}
+
+	// Test expiration
+	cache.Set(ctx, "key2", "value2", 50*time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have expired")
+	}
+
+	// Test Delete
+	cache.Set(ctx, "key3", "value3", 0)
+	cache.Delete(ctx, "key3")
+	if _, ok := cache.Get(ctx, "key3"); ok {
+		t.Error("key3 should have been deleted")
+	}
+
+	// Test Flush
+	cache.Set(ctx, "key4", "value4", 0)
+	cache.Flush(ctx)
+	if _, ok := cache.Get(ctx, "key4"); ok {
+		t.Error("Cache should be empty after Flush")
+	}
+}
+
+func TestSLRUCacheEvictsFromProbation(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewSLRUCache(4, zwis.WithProtectedRatio(0.5))
+
+	cache.Set(ctx, "key1", 1, 0)
+	cache.Set(ctx, "key2", 2, 0)
+	cache.Get(ctx, "key1") // promoted to protected
+	cache.Set(ctx, "key3", 3, 0)
+	cache.Set(ctx, "key4", 4, 0)
+
+	// key2 is the least recently used probationary entry.
+	cache.Set(ctx, "key5", 5, 0)
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have been evicted")
+	}
+	if _, ok := cache.Get(ctx, "key1"); !ok {
+		t.Error("key1 should have been protected")
+	}
+}
+
+func TestSLRUCacheRespectsCapacity(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewSLRUCache(100)
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i%250)
+		if _, ok := cache.Get(ctx, key); !ok {
+			cache.Set(ctx, key, i, 0)
+		}
+	}
+	if n := cache.Stats().Entries; n != 100 {
+		t.Errorf("Expected 100 entries, got %d", n)
+	}
+
+	// A capacity below 1 is treated as 1 rather than evicting nothing.
+	cache = zwis.NewSLRUCache(0)
+	cache.Set(ctx, "a", 1, 0)
+	cache.Get(ctx, "a")
+	cache.Set(ctx, "b", 2, 0)
+	if v, ok := cache.Get(ctx, "b"); !ok || v != 2 || cache.Stats().Entries != 1 {
+		t.Errorf("Expected a zero-capacity cache to hold only b, got %v, %v with %d entries", v, ok, cache.Stats().Entries)
+	}
+}
+
+func TestSLRUCacheScanResistance(t *testing.T) {
+	lru := hotSetSurvivors(zwis.NewLRUCache(100), 50, 1000)
+	slru := hotSetSurvivors(zwis.NewSLRUCache(100), 50, 1000)
+
+	if lru != 0 {
+		t.Errorf("Expected the scan to flush LRU, %d hot keys survived", lru)
+	}
+	if slru != 50 {
+		t.Errorf("Expected every hot key to survive the scan in SLRU, got %d/50", slru)
+	}
+}
@@ -0,0 +1,153 @@
+package zwis_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range []zwis.CacheType{zwis.MemoryCacheType, zwis.LRUCacheType, zwis.LFUCacheType, zwis.ARCCacheType} {
+		t.Run(string(cacheType), func(t *testing.T) {
+			clock := zwistest.NewFakeClock(time.Now())
+			original, _ := zwis.NewCache(cacheType, zwis.WithCapacity(4), zwis.WithClock(clock))
+			original.Set(ctx, "forever", "value", 0)
+			original.Set(ctx, "short", 42, 30*time.Millisecond)
+
+			var buf bytes.Buffer
+			if err := original.(zwis.Snapshotter).Snapshot(&buf); err != nil {
+				t.Fatalf("Snapshot: %v", err)
+			}
+
+			restored, _ := zwis.NewCache(cacheType, zwis.WithCapacity(4), zwis.WithClock(clock))
+			restored.Set(ctx, "stale", "dropped by Restore", 0)
+			var flushed []string
+			restored.(zwis.EvictionNotifier).OnEvict(func(key string, value interface{}, reason zwis.EvictReason) {
+				if reason == zwis.EvictReasonFlushed {
+					flushed = append(flushed, key)
+				}
+			})
+			if err := restored.(zwis.Snapshotter).Restore(&buf); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+
+			if _, ok := restored.Get(ctx, "stale"); ok {
+				t.Error("Restore should replace existing contents")
+			}
+			if len(flushed) != 1 || flushed[0] != "stale" {
+				t.Errorf("Expected Restore to report stale as flushed, got %v", flushed)
+			}
+			if v, ok := restored.Get(ctx, "forever"); !ok || v != "value" {
+				t.Errorf("Expected value, got %v", v)
+			}
+			if v, ok := restored.Get(ctx, "short"); !ok || v != 42 {
+				t.Errorf("Expected 42, got %v", v)
+			}
+			if entries := restored.(zwis.StatsProvider).Stats().Entries; entries != 2 {
+				t.Errorf("Expected 2 entries after Restore, got %d", entries)
+			}
+
+			// The remaining TTL carries over.
+			clock.Advance(40 * time.Millisecond)
+			if _, ok := restored.Get(ctx, "short"); ok {
+				t.Error("short should expire at its original deadline")
+			}
+		})
+	}
+}
+
+// TestSnapshotPreservesPolicyState drives an original cache and its restored
+// copy through the same workload and expects identical results, which only
+// holds if recency, frequencies, ghost lists and p were all restored.
+func TestSnapshotPreservesPolicyState(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range []zwis.CacheType{zwis.LRUCacheType, zwis.LFUCacheType, zwis.ARCCacheType} {
+		t.Run(string(cacheType), func(t *testing.T) {
+			original, _ := zwis.NewCache(cacheType, zwis.WithCapacity(8))
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("key%d", (i*i)%23)
+				if i%4 == 0 {
+					original.Set(ctx, key, i, 0)
+				} else {
+					original.Get(ctx, key)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := original.(zwis.Snapshotter).Snapshot(&buf); err != nil {
+				t.Fatalf("Snapshot: %v", err)
+			}
+			restored, _ := zwis.NewCache(cacheType, zwis.WithCapacity(8))
+			if err := restored.(zwis.Snapshotter).Restore(&buf); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("key%d", (i*7)%23)
+				if i%3 == 0 {
+					original.Set(ctx, key, i, 0)
+					restored.Set(ctx, key, i, 0)
+					continue
+				}
+				want, wantOK := original.Get(ctx, key)
+				got, gotOK := restored.Get(ctx, key)
+				if want != got || wantOK != gotOK {
+					t.Fatalf("Step %d: original returned %v, %v but restored returned %v, %v", i, want, wantOK, got, gotOK)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreRejectsMismatchedSnapshots(t *testing.T) {
+	var buf bytes.Buffer
+	zwis.NewLRUCache(4).Snapshot(&buf)
+
+	if err := zwis.NewARCCache(4).Restore(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("Expected an error restoring an LRU snapshot into an ARC cache")
+	}
+	if err := zwis.NewLRUCache(4).Restore(strings.NewReader("not a snapshot")); err == nil {
+		t.Error("Expected an error for invalid input")
+	}
+	if err := zwis.NewLRUCache(4).Restore(bytes.NewReader(buf.Bytes()[:3])); err == nil {
+		t.Error("Expected an error for truncated input")
+	}
+}
+
+func TestSnapshotWithCustomCodec(t *testing.T) {
+	ctx := context.Background()
+	opts := []zwis.Option{zwis.WithCodec[user](jsonCodec[user]{}), zwis.WithKeyCodec[int](jsonCodec[int]{})}
+
+	original := zwis.NewTypedLRUCache[int, user](4, opts...)
+	original.Set(ctx, 1, user{ID: 1, Name: "Ada"}, 0)
+
+	var buf bytes.Buffer
+	if err := original.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"Name":"Ada"`)) {
+		t.Error("Expected the value to be encoded with the JSON codec")
+	}
+
+	restored := zwis.NewTypedLRUCache[int, user](4, opts...)
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if u, ok := restored.Get(ctx, 1); !ok || u.Name != "Ada" {
+		t.Errorf("Expected Ada, got %+v", u)
+	}
+
+	mismatched := zwis.NewTypedLRUCache[int, user](4, zwis.WithCodec[string](jsonCodec[string]{}))
+	if err := mismatched.Snapshot(&buf); err == nil {
+		t.Error("Expected an error for a codec of the wrong type")
+	}
+}
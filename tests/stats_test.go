@@ -0,0 +1,114 @@
+package zwis_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestCacheStats(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cacheType := range []zwis.CacheType{zwis.LRUCacheType, zwis.LFUCacheType, zwis.ARCCacheType} {
+		t.Run(string(cacheType), func(t *testing.T) {
+			clock := zwistest.NewFakeClock(time.Now())
+			cache, _ := zwis.NewCache(cacheType, zwis.WithCapacity(2), zwis.WithClock(clock))
+			provider, ok := cache.(zwis.StatsProvider)
+			if !ok {
+				t.Fatalf("%s cache does not implement StatsProvider", cacheType)
+			}
+
+			cache.Set(ctx, "a", 1, 0)
+			cache.Set(ctx, "b", 2, 20*time.Millisecond)
+			cache.Get(ctx, "a")
+			cache.Get(ctx, "missing")
+
+			clock.Advance(30 * time.Millisecond)
+			cache.Get(ctx, "b") // expired
+			cache.Set(ctx, "c", 3, 0)
+			cache.Set(ctx, "d", 4, 0) // evicts to make room
+			cache.Delete(ctx, "d")
+
+			stats := provider.Stats()
+			want := zwis.Stats{
+				Hits:              1,
+				Misses:            2,
+				Sets:              4,
+				Deletes:           1,
+				CapacityEvictions: 1,
+				ExpiredEvictions:  1,
+				ExplicitEvictions: 1,
+				Entries:           1,
+				HitRatio:          1.0 / 3.0,
+			}
+			if stats != want {
+				t.Errorf("Expected %+v, got %+v", want, stats)
+			}
+
+			provider.ResetStats()
+			if stats := provider.Stats(); stats != (zwis.Stats{Entries: 1}) {
+				t.Errorf("Expected only Entries to survive ResetStats, got %+v", stats)
+			}
+
+			cache.Flush(ctx)
+			if stats := provider.Stats(); stats.Entries != 0 || stats.ExplicitEvictions != 1 {
+				t.Errorf("Expected Flush to drop the remaining entry, got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheStats(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewMemoryCache()
+
+	cache.Set(ctx, "a", 1, 0)
+	cache.Set(ctx, "a", 2, 0)
+	cache.Get(ctx, "a")
+	cache.Get(ctx, "b")
+	cache.Delete(ctx, "a")
+	cache.Delete(ctx, "a")
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Sets != 2 || stats.Deletes != 2 {
+		t.Errorf("Unexpected counters: %+v", stats)
+	}
+	if stats.ExplicitEvictions != 1 || stats.Entries != 0 {
+		t.Errorf("Expected one explicit eviction and no entries, got %+v", stats)
+	}
+	if stats.HitRatio != 0.5 {
+		t.Errorf("Expected hit ratio 0.5, got %v", stats.HitRatio)
+	}
+}
+
+func TestStatsConcurrentReads(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewARCCache(100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				cache.Set(ctx, "key", j, 0)
+				cache.Get(ctx, "key")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				cache.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := cache.Stats(); stats.Hits != 2000 || stats.Sets != 2000 {
+		t.Errorf("Expected 2000 hits and sets, got %+v", stats)
+	}
+}
@@ -0,0 +1,146 @@
+package zwis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestTieredCache(t *testing.T) {
+	ctx := context.Background()
+	l1 := zwis.NewLRUCache(2)
+	l2 := zwis.NewLRUCache(100)
+	cache := zwis.NewTieredCache(l1, l2)
+
+	// Write-through fills both tiers.
+	cache.Set(ctx, "a", 1, 0)
+	if _, ok := l1.Get(ctx, "a"); !ok {
+		t.Error("a should be written to L1")
+	}
+	if _, ok := l2.Get(ctx, "a"); !ok {
+		t.Error("a should be written to L2")
+	}
+
+	// Push a out of the small L1; it is still served from L2 and promoted.
+	cache.Set(ctx, "b", 2, 0)
+	cache.Set(ctx, "c", 3, 0)
+	if _, ok := l1.Get(ctx, "a"); ok {
+		t.Fatal("a should have been evicted from L1")
+	}
+	if v, ok := cache.Get(ctx, "a"); !ok || v != 1 {
+		t.Errorf("Expected 1 from L2, got %v", v)
+	}
+	if _, ok := l1.Get(ctx, "a"); !ok {
+		t.Error("a should have been promoted into L1")
+	}
+	cache.Get(ctx, "a")
+	cache.Get(ctx, "missing")
+
+	stats := cache.TierStats()
+	if stats.L1Hits != 1 || stats.L2Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 L1 hit, 1 L2 hit and 1 miss, got %+v", stats)
+	}
+	if stats.L2.Entries != 3 {
+		t.Errorf("Expected L2 stats to be included, got %+v", stats.L2)
+	}
+	if s := cache.Stats(); s.Hits != 2 || s.Misses != 1 || s.Entries != 3 {
+		t.Errorf("Unexpected combined stats: %+v", s)
+	}
+
+	// Deletes and flushes reach both tiers.
+	cache.Delete(ctx, "a")
+	if _, ok := l1.Get(ctx, "a"); ok {
+		t.Error("a should be deleted from L1")
+	}
+	if _, ok := l2.Get(ctx, "a"); ok {
+		t.Error("a should be deleted from L2")
+	}
+
+	cache.Flush(ctx)
+	if _, ok := cache.Get(ctx, "c"); ok {
+		t.Error("Both tiers should be empty after Flush")
+	}
+
+	cache.ResetStats()
+	if stats := cache.TierStats(); stats.L1Hits != 0 || stats.L1.Hits != 0 {
+		t.Errorf("Expected all stats to be reset, got %+v", stats)
+	}
+}
+
+func TestTieredCacheWriteAround(t *testing.T) {
+	ctx := context.Background()
+	l1 := zwis.NewTypedLRUCache[string, int](10)
+	l2 := zwis.NewTypedLRUCache[string, int](10)
+	cache := zwis.NewTypedTieredCache[string, int](l1, l2, zwis.WithWriteMode(zwis.WriteAround))
+
+	cache.Set(ctx, "a", 1, 0)
+	if _, ok := l1.Get(ctx, "a"); ok {
+		t.Error("Write-around should not write to L1")
+	}
+
+	cache.Get(ctx, "a")
+	if v, ok := l1.Get(ctx, "a"); !ok || v != 1 {
+		t.Errorf("Expected a read to fill L1, got %v", v)
+	}
+
+	// Overwriting must not leave the old value in L1.
+	cache.Set(ctx, "a", 2, 0)
+	if v, _ := cache.Get(ctx, "a"); v != 2 {
+		t.Errorf("Expected 2, got %d", v)
+	}
+}
+
+func TestTieredCachePromotionTTL(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	l1 := zwis.NewLRUCache(10, zwis.WithClock(clock))
+	l2 := zwis.NewLRUCache(10, zwis.WithClock(clock))
+	cache := zwis.NewTieredCache(l1, l2, zwis.WithPromotionTTL(20*time.Millisecond))
+
+	l2.Set(ctx, "a", 1, 0)
+	cache.Get(ctx, "a")
+
+	clock.Advance(30 * time.Millisecond)
+	if _, ok := l1.Get(ctx, "a"); ok {
+		t.Error("The promoted copy should expire from L1")
+	}
+	if _, ok := cache.Get(ctx, "a"); !ok {
+		t.Error("a should still be served from L2")
+	}
+}
+
+func TestTieredCacheOverDisk(t *testing.T) {
+	ctx := context.Background()
+	disk, err := zwis.NewDiskCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewDiskCache: %v", err)
+	}
+	cache := zwis.NewTieredCache(zwis.NewLRUCache(10), disk)
+	defer cache.Close()
+
+	cache.Set(ctx, "a", "on disk", 0)
+	if v, ok := cache.Get(ctx, "a"); !ok || v != "on disk" {
+		t.Errorf("Expected on disk, got %v", v)
+	}
+}
+
+// TestTieredCacheDefaultPromotionTTL checks that promoted copies do not
+// outlive L2's entry by more than the default promotion TTL.
+func TestTieredCacheDefaultPromotionTTL(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	l1 := zwis.NewLRUCache(10, zwis.WithClock(clock))
+	l2 := zwis.NewLRUCache(10, zwis.WithClock(clock))
+	cache := zwis.NewTieredCache(l1, l2)
+
+	l2.Set(ctx, "a", 1, time.Second)
+	cache.Get(ctx, "a")
+
+	clock.Advance(2 * time.Minute)
+	if _, ok := cache.Get(ctx, "a"); ok {
+		t.Error("The promoted copy outlived L2's entry by more than the promotion TTL")
+	}
+}
@@ -0,0 +1,157 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+func TestTinyLFUCache(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewTinyLFUCache(3, zwis.WithClock(clock))
+
+	// Test Set and Get
+	cache.Set(ctx, "key1", "value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	// Test updating existing key
+	cache.Set(ctx, "key1", "new_value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "new_value1" {
+		t.Errorf("Expected new_value1, got %v", v)
+	}
+
+	// Test expiration
+	cache.Set(ctx, "key2", "value2", 50*time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have expired")
+	}
+
+	// Test Delete
+	cache.Set(ctx, "key3", "value3", 0)
+	cache.Delete(ctx, "key3")
+	if _, ok := cache.Get(ctx, "key3"); ok {
+		t.Error("key3 should have been deleted")
+	}
+
+	// Test Flush
+	cache.Set(ctx, "key4", "value4", 0)
+	cache.Flush(ctx)
+	if _, ok := cache.Get(ctx, "key4"); ok {
+		t.Error("Cache should be empty after Flush")
+	}
+}
+
+func TestTinyLFUCacheRespectsCapacity(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewTinyLFUCache(100)
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(ctx, fmt.Sprintf("key%d", i), i, 0)
+	}
+	if n := cache.Stats().Entries; n > 100 {
+		t.Errorf("Expected at most 100 entries, got %d", n)
+	}
+}
+
+func TestTinyLFUCacheKeepsFrequentKeys(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewTinyLFUCache(100)
+
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("hot%d", i)
+			if _, ok := cache.Get(ctx, key); !ok {
+				cache.Set(ctx, key, i, 0)
+			}
+		}
+	}
+
+	// A long scan of keys seen once should not displace the hot set.
+	for i := 0; i < 1000; i++ {
+		cache.Set(ctx, fmt.Sprintf("scan%d", i), i, 0)
+	}
+
+	for i := 0; i < 50; i++ {
+		if _, ok := cache.Get(ctx, fmt.Sprintf("hot%d", i)); !ok {
+			t.Errorf("hot%d should have survived the scan", i)
+		}
+	}
+}
+
+func TestTinyLFUCacheAdaptsToNewHotSet(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewTinyLFUCache(50)
+
+	access := func(prefix string) {
+		for round := 0; round < 20; round++ {
+			for i := 0; i < 40; i++ {
+				key := fmt.Sprintf("%s%d", prefix, i)
+				if _, ok := cache.Get(ctx, key); !ok {
+					cache.Set(ctx, key, i, 0)
+				}
+			}
+		}
+	}
+
+	access("old")
+	access("new")
+
+	hits := 0
+	for i := 0; i < 40; i++ {
+		if _, ok := cache.Get(ctx, fmt.Sprintf("new%d", i)); ok {
+			hits++
+		}
+	}
+	if hits < 30 {
+		t.Errorf("Expected the new hot set to be cached once the old one aged out, got %d/40 hits", hits)
+	}
+}
+
+func TestTinyLFUCacheBeatsLRUOnZipfWithScans(t *testing.T) {
+	ctx := context.Background()
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, 100000)
+
+	hitRatio := func(cache zwis.Cache) float64 {
+		r.Seed(1)
+		scan := 0
+		for i := 0; i < 200000; i++ {
+			key := fmt.Sprintf("z%d", zipf.Uint64())
+			if i%10 == 0 {
+				// Interleave one-off keys to simulate scans.
+				scan++
+				key = fmt.Sprintf("scan%d", scan)
+			}
+			if _, ok := cache.Get(ctx, key); !ok {
+				cache.Set(ctx, key, i, 0)
+			}
+		}
+		return cache.(zwis.StatsProvider).Stats().HitRatio
+	}
+
+	lru := hitRatio(zwis.NewLRUCache(1000))
+	tinyLFU := hitRatio(zwis.NewTinyLFUCache(1000))
+	if tinyLFU <= lru {
+		t.Errorf("Expected TinyLFU hit ratio above LRU, got %.3f vs %.3f", tinyLFU, lru)
+	}
+	t.Logf("hit ratio: lru=%.3f tinylfu=%.3f", lru, tinyLFU)
+}
+
+func TestTinyLFUCacheFactory(t *testing.T) {
+	cache, err := zwis.NewCache(zwis.TinyLFUCacheType, zwis.WithCapacity(10))
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+	if _, ok := cache.(*zwis.TinyLFUCache); !ok {
+		t.Errorf("Expected *TinyLFUCache, got %T", cache)
+	}
+}
@@ -0,0 +1,132 @@
+package zwis_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+	"github.com/NonsoAmadi10/zwis/zwis/zwistest"
+)
+
+// hotSetSurvivors reads a working set of hot keys a few times, interleaved
+// with as many keys that are read once, then runs a scan of scan more
+// one-off keys and returns how many hot keys are still cached afterwards.
+func hotSetSurvivors(cache zwis.Cache, hot, scan int) int {
+	ctx := context.Background()
+	readThrough := func(key string) {
+		if _, ok := cache.Get(ctx, key); !ok {
+			cache.Set(ctx, key, key, 0)
+		}
+	}
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < hot; i++ {
+			readThrough(fmt.Sprintf("hot%d", i))
+		}
+		for i := 0; i < hot; i++ {
+			readThrough(fmt.Sprintf("noise%d-%d", round, i))
+		}
+	}
+	for i := 0; i < scan; i++ {
+		readThrough(fmt.Sprintf("scan%d", i))
+	}
+
+	survivors := 0
+	for i := 0; i < hot; i++ {
+		if _, ok := cache.Get(ctx, fmt.Sprintf("hot%d", i)); ok {
+			survivors++
+		}
+	}
+	return survivors
+}
+
+func TestTwoQueueCache(t *testing.T) {
+	ctx := context.Background()
+	clock := zwistest.NewFakeClock(time.Now())
+	cache := zwis.NewTwoQueueCache(4, zwis.WithClock(clock))
+
+	// Test Set and Get
+	cache.Set(ctx, "key1", "value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	// Test updating existing key
+	cache.Set(ctx, "key1", "new_value1", 0)
+	if v, ok := cache.Get(ctx, "key1"); !ok || v != "new_value1" {
+		t.Errorf("Expected new_value1, got %v", v)
+	}
+
+	// Test expiration
+	cache.Set(ctx, "key2", "value2", 50*time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
+	if _, ok := cache.Get(ctx, "key2"); ok {
+		t.Error("key2 should have expired")
+	}
+
+	// Test Delete
+	cache.Set(ctx, "key3", "value3", 0)
+	cache.Delete(ctx, "key3")
+	if _, ok := cache.Get(ctx, "key3"); ok {
+		t.Error("key3 should have been deleted")
+	}
+
+	// Test Flush
+	cache.Set(ctx, "key4", "value4", 0)
+	cache.Flush(ctx)
+	if _, ok := cache.Get(ctx, "key4"); ok {
+		t.Error("Cache should be empty after Flush")
+	}
+}
+
+func TestTwoQueueCachePromotesGhosts(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewTwoQueueCache(4, zwis.WithTwoQueueRatios(0.25, 0.5))
+
+	// Fill the cache and push key0 out of A1in into the ghost queue.
+	for i := 0; i < 5; i++ {
+		cache.Set(ctx, fmt.Sprintf("key%d", i), i, 0)
+	}
+	if _, ok := cache.Get(ctx, "key0"); ok {
+		t.Fatal("key0 should have been evicted from A1in")
+	}
+
+	// Setting key0 again admits it to Am, where new keys cannot evict it
+	// while A1in is over its share.
+	cache.Set(ctx, "key0", 0, 0)
+	for i := 5; i < 10; i++ {
+		cache.Set(ctx, fmt.Sprintf("key%d", i), i, 0)
+	}
+	if _, ok := cache.Get(ctx, "key0"); !ok {
+		t.Error("key0 should have been kept in Am")
+	}
+}
+
+func TestTwoQueueCacheRespectsCapacity(t *testing.T) {
+	ctx := context.Background()
+	cache := zwis.NewTwoQueueCache(100)
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i%250)
+		if _, ok := cache.Get(ctx, key); !ok {
+			cache.Set(ctx, key, i, 0)
+		}
+	}
+	if n := cache.Stats().Entries; n != 100 {
+		t.Errorf("Expected 100 entries, got %d", n)
+	}
+}
+
+func TestTwoQueueCacheScanResistance(t *testing.T) {
+	lru := hotSetSurvivors(zwis.NewLRUCache(100), 50, 1000)
+	twoQueue := hotSetSurvivors(zwis.NewTwoQueueCache(100), 50, 1000)
+
+	if lru != 0 {
+		t.Errorf("Expected the scan to flush LRU, %d hot keys survived", lru)
+	}
+	if twoQueue != 50 {
+		t.Errorf("Expected every hot key to survive the scan in 2Q, got %d/50", twoQueue)
+	}
+}
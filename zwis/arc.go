@@ -2,6 +2,8 @@ package zwis
 
 /*
 Adaptive Replacement Cache (ARC) is a sophisticated caching algorithm that provides a high hit rate and adapts to varying access patterns. ARC dynamically balances between recent and frequently accessed items by maintaining two lists of pages (recently accessed and frequently accessed) and two ghost lists (recently evicted from each of the main lists).
+
+Every entry records which of the four lists it currently lives in, and resident and ghost entries are each indexed by a map, so Get, Set and Delete never have to walk a list.
 */
 
 import (
@@ -11,229 +13,377 @@ import (
 	"time"
 )
 
-// ARCCache implements the Adaptive Replacement Cache algorithm.
+// arcList identifies which of the four ARC lists an entry belongs to.
+type arcList uint8
+
+const (
+	arcT1 arcList = iota // Resident, accessed once recently
+	arcT2                // Resident, accessed at least twice recently
+	arcB1                // Ghost, evicted from T1
+	arcB2                // Ghost, evicted from T2
+)
+
+// TypedARCCache implements the Adaptive Replacement Cache algorithm.
 // It maintains four lists: T1, T2, B1, and B2.
 // T1 and T2 contain cached items, while B1 and B2 contain "ghost" entries (only keys).
-type ARCCache struct {
-	capacity int                      // Maximum number of items in the cache
-	p        int                      // Target size for the T1 list
-	t1       *list.List               // List for items accessed once recently
-	t2       *list.List               // List for items accessed at least twice recently
-	b1       *list.List               // Ghost list for items evicted from T1
-	b2       *list.List               // Ghost list for items evicted from T2
-	cache    map[string]*list.Element // Map for quick lookup of list elements
-	mu       sync.Mutex               // Mutex for thread-safety
+type TypedARCCache[K comparable, V any] struct {
+	capacity int                  // Maximum number of items in the cache
+	p        int                  // Target size for the T1 list
+	t1       *list.List           // List for items accessed once recently
+	t2       *list.List           // List for items accessed at least twice recently
+	b1       *list.List           // Ghost list for items evicted from T1
+	b2       *list.List           // Ghost list for items evicted from T2
+	cache    map[K]*list.Element  // Resident entries in T1 or T2
+	ghosts   map[K]*list.Element  // Ghost entries in B1 or B2
+	expiries expiryQueue[K]       // Resident entries ordered by expiration
+	janitor  *janitor             // Background sweeper for expired entries
+	codecs   snapshotCodecs[K, V] // Key and value codecs for snapshots
+	mu       sync.Mutex           // Mutex for thread-safety
+	evictionHooks[K, V]
+	timekeeper
+	costBudget[K, V]
 }
 
+// ARCCache is a TypedARCCache with string keys and interface{} values.
+type ARCCache = TypedARCCache[string, interface{}]
+
 // arcItem represents an item in the cache.
-type arcItem struct {
-	key        string
-	value      interface{}
-	expiration int64 // Unix timestamp for item expiration (0 means no expiration)
+type arcItem[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration int64           // Unix timestamp for item expiration (0 means no expiration)
+	expiry     *expiryEntry[K] // Position in the expiry heap
+	list       arcList         // List the item currently lives in
+	cost       int64           // Cost charged against the budget
 }
 
 // NewARCCache creates a new ARC cache with the given capacity.
-func NewARCCache(capacity int) *ARCCache {
-	return &ARCCache{
-		capacity: capacity,
-		p:        0,
-		t1:       list.New(),
-		t2:       list.New(),
-		b1:       list.New(),
-		b2:       list.New(),
-		cache:    make(map[string]*list.Element),
+func NewARCCache(capacity int, opts ...Option) *ARCCache {
+	return NewTypedARCCache[string, interface{}](capacity, opts...)
+}
+
+// NewTypedARCCache creates a new generic ARC cache with the given capacity.
+func NewTypedARCCache[K comparable, V any](capacity int, opts ...Option) *TypedARCCache[K, V] {
+	o := newOptions(opts)
+	c := &TypedARCCache[K, V]{
+		capacity:   capacity,
+		p:          0,
+		t1:         list.New(),
+		t2:         list.New(),
+		b1:         list.New(),
+		b2:         list.New(),
+		cache:      make(map[K]*list.Element),
+		ghosts:     make(map[K]*list.Element),
+		timekeeper: newTimekeeper(o),
 	}
+	c.codecs = newSnapshotCodecs[K, V](o)
+	c.configureCost(o)
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c
 }
 
 // Get retrieves an item from the cache.
-func (c *ARCCache) Get(ctx context.Context, key string) (interface{}, bool) {
+func (c *TypedARCCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if elt, ok := c.cache[key]; ok {
-		item := elt.Value.(*arcItem)
+	defer c.unlock(&c.mu)
+	return c.getLocked(key)
+}
 
-		if item.expiration > 0 && item.expiration < time.Now().UnixNano() {
-			c.remove(key)
-			return nil, false
-		}
+// getLocked is Get with c.mu held.
+func (c *TypedARCCache[K, V]) getLocked(key K) (V, bool) {
+	var zero V
+	elt, ok := c.cache[key]
+	if !ok {
+		c.recordMiss()
+		return zero, false
+	}
 
-		if c.listContains(c.t1, elt) {
-			c.t1.Remove(elt)
-			c.t2.PushFront(item)
-			c.cache[key] = c.t2.Front()
-		} else if c.listContains(c.t2, elt) {
-			c.t2.MoveToFront(elt)
-		}
-		return item.value, true
+	item := elt.Value.(*arcItem[K, V])
+	if item.expiration > 0 && item.expiration < c.now().UnixNano() {
+		c.remove(elt, EvictReasonExpired)
+		c.recordMiss()
+		return zero, false
 	}
 
-	// Cache miss, but update ghost lists
-	c.request(key)
-	return nil, false
+	c.promote(elt)
+	c.recordHit()
+	return item.value, true
 }
 
 // Set adds or updates an item in the cache.
-// Set adds or updates an item in the cache.
-func (c *ARCCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+func (c *TypedARCCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	return c.SetWithCost(ctx, key, value, c.costOf(key, value), ttl)
+}
+
+// SetWithCost adds or updates an item with an explicit cost. Besides keeping
+// at most capacity items, the cache evicts items the way replace does until
+// the total cost fits the budget set with WithMaxCost.
+func (c *TypedARCCache[K, V]) SetWithCost(ctx context.Context, key K, value V, cost int64, ttl time.Duration) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, value, cost, ttl)
+}
 
-	var expiration int64
-	if ttl > 0 {
-		expiration = time.Now().Add(ttl).UnixNano()
+// setLocked is SetWithCost with c.mu held.
+func (c *TypedARCCache[K, V]) setLocked(key K, value V, cost int64, ttl time.Duration) error {
+	if err := c.checkCost(cost); err != nil {
+		return err
 	}
 
+	expiration := unixNano(c.expiresAt(ttl))
+
+	c.recordSet()
 	if elt, ok := c.cache[key]; ok {
-		item := elt.Value.(*arcItem)
+		item := elt.Value.(*arcItem[K, V])
+		c.evicted(key, item.value, EvictReasonReplaced)
 		item.value = value
 		item.expiration = expiration
-		if c.listContains(c.t1, elt) {
-			c.t1.Remove(elt)
-			c.t2.PushFront(item)
-			c.cache[key] = c.t2.Front()
-		} else if c.listContains(c.t2, elt) {
-			c.t2.MoveToFront(elt)
-		}
+		item.expiry = c.expiries.track(item.expiry, key, expiration)
+		c.charge(cost - item.cost)
+		item.cost = cost
+		c.promote(elt)
+		c.shrink(0, elt)
 		return nil
 	}
 
-	// New item
-	c.request(key)
+	item := &arcItem[K, V]{key: key, value: value, expiration: expiration, cost: cost}
+	item.expiry = c.expiries.track(nil, key, expiration)
+
+	if ghost, ok := c.ghosts[key]; ok {
+		// A ghost hit means the key was evicted too early: adapt p towards the
+		// list it was evicted from and bring it back as a frequent item.
+		inB2 := ghost.Value.(*arcItem[K, V]).list == arcB2
+		if inB2 {
+			c.p = max(0, c.p-max(c.b1.Len()/c.b2.Len(), 1))
+		} else {
+			c.p = min(c.capacity, c.p+max(c.b2.Len()/c.b1.Len(), 1))
+		}
+		c.removeGhost(ghost)
+		if c.t1.Len()+c.t2.Len() >= c.capacity {
+			c.replace(inB2)
+		}
+		c.shrink(cost, nil)
+		item.list = arcT2
+		c.cache[key] = c.t2.PushFront(item)
+		c.charge(cost)
+		c.recordAdded()
+		return nil
+	}
 
-	if c.t1.Len()+c.t2.Len() >= c.capacity {
-		c.replace(key)
+	// Complete miss: keep the resident and ghost directories within bounds.
+	// When T1 alone fills the cache, B1 is empty and its LRU page is dropped
+	// without a ghost, or T1 and B1 together would outgrow the capacity.
+	if c.t1.Len()+c.b1.Len() >= c.capacity {
+		if c.t1.Len() < c.capacity {
+			c.removeGhost(c.b1.Back())
+			c.replace(false)
+		} else {
+			c.remove(c.t1.Back(), EvictReasonCapacity)
+		}
+	} else if total := c.t1.Len() + c.t2.Len() + c.b1.Len() + c.b2.Len(); total >= c.capacity {
+		if total >= 2*c.capacity {
+			c.removeGhost(c.b2.Back())
+		}
+		if c.t1.Len()+c.t2.Len() >= c.capacity {
+			c.replace(false)
+		}
 	}
 
-	item := &arcItem{key: key, value: value, expiration: expiration}
-	c.t1.PushFront(item)
-	c.cache[key] = c.t1.Front()
+	c.shrink(cost, nil)
+	item.list = arcT1
+	c.cache[key] = c.t1.PushFront(item)
+	c.charge(cost)
+	c.recordAdded()
 
 	return nil
 }
 
 // Delete removes an item from the cache.
-func (c *ARCCache) Delete(ctx context.Context, key string) error {
+func (c *TypedARCCache[K, V]) Delete(ctx context.Context, key K) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
 
-	c.remove(key)
+// deleteLocked is Delete with c.mu held.
+func (c *TypedARCCache[K, V]) deleteLocked(key K) error {
+	c.recordDelete()
+	if elt, ok := c.cache[key]; ok {
+		c.remove(elt, EvictReasonDeleted)
+	}
 	return nil
 }
 
-// Clear removes all items from the cache.
-func (c *ARCCache) Flush(ctx context.Context) error {
+// GetMany looks up every key under a single lock acquisition.
+func (c *TypedARCCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return getMany(keys, c.getLocked)
+}
+
+// SetMany stores every item under a single lock acquisition.
+func (c *TypedARCCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock(&c.mu)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return c.setLocked(key, item.Value, c.costOf(key, item.Value), item.TTL)
+	})
+}
 
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedARCCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
+
+// Flush removes all items from the cache.
+func (c *TypedARCCache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	c.flushLocked()
+	return nil
+}
+
+// flushLocked removes every item and ghost key, reporting each item as
+// flushed. c.mu must be held.
+func (c *TypedARCCache[K, V]) flushLocked() {
+	for _, l := range []*list.List{c.t1, c.t2} {
+		for elt := l.Back(); elt != nil; elt = elt.Prev() {
+			item := elt.Value.(*arcItem[K, V])
+			c.evicted(item.key, item.value, EvictReasonFlushed)
+		}
+	}
 	c.t1.Init()
 	c.t2.Init()
 	c.b1.Init()
 	c.b2.Init()
-	c.cache = make(map[string]*list.Element)
+	c.cache = make(map[K]*list.Element)
+	c.ghosts = make(map[K]*list.Element)
+	c.expiries = nil
 	c.p = 0
+	c.used.Store(0)
+}
+
+// Close stops the background janitor, if one was configured.
+func (c *TypedARCCache[K, V]) Close() error {
+	c.janitor.Close()
 	return nil
 }
 
-// remove deletes an item from the cache and moves it to the appropriate ghost list.
-func (c *ARCCache) remove(key string) {
-	if elt, ok := c.cache[key]; ok {
-		if c.listContains(c.t1, elt) {
-			c.t1.Remove(elt)
-			c.b1.PushFront(key)
-			if c.b1.Len() > c.capacity {
-				c.b1.Remove(c.b1.Back())
-			}
-		} else if c.listContains(c.t2, elt) {
-			c.t2.Remove(elt)
-			c.b2.PushFront(key)
-			if c.b2.Len() > c.capacity {
-				c.b2.Remove(c.b2.Back())
-			}
-		}
-		delete(c.cache, key)
+// deleteExpired removes every resident entry whose TTL has elapsed.
+func (c *TypedARCCache[K, V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(c.cache[key], EvictReasonExpired)
 	}
 }
 
+// promote moves a resident item to the front of T2.
+func (c *TypedARCCache[K, V]) promote(elt *list.Element) {
+	item := elt.Value.(*arcItem[K, V])
+	if item.list == arcT2 {
+		c.t2.MoveToFront(elt)
+		return
+	}
+	c.t1.Remove(elt)
+	item.list = arcT2
+	c.cache[item.key] = c.t2.PushFront(item)
+}
+
+// remove drops a resident item without remembering it in a ghost list.
+func (c *TypedARCCache[K, V]) remove(elt *list.Element, reason EvictReason) {
+	item := elt.Value.(*arcItem[K, V])
+	c.residentList(item.list).Remove(elt)
+	c.expiries.untrack(item.expiry)
+	c.charge(-item.cost)
+	delete(c.cache, item.key)
+	c.evicted(item.key, item.value, reason)
+}
+
 // replace is called when the cache is full and a new item needs to be added.
-// It chooses which item to evict based on the ARC algorithm.
-func (c *ARCCache) replace(key string) {
-	if c.t1.Len() > 0 && (c.t1.Len() > c.p || (c.listContainsKey(c.b2, key) && c.t1.Len() == c.p)) {
-		// Evict from T1
-		lru := c.t1.Back()
-		c.t1.Remove(lru)
-		c.b1.PushFront(lru.Value.(*arcItem).key)
-		if c.b1.Len() > c.capacity {
-			c.b1.Remove(c.b1.Back())
+// It chooses which item to evict based on the ARC algorithm. inB2 reports
+// whether the incoming key was found in B2.
+func (c *TypedARCCache[K, V]) replace(inB2 bool) {
+	if c.t1.Len() > 0 && (c.t1.Len() > c.p || (inB2 && c.t1.Len() == c.p) || c.t2.Len() == 0) {
+		c.evict(c.t1.Back())
+	} else if c.t2.Len() > 0 {
+		c.evict(c.t2.Back())
+	}
+}
+
+// shrink evicts items until extra more cost fits the budget, choosing
+// between T1 and T2 as replace does and never evicting keep.
+func (c *TypedARCCache[K, V]) shrink(extra int64, keep *list.Element) {
+	for c.overBudget(extra) {
+		t1, t2 := c.t1.Back(), c.t2.Back()
+		if t1 == keep && t1 != nil {
+			t1 = t1.Prev()
+		}
+		if t2 == keep && t2 != nil {
+			t2 = t2.Prev()
 		}
-		delete(c.cache, lru.Value.(*arcItem).key)
-	} else {
-		// Evict from T2
-		lru := c.t2.Back()
-		c.t2.Remove(lru)
-		c.b2.PushFront(lru.Value.(*arcItem).key)
-		if c.b2.Len() > c.capacity {
-			c.b2.Remove(c.b2.Back())
+		switch {
+		case t1 != nil && (c.t1.Len() > c.p || t2 == nil):
+			c.evict(t1)
+		case t2 != nil:
+			c.evict(t2)
+		case t1 != nil:
+			c.evict(t1)
+		default:
+			return
 		}
-		delete(c.cache, lru.Value.(*arcItem).key)
 	}
 }
 
-// request updates the target size p based on which ghost list contains the requested key.
-func (c *ARCCache) request(key string) {
-	if c.listContainsKey(c.b1, key) {
-		c.p = min(c.capacity, c.p+max(c.b2.Len()/c.b1.Len(), 1))
-		c.moveToT2(key)
-		item := &arcItem{key: key, value: nil}
-		c.t2.PushFront(item)
-		c.cache[key] = c.t2.Front()
-	} else if c.listContainsKey(c.b2, key) {
-		c.p = max(0, c.p-max(c.b1.Len()/c.b2.Len(), 1))
-		c.moveToT2(key)
-		item := &arcItem{key: key, value: nil}
-		c.t2.PushFront(item)
-		c.cache[key] = c.t2.Front()
+// evict moves a resident item into the ghost list matching its resident list.
+func (c *TypedARCCache[K, V]) evict(elt *list.Element) {
+	if elt == nil {
+		return
+	}
+	c.remove(elt, EvictReasonCapacity)
+
+	item := elt.Value.(*arcItem[K, V])
+	ghost := &arcItem[K, V]{key: item.key, list: arcB1}
+	if item.list == arcT2 {
+		ghost.list = arcB2
 	}
-}
 
-func (c *ARCCache) moveToT2(key string) {
-	if elt := c.removeFromList(c.b1, key); elt != nil {
-		c.b1.Remove(elt)
-	} else if elt := c.removeFromList(c.b2, key); elt != nil {
-		c.b2.Remove(elt)
+	ghosts := c.ghostList(ghost.list)
+	c.ghosts[item.key] = ghosts.PushFront(ghost)
+	if ghosts.Len() > c.capacity {
+		c.removeGhost(ghosts.Back())
 	}
 }
 
-func (c *ARCCache) removeFromList(l *list.List, key string) *list.Element {
-	for e := l.Front(); e != nil; e = e.Next() {
-		if k, ok := e.Value.(string); ok && k == key {
-			return e
-		}
+// removeGhost forgets a ghost entry.
+func (c *TypedARCCache[K, V]) removeGhost(elt *list.Element) {
+	if elt == nil {
+		return
 	}
-	return nil
+	ghost := elt.Value.(*arcItem[K, V])
+	c.ghostList(ghost.list).Remove(elt)
+	delete(c.ghosts, ghost.key)
 }
 
-// listContains checks if a list contains a specific element.
-func (c *ARCCache) listContains(l *list.List, element *list.Element) bool {
-	for e := l.Front(); e != nil; e = e.Next() {
-		if e == element {
-			return true
-		}
+// residentList returns T1 or T2.
+func (c *TypedARCCache[K, V]) residentList(l arcList) *list.List {
+	if l == arcT2 {
+		return c.t2
 	}
-	return false
+	return c.t1
 }
 
-// listContainsKey checks if a list contains an item with a specific key.
-func (c *ARCCache) listContainsKey(l *list.List, key string) bool {
-	for e := l.Front(); e != nil; e = e.Next() {
-		if item, ok := e.Value.(*arcItem); ok && item.key == key {
-			return true
-		}
-		if s, ok := e.Value.(string); ok && s == key {
-			return true
-		}
+// ghostList returns B1 or B2.
+func (c *TypedARCCache[K, V]) ghostList(l arcList) *list.List {
+	if l == arcB2 {
+		return c.b2
 	}
-	return false
+	return c.b1
 }
 
 // min returns the minimum of two integers.
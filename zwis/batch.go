@@ -0,0 +1,102 @@
+package zwis
+
+/*
+Batch operations look up, store or remove many keys at once. Every policy implements them natively under a single lock acquisition, which saves a lock round trip per key for request handlers that touch dozens of keys at a time. NewBatchCache adapts any other Cache by looping over its single-key methods.
+*/
+
+import (
+	"context"
+	"time"
+)
+
+// TypedItem is a value to store with SetMany, along with its TTL. The TTL
+// has the same meaning as the ttl passed to Set.
+type TypedItem[V any] struct {
+	Value V
+	TTL   time.Duration
+}
+
+// Item is a TypedItem with an interface{} value.
+type Item = TypedItem[interface{}]
+
+// TypedBatchCache is a cache that can operate on many keys at once.
+type TypedBatchCache[K comparable, V any] interface {
+	TypedCache[K, V]
+	// GetMany returns the values of the keys that are in the cache. Missing
+	// and expired keys are left out of the result.
+	GetMany(ctx context.Context, keys []K) map[K]V
+	// SetMany stores every item. It stores as many items as it can and
+	// returns the first error encountered.
+	SetMany(ctx context.Context, items map[K]TypedItem[V]) error
+	// DeleteMany removes every key. It returns the first error encountered.
+	DeleteMany(ctx context.Context, keys []K) error
+}
+
+// BatchCache is a TypedBatchCache with string keys and interface{} values.
+type BatchCache = TypedBatchCache[string, interface{}]
+
+// NewBatchCache returns cache as a BatchCache. Caches that already implement
+// BatchCache are returned as they are; others are wrapped in an adapter
+// that calls their single-key methods in a loop.
+func NewBatchCache(cache Cache) BatchCache {
+	return NewTypedBatchCache[string, interface{}](cache)
+}
+
+// NewTypedBatchCache returns cache as a TypedBatchCache, wrapping it in a
+// looping adapter if it does not implement batch operations itself.
+func NewTypedBatchCache[K comparable, V any](cache TypedCache[K, V]) TypedBatchCache[K, V] {
+	if batch, ok := cache.(TypedBatchCache[K, V]); ok {
+		return batch
+	}
+	return batchAdapter[K, V]{cache}
+}
+
+// batchAdapter implements batch operations on top of single-key calls.
+type batchAdapter[K comparable, V any] struct {
+	TypedCache[K, V]
+}
+
+func (a batchAdapter[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	return getMany(keys, func(key K) (V, bool) { return a.Get(ctx, key) })
+}
+
+func (a batchAdapter[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	return setMany(items, func(key K, item TypedItem[V]) error { return a.Set(ctx, key, item.Value, item.TTL) })
+}
+
+func (a batchAdapter[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	return deleteMany(keys, func(key K) error { return a.Delete(ctx, key) })
+}
+
+// getMany, setMany and deleteMany apply a single-key operation to every key.
+// The policies call them with the cache lock held and their unlocked
+// single-key operations.
+func getMany[K comparable, V any](keys []K, get func(K) (V, bool)) map[K]V {
+	found := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if value, ok := get(key); ok {
+			found[key] = value
+		}
+	}
+	return found
+}
+
+func setMany[K comparable, V any](items map[K]TypedItem[V], set func(K, TypedItem[V]) error) error {
+	var firstErr error
+	for key, item := range items {
+		if err := set(key, item); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func deleteMany[K comparable](keys []K, del func(K) error) error {
+	var firstErr error
+	for _, key := range keys {
+		if err := del(key); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
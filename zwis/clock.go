@@ -0,0 +1,275 @@
+package zwis
+
+/*
+CLOCK approximates LRU with a ring buffer of slots and a single hand. A hit only sets the slot's reference bit, so lookups run under a read lock and never move anything. To make room the hand sweeps the ring, clearing reference bits, and evicts the first entry whose bit was already clear; the new entry takes over that slot, right behind the hand.
+*/
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TypedClockCache implements the CLOCK eviction policy.
+type TypedClockCache[K comparable, V any] struct {
+	capacity int
+	slots    []clockSlot[K, V]
+	free     []int // Indexes of slots emptied by Delete or expiry
+	hand     int
+	cache    map[K]int
+	expiries expiryQueue[K]
+	janitor  *janitor
+	mu       sync.RWMutex
+	evictionHooks[K, V]
+	timekeeper
+}
+
+// ClockCache is a TypedClockCache with string keys and interface{} values.
+type ClockCache = TypedClockCache[string, interface{}]
+
+type clockSlot[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration int64
+	expiry     *expiryEntry[K]
+	used       bool
+	referenced atomic.Bool
+}
+
+// NewClockCache creates a CLOCK cache with the given capacity.
+func NewClockCache(capacity int, opts ...Option) *ClockCache {
+	return NewTypedClockCache[string, interface{}](capacity, opts...)
+}
+
+// NewTypedClockCache creates a generic CLOCK cache with the given capacity.
+// A capacity below 1 is treated as 1.
+func NewTypedClockCache[K comparable, V any](capacity int, opts ...Option) *TypedClockCache[K, V] {
+	o := newOptions(opts)
+	capacity = max(capacity, 1)
+	c := &TypedClockCache[K, V]{
+		capacity:   capacity,
+		cache:      make(map[K]int),
+		timekeeper: newTimekeeper(o),
+	}
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c
+}
+
+func (c *TypedClockCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	var zero V
+
+	c.mu.RLock()
+	idx, ok := c.cache[key]
+	if !ok {
+		c.mu.RUnlock()
+		c.recordMiss()
+		return zero, false
+	}
+
+	slot := &c.slots[idx]
+	if slot.expiration > 0 && slot.expiration < c.now().UnixNano() {
+		c.mu.RUnlock()
+		c.mu.Lock()
+		// The entry may have been replaced while the lock was released.
+		c.removeIfExpired(key)
+		c.unlock(&c.mu)
+		c.recordMiss()
+		return zero, false
+	}
+
+	slot.referenced.Store(true)
+	value := slot.value
+	c.mu.RUnlock()
+
+	c.recordHit()
+	return value, true
+}
+
+func (c *TypedClockCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, value, ttl)
+}
+
+// setLocked is Set with c.mu held.
+func (c *TypedClockCache[K, V]) setLocked(key K, value V, ttl time.Duration) error {
+	expiration := unixNano(c.expiresAt(ttl))
+
+	c.recordSet()
+	if idx, ok := c.cache[key]; ok {
+		slot := &c.slots[idx]
+		c.evicted(key, slot.value, EvictReasonReplaced)
+		slot.value = value
+		slot.expiration = expiration
+		slot.expiry = c.expiries.track(slot.expiry, key, expiration)
+		slot.referenced.Store(true)
+		return nil
+	}
+
+	idx := c.claimSlot()
+	slot := &c.slots[idx]
+	slot.key = key
+	slot.value = value
+	slot.expiration = expiration
+	slot.expiry = c.expiries.track(nil, key, expiration)
+	slot.used = true
+	slot.referenced.Store(false)
+	c.cache[key] = idx
+	c.recordAdded()
+	return nil
+}
+
+func (c *TypedClockCache[K, V]) Delete(ctx context.Context, key K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
+
+// deleteLocked is Delete with c.mu held.
+func (c *TypedClockCache[K, V]) deleteLocked(key K) error {
+	c.recordDelete()
+	if idx, ok := c.cache[key]; ok {
+		c.remove(idx, EvictReasonDeleted)
+	}
+	return nil
+}
+
+// GetMany looks up every key under a single read lock, then removes the
+// expired ones under a single write lock.
+func (c *TypedClockCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	found := make(map[K]V, len(keys))
+	var expired []K
+
+	c.mu.RLock()
+	now := c.now().UnixNano()
+	for _, key := range keys {
+		idx, ok := c.cache[key]
+		if !ok {
+			c.recordMiss()
+			continue
+		}
+		slot := &c.slots[idx]
+		if slot.expiration > 0 && slot.expiration < now {
+			expired = append(expired, key)
+			c.recordMiss()
+			continue
+		}
+		slot.referenced.Store(true)
+		found[key] = slot.value
+		c.recordHit()
+	}
+	c.mu.RUnlock()
+
+	if len(expired) > 0 {
+		c.mu.Lock()
+		for _, key := range expired {
+			c.removeIfExpired(key)
+		}
+		c.unlock(&c.mu)
+	}
+	return found
+}
+
+// removeIfExpired removes key if it is in the cache and has expired. It is
+// called with c.mu held after a read lock was released, so it checks the
+// entry again.
+func (c *TypedClockCache[K, V]) removeIfExpired(key K) {
+	if idx, ok := c.cache[key]; ok {
+		if s := &c.slots[idx]; s.expiration > 0 && s.expiration < c.now().UnixNano() {
+			c.remove(idx, EvictReasonExpired)
+		}
+	}
+}
+
+// SetMany stores every item under a single lock acquisition.
+func (c *TypedClockCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return c.setLocked(key, item.Value, item.TTL)
+	})
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedClockCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
+
+func (c *TypedClockCache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	for i := range c.slots {
+		if slot := &c.slots[i]; slot.used {
+			c.evicted(slot.key, slot.value, EvictReasonFlushed)
+		}
+	}
+	c.slots = nil
+	c.free = nil
+	c.hand = 0
+	c.cache = make(map[K]int)
+	c.expiries = nil
+	return nil
+}
+
+// Close stops the background janitor, if one was configured.
+func (c *TypedClockCache[K, V]) Close() error {
+	c.janitor.Close()
+	return nil
+}
+
+// deleteExpired removes every entry whose TTL has elapsed.
+func (c *TypedClockCache[K, V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(c.cache[key], EvictReasonExpired)
+	}
+}
+
+// claimSlot returns the index of an empty slot for a new entry, growing the
+// ring until it reaches capacity and then evicting with the hand.
+func (c *TypedClockCache[K, V]) claimSlot() int {
+	if n := len(c.free); n > 0 {
+		idx := c.free[n-1]
+		c.free = c.free[:n-1]
+		return idx
+	}
+	if len(c.slots) < c.capacity {
+		c.slots = append(c.slots, clockSlot[K, V]{})
+		return len(c.slots) - 1
+	}
+
+	for {
+		idx := c.hand
+		c.hand = (c.hand + 1) % len(c.slots)
+		slot := &c.slots[idx]
+		if slot.referenced.Load() {
+			slot.referenced.Store(false)
+			continue
+		}
+		c.remove(idx, EvictReasonCapacity)
+		c.free = c.free[:len(c.free)-1]
+		return idx
+	}
+}
+
+// remove empties the slot at idx and adds it to the free list.
+func (c *TypedClockCache[K, V]) remove(idx int, reason EvictReason) {
+	slot := &c.slots[idx]
+	key, value := slot.key, slot.value
+	c.expiries.untrack(slot.expiry)
+	delete(c.cache, key)
+
+	var zeroKey K
+	var zeroValue V
+	slot.key, slot.value, slot.expiry, slot.used = zeroKey, zeroValue, nil, false
+	c.free = append(c.free, idx)
+	c.evicted(key, value, reason)
+}
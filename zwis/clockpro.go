@@ -0,0 +1,464 @@
+package zwis
+
+/*
+CLOCK-Pro extends CLOCK with the reuse distance tracking of LIRS. Resident entries are either hot or cold, and cold entries go through a test period during which a second access proves they are worth keeping: such entries turn hot. Cold entries evicted during their test period stay in the clock as non-resident metadata, so a key that comes back soon after eviction is recognised and admitted as hot.
+
+Three hands sweep the same clock. HAND_cold evicts cold entries and promotes tested ones, HAND_hot demotes hot entries that have not been referenced since its last pass, and HAND_test ends test periods and drops non-resident entries. The number of resident cold entries the cache aims for adapts: it grows when a test period ends with a re-access and shrinks when one ends without.
+
+The clock is a ring of slots linked by index, holding up to capacity resident entries and as many non-resident ones. Hits only set a reference bit under a read lock.
+*/
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clockProState is the status of an entry in a ClockProCache.
+type clockProState uint8
+
+const (
+	clockProHot clockProState = iota
+	clockProCold
+	clockProNonResident
+)
+
+// TypedClockProCache implements the CLOCK-Pro eviction policy.
+type TypedClockProCache[K comparable, V any] struct {
+	capacity   int
+	coldTarget int // Number of resident cold entries the cache adapts towards
+	hotCount   int
+	coldCount  int
+	testCount  int // Number of non-resident entries
+	slots      []clockProSlot[K, V]
+	free       []int
+	handHot    int // -1 while the clock is empty
+	handCold   int
+	handTest   int
+	cache      map[K]int
+	expiries   expiryQueue[K]
+	janitor    *janitor
+	mu         sync.RWMutex
+	evictionHooks[K, V]
+	timekeeper
+}
+
+// ClockProCache is a TypedClockProCache with string keys and interface{}
+// values.
+type ClockProCache = TypedClockProCache[string, interface{}]
+
+type clockProSlot[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration int64
+	expiry     *expiryEntry[K]
+	prev, next int
+	state      clockProState
+	test       bool // Whether a cold entry is in its test period
+	referenced atomic.Bool
+}
+
+// NewClockProCache creates a CLOCK-Pro cache with the given capacity.
+func NewClockProCache(capacity int, opts ...Option) *ClockProCache {
+	return NewTypedClockProCache[string, interface{}](capacity, opts...)
+}
+
+// NewTypedClockProCache creates a generic CLOCK-Pro cache with the given
+// capacity. A capacity below 1 is treated as 1.
+func NewTypedClockProCache[K comparable, V any](capacity int, opts ...Option) *TypedClockProCache[K, V] {
+	o := newOptions(opts)
+	capacity = max(capacity, 1)
+	c := &TypedClockProCache[K, V]{
+		capacity:   capacity,
+		coldTarget: max(1, capacity-1),
+		handHot:    -1,
+		handCold:   -1,
+		handTest:   -1,
+		cache:      make(map[K]int),
+		timekeeper: newTimekeeper(o),
+	}
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c
+}
+
+func (c *TypedClockProCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	var zero V
+
+	c.mu.RLock()
+	idx, ok := c.cache[key]
+	if !ok || c.slots[idx].state == clockProNonResident {
+		c.mu.RUnlock()
+		c.recordMiss()
+		return zero, false
+	}
+
+	slot := &c.slots[idx]
+	if slot.expiration > 0 && slot.expiration < c.now().UnixNano() {
+		c.mu.RUnlock()
+		c.mu.Lock()
+		// The entry may have been replaced while the lock was released.
+		c.removeIfExpired(key)
+		c.unlock(&c.mu)
+		c.recordMiss()
+		return zero, false
+	}
+
+	slot.referenced.Store(true)
+	value := slot.value
+	c.mu.RUnlock()
+
+	c.recordHit()
+	return value, true
+}
+
+func (c *TypedClockProCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, value, ttl)
+}
+
+// setLocked is Set with c.mu held.
+func (c *TypedClockProCache[K, V]) setLocked(key K, value V, ttl time.Duration) error {
+	expiration := unixNano(c.expiresAt(ttl))
+
+	c.recordSet()
+	idx, ok := c.cache[key]
+	if ok && c.slots[idx].state != clockProNonResident {
+		slot := &c.slots[idx]
+		c.evicted(key, slot.value, EvictReasonReplaced)
+		slot.value = value
+		slot.expiration = expiration
+		slot.expiry = c.expiries.track(slot.expiry, key, expiration)
+		slot.referenced.Store(true)
+		return nil
+	}
+
+	for c.hotCount+c.coldCount >= c.capacity {
+		c.runHandCold()
+	}
+	// Running HAND_cold may have ended the key's test period.
+	idx, ok = c.cache[key]
+
+	if ok {
+		// Re-accessed during its test period: admit it as hot.
+		c.coldTarget = min(c.coldTarget+1, max(1, c.capacity-1))
+		c.unlink(idx)
+		c.testCount--
+		c.slots[idx].state = clockProHot
+		c.slots[idx].test = false
+		c.hotCount++
+	} else {
+		idx = c.claimSlot()
+		c.slots[idx].key = key
+		c.slots[idx].state = clockProCold
+		c.slots[idx].test = true
+		c.coldCount++
+		c.cache[key] = idx
+	}
+
+	slot := &c.slots[idx]
+	slot.value = value
+	slot.expiration = expiration
+	slot.expiry = c.expiries.track(nil, key, expiration)
+	slot.referenced.Store(false)
+	c.link(idx)
+	c.recordAdded()
+
+	for c.hotCount > c.capacity-c.coldTarget {
+		c.runHandHot()
+	}
+	return nil
+}
+
+func (c *TypedClockProCache[K, V]) Delete(ctx context.Context, key K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
+
+// deleteLocked is Delete with c.mu held.
+func (c *TypedClockProCache[K, V]) deleteLocked(key K) error {
+	c.recordDelete()
+	if idx, ok := c.cache[key]; ok && c.slots[idx].state != clockProNonResident {
+		c.remove(idx, EvictReasonDeleted)
+	}
+	return nil
+}
+
+// GetMany looks up every key under a single read lock, then removes the
+// expired ones under a single write lock.
+func (c *TypedClockProCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	found := make(map[K]V, len(keys))
+	var expired []K
+
+	c.mu.RLock()
+	now := c.now().UnixNano()
+	for _, key := range keys {
+		idx, ok := c.cache[key]
+		if !ok || c.slots[idx].state == clockProNonResident {
+			c.recordMiss()
+			continue
+		}
+		slot := &c.slots[idx]
+		if slot.expiration > 0 && slot.expiration < now {
+			expired = append(expired, key)
+			c.recordMiss()
+			continue
+		}
+		slot.referenced.Store(true)
+		found[key] = slot.value
+		c.recordHit()
+	}
+	c.mu.RUnlock()
+
+	if len(expired) > 0 {
+		c.mu.Lock()
+		for _, key := range expired {
+			c.removeIfExpired(key)
+		}
+		c.unlock(&c.mu)
+	}
+	return found
+}
+
+// removeIfExpired removes key if it is in the cache and has expired. It is
+// called with c.mu held after a read lock was released, so it checks the
+// entry again.
+func (c *TypedClockProCache[K, V]) removeIfExpired(key K) {
+	if idx, ok := c.cache[key]; ok {
+		if s := &c.slots[idx]; s.state != clockProNonResident && s.expiration > 0 && s.expiration < c.now().UnixNano() {
+			c.remove(idx, EvictReasonExpired)
+		}
+	}
+}
+
+// SetMany stores every item under a single lock acquisition.
+func (c *TypedClockProCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return c.setLocked(key, item.Value, item.TTL)
+	})
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedClockProCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
+
+func (c *TypedClockProCache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	for _, idx := range c.cache {
+		if slot := &c.slots[idx]; slot.state != clockProNonResident {
+			c.evicted(slot.key, slot.value, EvictReasonFlushed)
+		}
+	}
+	c.slots = nil
+	c.free = nil
+	c.handHot, c.handCold, c.handTest = -1, -1, -1
+	c.hotCount, c.coldCount, c.testCount = 0, 0, 0
+	c.coldTarget = max(1, c.capacity-1)
+	c.cache = make(map[K]int)
+	c.expiries = nil
+	return nil
+}
+
+// Close stops the background janitor, if one was configured.
+func (c *TypedClockProCache[K, V]) Close() error {
+	c.janitor.Close()
+	return nil
+}
+
+// deleteExpired removes every entry whose TTL has elapsed.
+func (c *TypedClockProCache[K, V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(c.cache[key], EvictReasonExpired)
+	}
+}
+
+// runHandCold advances HAND_cold until it has evicted one resident cold
+// entry. Referenced cold entries it passes are promoted to hot if they are
+// in their test period and start a new one otherwise.
+func (c *TypedClockProCache[K, V]) runHandCold() {
+	for {
+		idx := c.handCold
+		slot := &c.slots[idx]
+		if slot.state != clockProCold {
+			c.handCold = slot.next
+			continue
+		}
+
+		if slot.referenced.Load() {
+			slot.referenced.Store(false)
+			c.unlink(idx)
+			if slot.test {
+				c.coldTarget = min(c.coldTarget+1, max(1, c.capacity-1))
+				slot.state = clockProHot
+				slot.test = false
+				c.coldCount--
+				c.hotCount++
+			} else {
+				slot.test = true
+			}
+			c.link(idx)
+			for c.hotCount > c.capacity-c.coldTarget {
+				c.runHandHot()
+			}
+			continue
+		}
+
+		c.handCold = slot.next
+		if !slot.test {
+			c.remove(idx, EvictReasonCapacity)
+			return
+		}
+
+		// Keep the key as a non-resident entry until its test period ends.
+		c.expiries.untrack(slot.expiry)
+		key, value := slot.key, slot.value
+		var zero V
+		slot.value, slot.expiry, slot.expiration = zero, nil, 0
+		slot.state = clockProNonResident
+		c.coldCount--
+		c.testCount++
+		c.evicted(key, value, EvictReasonCapacity)
+		for c.testCount > c.capacity {
+			c.runHandTest()
+		}
+		return
+	}
+}
+
+// runHandHot advances HAND_hot until it has demoted one unreferenced hot
+// entry to cold, ending the test periods of the cold entries it passes.
+func (c *TypedClockProCache[K, V]) runHandHot() {
+	for {
+		idx := c.handHot
+		slot := &c.slots[idx]
+		c.handHot = slot.next
+
+		switch slot.state {
+		case clockProHot:
+			if slot.referenced.Load() {
+				slot.referenced.Store(false)
+				continue
+			}
+			slot.state = clockProCold
+			c.hotCount--
+			c.coldCount++
+			return
+		default:
+			c.endTest(idx)
+		}
+	}
+}
+
+// runHandTest advances HAND_test until it has dropped one non-resident
+// entry, ending the test periods of the cold entries it passes.
+func (c *TypedClockProCache[K, V]) runHandTest() {
+	for {
+		idx := c.handTest
+		slot := &c.slots[idx]
+		c.handTest = slot.next
+		if slot.state == clockProNonResident {
+			c.endTest(idx)
+			return
+		}
+		c.endTest(idx)
+	}
+}
+
+// endTest ends the test period of a cold entry without a re-access,
+// shrinking the cold target. Non-resident entries are dropped.
+func (c *TypedClockProCache[K, V]) endTest(idx int) {
+	slot := &c.slots[idx]
+	switch {
+	case slot.state == clockProNonResident:
+		c.coldTarget = max(c.coldTarget-1, 1)
+		delete(c.cache, slot.key)
+		c.testCount--
+		c.release(idx)
+	case slot.state == clockProCold && slot.test:
+		c.coldTarget = max(c.coldTarget-1, 1)
+		slot.test = false
+	}
+}
+
+// claimSlot returns the index of an unlinked slot for a new entry.
+func (c *TypedClockProCache[K, V]) claimSlot() int {
+	if n := len(c.free); n > 0 {
+		idx := c.free[n-1]
+		c.free = c.free[:n-1]
+		return idx
+	}
+	c.slots = append(c.slots, clockProSlot[K, V]{})
+	return len(c.slots) - 1
+}
+
+// link inserts the slot at the head of the clock, just behind HAND_hot,
+// where every hand reaches it last.
+func (c *TypedClockProCache[K, V]) link(idx int) {
+	slot := &c.slots[idx]
+	if c.handHot < 0 {
+		slot.prev, slot.next = idx, idx
+		c.handHot, c.handCold, c.handTest = idx, idx, idx
+		return
+	}
+	next := c.handHot
+	prev := c.slots[next].prev
+	slot.prev, slot.next = prev, next
+	c.slots[prev].next = idx
+	c.slots[next].prev = idx
+}
+
+// unlink takes the slot out of the clock, moving any hand that points at
+// it on to the next slot.
+func (c *TypedClockProCache[K, V]) unlink(idx int) {
+	slot := &c.slots[idx]
+	if slot.next == idx {
+		c.handHot, c.handCold, c.handTest = -1, -1, -1
+		return
+	}
+	for _, hand := range []*int{&c.handHot, &c.handCold, &c.handTest} {
+		if *hand == idx {
+			*hand = slot.next
+		}
+	}
+	c.slots[slot.prev].next = slot.next
+	c.slots[slot.next].prev = slot.prev
+}
+
+// release unlinks a slot and adds it to the free list.
+func (c *TypedClockProCache[K, V]) release(idx int) {
+	c.unlink(idx)
+	var zeroKey K
+	var zeroValue V
+	slot := &c.slots[idx]
+	slot.key, slot.value, slot.expiry, slot.test = zeroKey, zeroValue, nil, false
+	c.free = append(c.free, idx)
+}
+
+// remove drops a resident entry from the cache entirely.
+func (c *TypedClockProCache[K, V]) remove(idx int, reason EvictReason) {
+	slot := &c.slots[idx]
+	key, value := slot.key, slot.value
+	if slot.state == clockProHot {
+		c.hotCount--
+	} else {
+		c.coldCount--
+	}
+	c.expiries.untrack(slot.expiry)
+	delete(c.cache, key)
+	c.release(idx)
+	c.evicted(key, value, reason)
+}
@@ -0,0 +1,47 @@
+package zwis
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+)
+
+// Codec converts values to and from bytes for caches that keep them outside
+// of process memory.
+type Codec[V any] interface {
+	Encode(value V) ([]byte, error)
+	Decode(data []byte) (V, error)
+}
+
+// GobCodec encodes values with encoding/gob. Concrete types stored behind an
+// interface{} value must be registered with gob.Register first.
+type GobCodec[V any] struct{}
+
+func (GobCodec[V]) Encode(value V) ([]byte, error) {
+	var buf bytes.Buffer
+	// Encoding through a pointer lets gob handle interface-typed values.
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[V]) Decode(data []byte) (V, error) {
+	var value V
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// resolveCodec returns the codec set with WithCodec or WithKeyCodec, or a
+// GobCodec when none was set.
+func resolveCodec[V any](configured interface{}) (Codec[V], error) {
+	if configured == nil {
+		return GobCodec[V]{}, nil
+	}
+	codec, ok := configured.(Codec[V])
+	if !ok {
+		return nil, fmt.Errorf("zwis: codec %T does not encode %v values", configured, reflect.TypeOf((*V)(nil)).Elem())
+	}
+	return codec, nil
+}
@@ -0,0 +1,101 @@
+package zwis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// Weigher returns the cost of an entry, such as its size in bytes.
+type Weigher[K comparable, V any] func(key K, value V) int64
+
+// TypedCostCache is a cache that can bound the total cost of its entries
+// rather than only their number.
+type TypedCostCache[K comparable, V any] interface {
+	TypedCache[K, V]
+	// SetWithCost stores a value with an explicit cost, overriding the
+	// cache's weigher. It returns ErrEntryTooLarge if cost exceeds the whole
+	// budget.
+	SetWithCost(ctx context.Context, key K, value V, cost int64, ttl time.Duration) error
+	// Cost returns the total cost of the entries currently cached.
+	Cost() int64
+}
+
+// CostCache is a TypedCostCache with string keys and interface{} values.
+type CostCache = TypedCostCache[string, interface{}]
+
+// costBudget tracks the total cost of a cache's entries against the budget
+// set with WithMaxCost. Without a budget costs are still tracked but never
+// cause evictions.
+type costBudget[K comparable, V any] struct {
+	maxCost    int64
+	used       atomic.Int64
+	weigher    Weigher[K, V]
+	weigherErr error
+}
+
+// configureCost sets the budget and weigher from the cache's options.
+func (b *costBudget[K, V]) configureCost(o options) {
+	b.maxCost = o.maxCost
+	if o.weigher != nil {
+		weigher, ok := o.weigher.(Weigher[K, V])
+		if !ok {
+			b.weigherErr = fmt.Errorf("zwis: weigher %T does not weigh %v keys and %v values", o.weigher,
+				reflect.TypeOf((*K)(nil)).Elem(), reflect.TypeOf((*V)(nil)).Elem())
+		}
+		b.weigher = weigher
+	}
+}
+
+// Cost returns the total cost of the entries currently cached.
+func (b *costBudget[K, V]) Cost() int64 {
+	return b.used.Load()
+}
+
+// costOf weighs an entry with the configured weigher. Entries cost 1 when
+// no weigher is set.
+func (b *costBudget[K, V]) costOf(key K, value V) int64 {
+	if b.weigher == nil {
+		return 1
+	}
+	return b.weigher(key, value)
+}
+
+// checkCost returns an error if an entry of the given cost can never be
+// stored.
+func (b *costBudget[K, V]) checkCost(cost int64) error {
+	if b.weigherErr != nil {
+		return b.weigherErr
+	}
+	if b.maxCost > 0 && cost > b.maxCost {
+		return ErrEntryTooLarge
+	}
+	return nil
+}
+
+// overBudget reports whether adding extra to the current cost would exceed
+// the budget.
+func (b *costBudget[K, V]) overBudget(extra int64) bool {
+	return b.maxCost > 0 && b.used.Load()+extra > b.maxCost
+}
+
+func (b *costBudget[K, V]) charge(delta int64) {
+	b.used.Add(delta)
+}
+
+// fitNewest returns the longest suffix of entries whose total cost fits in
+// what is left of the budget, and charges it. Restore uses it to keep the
+// most recently or frequently used entries of a snapshot.
+func (b *costBudget[K, V]) fitNewest(entries []snapshotEntry[K, V]) []snapshotEntry[K, V] {
+	i := len(entries)
+	for ; i > 0; i-- {
+		cost := b.costOf(entries[i-1].key, entries[i-1].val)
+		if b.overBudget(cost) {
+			break
+		}
+		b.charge(cost)
+	}
+	return entries[i:]
+}
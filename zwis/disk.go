@@ -0,0 +1,613 @@
+package zwis
+
+/*
+DiskCache keeps entries in an append-only log file under a directory, together with an in-memory index from each key to the position of its latest record. Every Set appends a record and every Delete or eviction appends a tombstone, so reopening the directory replays the log to rebuild the index. Records carry a checksum, and a torn or corrupt tail left behind by a crash is truncated on reopen. Records that are no longer referenced are reclaimed by compaction, which rewrites the live records into a fresh log.
+*/
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	diskLogName          = "zwis.log"
+	diskRecordHeaderSize = 21 // crc32, kind, expiration, key length, value length
+	diskMaxFieldSize     = 1 << 30
+	diskMinCompactBytes  = 64 << 10
+)
+
+const (
+	diskRecordSet    byte = 1
+	diskRecordDelete byte = 2
+)
+
+// ErrEntryTooLarge is returned when a single entry can never fit within a
+// cache's size budget.
+var ErrEntryTooLarge = errors.New("zwis: entry is larger than the cache's size budget")
+
+// TypedDiskCache is a disk-backed cache with string keys. Values are
+// serialised with a Codec, GobCodec by default.
+type TypedDiskCache[V any] struct {
+	dir      string
+	file     *os.File
+	codec    Codec[V]
+	capacity int   // Maximum number of entries, 0 for no limit
+	maxSize  int64 // Maximum length of the log, 0 for no limit
+	index    map[string]*list.Element
+	lru      *list.List
+	size     int64 // Length of the log
+	live     int64 // Bytes of the log still referenced by the index
+	expiries expiryQueue[string]
+	janitor  *janitor
+	mu       sync.Mutex
+	evictionHooks[string, V]
+	timekeeper
+}
+
+// DiskCache is a TypedDiskCache with interface{} values.
+type DiskCache = TypedDiskCache[interface{}]
+
+// diskEntry locates the latest record for a key in the log.
+type diskEntry struct {
+	key        string
+	offset     int64 // Start of the record
+	size       int64 // Length of the whole record
+	valueLen   int
+	expiration int64
+	expiry     *expiryEntry[string]
+}
+
+// diskRecord is a record decoded from the log.
+type diskRecord struct {
+	kind       byte
+	expiration int64
+	key        string
+	valueLen   int
+}
+
+// NewDiskCache opens or creates a disk cache in dir. capacity limits the
+// number of entries and WithMaxDiskSize limits the bytes they occupy; 0
+// means no limit. Either limit evicts the least recently used entries.
+func NewDiskCache(dir string, capacity int, opts ...Option) (*DiskCache, error) {
+	return NewTypedDiskCache[interface{}](dir, capacity, opts...)
+}
+
+// NewTypedDiskCache opens or creates a disk cache in dir. capacity limits the
+// number of entries and WithMaxDiskSize limits the bytes they occupy; 0
+// means no limit. Either limit evicts the least recently used entries.
+func NewTypedDiskCache[V any](dir string, capacity int, opts ...Option) (*TypedDiskCache[V], error) {
+	o := newOptions(opts)
+	if dir == "" {
+		return nil, errors.New("zwis: disk cache requires a directory")
+	}
+
+	codec, err := resolveCodec[V](o.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	// A leftover compaction file means a compaction was interrupted before
+	// it replaced the log, so the log itself is still authoritative.
+	os.Remove(filepath.Join(dir, diskLogName+".compact"))
+
+	file, err := os.OpenFile(filepath.Join(dir, diskLogName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &TypedDiskCache[V]{
+		dir:        dir,
+		file:       file,
+		codec:      codec,
+		capacity:   capacity,
+		maxSize:    o.maxDiskSize,
+		index:      make(map[string]*list.Element),
+		lru:        list.New(),
+		timekeeper: newTimekeeper(o),
+	}
+	if err := c.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c, nil
+}
+
+func (c *TypedDiskCache[V]) Get(ctx context.Context, key string) (V, bool) {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.getLocked(key)
+}
+
+// getLocked is Get with c.mu held.
+func (c *TypedDiskCache[V]) getLocked(key string) (V, bool) {
+	var zero V
+	elem, ok := c.index[key]
+	if !ok {
+		c.recordMiss()
+		return zero, false
+	}
+
+	entry := elem.Value.(*diskEntry)
+	if entry.expiration > 0 && entry.expiration < c.now().UnixNano() {
+		c.remove(elem, EvictReasonExpired)
+		c.recordMiss()
+		return zero, false
+	}
+
+	value, err := c.readValue(entry)
+	if err != nil {
+		c.recordMiss()
+		return zero, false
+	}
+
+	c.lru.MoveToFront(elem)
+	c.recordHit()
+	return value, true
+}
+
+func (c *TypedDiskCache[V]) Set(ctx context.Context, key string, value V, ttl time.Duration) error {
+	record, valueLen, expiration, err := c.encode(key, value, ttl)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, record, valueLen, expiration)
+}
+
+// encode serialises a Set of key into a log record, without locking. It
+// returns the record, the length of the encoded value and the expiration.
+func (c *TypedDiskCache[V]) encode(key string, value V, ttl time.Duration) ([]byte, int, int64, error) {
+	expiration := unixNano(c.expiresAt(ttl))
+
+	data, err := c.codec.Encode(value)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	record := encodeDiskRecord(diskRecordSet, key, data, expiration)
+	if c.maxSize > 0 && int64(len(record)) > c.maxSize {
+		return nil, 0, 0, ErrEntryTooLarge
+	}
+	return record, len(data), expiration, nil
+}
+
+// setLocked appends an encoded record for key and indexes it. c.mu must be
+// held.
+func (c *TypedDiskCache[V]) setLocked(key string, record []byte, valueLen int, expiration int64) error {
+	offset, err := c.append(record)
+	if err != nil {
+		return err
+	}
+
+	c.recordSet()
+	if elem, ok := c.index[key]; ok {
+		old := elem.Value.(*diskEntry)
+		if c.hasListeners() {
+			if oldValue, err := c.readValue(old); err == nil {
+				c.evicted(key, oldValue, EvictReasonReplaced)
+			}
+		}
+		c.live -= old.size
+		old.offset, old.size, old.valueLen = offset, int64(len(record)), valueLen
+		old.expiration = expiration
+		old.expiry = c.expiries.track(old.expiry, key, expiration)
+		c.live += old.size
+		c.lru.MoveToFront(elem)
+	} else {
+		entry := &diskEntry{key: key, offset: offset, size: int64(len(record)), valueLen: valueLen, expiration: expiration}
+		entry.expiry = c.expiries.track(nil, key, expiration)
+		c.index[key] = c.lru.PushFront(entry)
+		c.live += entry.size
+		c.recordAdded()
+	}
+
+	c.enforceLimits()
+	return c.maybeCompact()
+}
+
+func (c *TypedDiskCache[V]) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
+
+// deleteLocked is Delete with c.mu held.
+func (c *TypedDiskCache[V]) deleteLocked(key string) error {
+	c.recordDelete()
+	if elem, ok := c.index[key]; ok {
+		return c.remove(elem, EvictReasonDeleted)
+	}
+	return nil
+}
+
+// GetMany looks up every key under a single lock acquisition.
+func (c *TypedDiskCache[V]) GetMany(ctx context.Context, keys []string) map[string]V {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return getMany(keys, c.getLocked)
+}
+
+// SetMany encodes every item and then appends them all to the log under a
+// single lock acquisition.
+func (c *TypedDiskCache[V]) SetMany(ctx context.Context, items map[string]TypedItem[V]) error {
+	type encoded struct {
+		key        string
+		record     []byte
+		valueLen   int
+		expiration int64
+	}
+	var firstErr error
+	records := make([]encoded, 0, len(items))
+	for key, item := range items {
+		record, valueLen, expiration, err := c.encode(key, item.Value, item.TTL)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		records = append(records, encoded{key, record, valueLen, expiration})
+	}
+
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	for _, r := range records {
+		if err := c.setLocked(r.key, r.record, r.valueLen, r.expiration); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedDiskCache[V]) DeleteMany(ctx context.Context, keys []string) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
+
+// Flush removes every entry and truncates the log.
+func (c *TypedDiskCache[V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	for elem := c.lru.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*diskEntry)
+		var value V
+		if c.hasListeners() {
+			value, _ = c.readValue(entry)
+		}
+		c.evicted(entry.key, value, EvictReasonFlushed)
+	}
+
+	if err := c.file.Truncate(0); err != nil {
+		return err
+	}
+	c.index = make(map[string]*list.Element)
+	c.lru.Init()
+	c.expiries = nil
+	c.size, c.live = 0, 0
+	return nil
+}
+
+// Compact rewrites the log so that it only contains live records.
+func (c *TypedDiskCache[V]) Compact() error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	return c.compact()
+}
+
+// DiskSize returns the current length of the log file in bytes.
+func (c *TypedDiskCache[V]) DiskSize() int64 {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	return c.size
+}
+
+// Close stops the background janitor, if one was configured, and closes the
+// log file.
+func (c *TypedDiskCache[V]) Close() error {
+	c.janitor.Close()
+
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	if err := c.file.Sync(); err != nil {
+		c.file.Close()
+		return err
+	}
+	return c.file.Close()
+}
+
+// deleteExpired removes every entry whose TTL has elapsed.
+func (c *TypedDiskCache[V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(c.index[key], EvictReasonExpired)
+	}
+}
+
+// load replays the log to rebuild the index, truncating a torn or corrupt
+// tail.
+func (c *TypedDiskCache[V]) load() error {
+	info, err := c.file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := c.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	now := c.now().UnixNano()
+	r := bufio.NewReader(c.file)
+	var offset int64
+	for {
+		record, n, err := readDiskRecord(r, info.Size()-offset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if err := c.file.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+
+		if elem, ok := c.index[record.key]; ok {
+			old := elem.Value.(*diskEntry)
+			c.expiries.untrack(old.expiry)
+			c.lru.Remove(elem)
+			delete(c.index, record.key)
+			c.live -= old.size
+		}
+		if record.kind == diskRecordSet && (record.expiration == 0 || record.expiration >= now) {
+			entry := &diskEntry{key: record.key, offset: offset, size: n, valueLen: record.valueLen, expiration: record.expiration}
+			entry.expiry = c.expiries.track(nil, record.key, record.expiration)
+			c.index[record.key] = c.lru.PushFront(entry)
+			c.live += n
+		}
+		offset += n
+	}
+
+	c.size = offset
+	c.entries.Store(int64(len(c.index)))
+	c.enforceLimits()
+	return c.maybeCompact()
+}
+
+// append writes a record at the end of the log and returns its offset,
+// first making room if the record would take the log past its size limit.
+func (c *TypedDiskCache[V]) append(record []byte) (int64, error) {
+	if c.maxSize > 0 && c.size+int64(len(record)) > c.maxSize {
+		if err := c.makeRoom(int64(len(record))); err != nil {
+			return 0, err
+		}
+	}
+	offset := c.size
+	if _, err := c.file.WriteAt(record, offset); err != nil {
+		return 0, err
+	}
+	c.size += int64(len(record))
+	return offset, nil
+}
+
+// readValue reads and decodes the value of an entry.
+func (c *TypedDiskCache[V]) readValue(entry *diskEntry) (V, error) {
+	data := make([]byte, entry.valueLen)
+	valueOffset := entry.offset + diskRecordHeaderSize + int64(len(entry.key))
+	if _, err := c.file.ReadAt(data, valueOffset); err != nil {
+		var zero V
+		return zero, err
+	}
+	return c.codec.Decode(data)
+}
+
+// remove drops an entry from the index. Deletions and evictions write a
+// tombstone so the entry stays gone after reopening; expired records are
+// skipped on replay anyway.
+func (c *TypedDiskCache[V]) remove(elem *list.Element, reason EvictReason) error {
+	entry := c.drop(elem, reason)
+	if reason == EvictReasonExpired {
+		return nil
+	}
+	_, err := c.append(encodeDiskRecord(diskRecordDelete, entry.key, nil, 0))
+	return err
+}
+
+// drop removes an entry from the index and reports its eviction without
+// writing a tombstone.
+func (c *TypedDiskCache[V]) drop(elem *list.Element, reason EvictReason) *diskEntry {
+	entry := elem.Value.(*diskEntry)
+
+	var value V
+	if c.hasListeners() {
+		value, _ = c.readValue(entry)
+	}
+
+	c.lru.Remove(elem)
+	delete(c.index, entry.key)
+	c.expiries.untrack(entry.expiry)
+	c.live -= entry.size
+	c.evicted(entry.key, value, reason)
+	return entry
+}
+
+// enforceLimits evicts least recently used entries until both the entry and
+// size limits are respected.
+func (c *TypedDiskCache[V]) enforceLimits() {
+	for c.lru.Len() > 0 && ((c.capacity > 0 && c.lru.Len() > c.capacity) || (c.maxSize > 0 && c.live > c.maxSize)) {
+		c.remove(c.lru.Back(), EvictReasonCapacity)
+	}
+}
+
+// maybeCompact compacts the log once dead records outweigh live ones, or
+// once it is longer than the size limit, as it can be after reopening with a
+// smaller one.
+func (c *TypedDiskCache[V]) maybeCompact() error {
+	if c.maxSize > 0 && c.size > c.maxSize {
+		return c.makeRoom(0)
+	}
+	dead := c.size - c.live
+	if dead < diskMinCompactBytes || dead < c.live {
+		return nil
+	}
+	return c.compact()
+}
+
+// makeRoom evicts least recently used entries until live records and n more
+// bytes leave an eighth of the size limit free, then compacts the log.
+// The headroom keeps a cache that is full of live records from compacting on
+// every write. Evicted entries need no tombstones, since compaction drops
+// their records.
+func (c *TypedDiskCache[V]) makeRoom(n int64) error {
+	for c.lru.Len() > 0 && c.live+n > c.maxSize-c.maxSize/8 {
+		c.drop(c.lru.Back(), EvictReasonCapacity)
+	}
+	return c.compact()
+}
+
+// compact copies the live records, least recently used first so that replay
+// restores their order, into a new log that then replaces the current one.
+func (c *TypedDiskCache[V]) compact() error {
+	path := filepath.Join(c.dir, diskLogName)
+	tmpPath := path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	offsets := make([]int64, 0, c.lru.Len())
+	var offset int64
+	for elem := c.lru.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*diskEntry)
+		record := make([]byte, entry.size)
+		if _, err := c.file.ReadAt(record, entry.offset); err != nil {
+			return fail(err)
+		}
+		if _, err := w.Write(record); err != nil {
+			return fail(err)
+		}
+		offsets = append(offsets, offset)
+		offset += entry.size
+	}
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fail(err)
+	}
+
+	// The compacted file is the log from here on, whatever happens next.
+	c.file.Close()
+	c.file = tmp
+	i := 0
+	for elem := c.lru.Back(); elem != nil; elem = elem.Prev() {
+		elem.Value.(*diskEntry).offset = offsets[i]
+		i++
+	}
+	c.size, c.live = offset, offset
+
+	// Sync the directory too, or the rename itself may not survive a crash.
+	return syncDir(c.dir)
+}
+
+// syncDir flushes a directory's entries to disk.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// encodeDiskRecord lays out a record as: crc32 of the rest of the record,
+// kind, expiration, key length, value length, key, value.
+func encodeDiskRecord(kind byte, key string, value []byte, expiration int64) []byte {
+	record := make([]byte, diskRecordHeaderSize+len(key)+len(value))
+	record[4] = kind
+	binary.LittleEndian.PutUint64(record[5:], uint64(expiration))
+	binary.LittleEndian.PutUint32(record[13:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(record[17:], uint32(len(value)))
+	copy(record[diskRecordHeaderSize:], key)
+	copy(record[diskRecordHeaderSize+len(key):], value)
+	binary.LittleEndian.PutUint32(record, crc32.ChecksumIEEE(record[4:]))
+	return record
+}
+
+// readDiskRecord reads the next record, which can be at most limit bytes
+// long, and returns it with its length. It returns io.EOF at a clean end of
+// the log and another error for a torn or corrupt record.
+func readDiskRecord(r io.Reader, limit int64) (diskRecord, int64, error) {
+	header := make([]byte, diskRecordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return diskRecord{}, 0, errors.New("zwis: torn record header")
+		}
+		return diskRecord{}, 0, err
+	}
+
+	keyLen := binary.LittleEndian.Uint32(header[13:])
+	valueLen := binary.LittleEndian.Uint32(header[17:])
+	if keyLen > diskMaxFieldSize || valueLen > diskMaxFieldSize {
+		return diskRecord{}, 0, errors.New("zwis: corrupt record header")
+	}
+	// Check the lengths against what is left of the log before trusting
+	// them with an allocation; the checksum can only be verified after.
+	if int64(keyLen)+int64(valueLen) > limit-diskRecordHeaderSize {
+		return diskRecord{}, 0, errors.New("zwis: torn record")
+	}
+
+	payload := make([]byte, int(keyLen)+int(valueLen))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return diskRecord{}, 0, errors.New("zwis: torn record")
+	}
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(payload)
+	if crc.Sum32() != binary.LittleEndian.Uint32(header) {
+		return diskRecord{}, 0, errors.New("zwis: record checksum mismatch")
+	}
+
+	record := diskRecord{
+		kind:       header[4],
+		expiration: int64(binary.LittleEndian.Uint64(header[5:])),
+		key:        string(payload[:keyLen]),
+		valueLen:   int(valueLen),
+	}
+	return record, int64(len(header) + len(payload)), nil
+}
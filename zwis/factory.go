@@ -2,28 +2,174 @@ package zwis
 
 import (
 	"fmt"
+	"reflect"
 )
 
 type CacheType string
 
 const (
-	MemoryCacheType CacheType = "memory"
-	LRUCacheType    CacheType = "lru"
-	LFUCacheType    CacheType = "lfu"
-	ARCCacheType    CacheType = "arc"
+	MemoryCacheType   CacheType = "memory"
+	LRUCacheType      CacheType = "lru"
+	LFUCacheType      CacheType = "lfu"
+	ARCCacheType      CacheType = "arc"
+	DiskCacheType     CacheType = "disk"
+	TinyLFUCacheType  CacheType = "tinylfu"
+	SIEVECacheType    CacheType = "sieve"
+	S3FIFOCacheType   CacheType = "s3fifo"
+	TwoQueueCacheType CacheType = "2q"
+	SLRUCacheType     CacheType = "slru"
+	ClockCacheType    CacheType = "clock"
+	ClockProCacheType CacheType = "clockpro"
+	RedisCacheType    CacheType = "redis"
 )
 
-func NewCache(cacheType CacheType, capacity int) (Cache, error) {
+// NewCache creates a string-keyed, interface{}-valued cache of the given type,
+// configured by opts. Every type except MemoryCacheType, DiskCacheType and
+// RedisCacheType needs a positive WithCapacity; LRU and LFU caches may use
+// WithMaxCost instead. Memory caches are unbounded unless given either.
+// Redis caches need WithRedisAddress and leave capacity to the server.
+func NewCache(cacheType CacheType, opts ...Option) (Cache, error) {
+	return NewTypedCache[string, interface{}](cacheType, opts...)
+}
+
+// NewTypedCache creates a cache of the given type with the key and value types
+// given by the type parameters, configured by opts. It returns an error
+// rather than a cache if the options are invalid for the type.
+func NewTypedCache[K comparable, V any](cacheType CacheType, opts ...Option) (TypedCache[K, V], error) {
+	o := newOptions(opts)
+	if err := validateOptions[K, V](cacheType, o); err != nil {
+		return nil, err
+	}
+
+	capacity := o.capacity
 	switch cacheType {
 	case MemoryCacheType:
-		return NewMemoryCache(), nil
+		return NewTypedMemoryCache[K, V](opts...), nil
 	case LRUCacheType:
-		return NewLRUCache(capacity), nil
+		return NewTypedLRUCache[K, V](capacity, opts...), nil
 	case LFUCacheType:
-		return NewLFUCache(capacity), nil
+		return NewTypedLFUCache[K, V](capacity, opts...), nil
 	case ARCCacheType:
-		return NewARCCache(capacity), nil
+		return NewTypedARCCache[K, V](capacity, opts...), nil
+	case TinyLFUCacheType:
+		return NewTypedTinyLFUCache[K, V](capacity, opts...), nil
+	case SIEVECacheType:
+		return NewTypedSIEVECache[K, V](capacity, opts...), nil
+	case S3FIFOCacheType:
+		return NewTypedS3FIFOCache[K, V](capacity, opts...), nil
+	case TwoQueueCacheType:
+		return NewTypedTwoQueueCache[K, V](capacity, opts...), nil
+	case SLRUCacheType:
+		return NewTypedSLRUCache[K, V](capacity, opts...), nil
+	case ClockCacheType:
+		return NewTypedClockCache[K, V](capacity, opts...), nil
+	case ClockProCacheType:
+		return NewTypedClockProCache[K, V](capacity, opts...), nil
+	case DiskCacheType:
+		return newTypedDiskCacheFor[K, V](capacity, opts...)
+	case RedisCacheType:
+		return newTypedRedisCacheFor[K, V](opts...)
 	default:
 		return nil, fmt.Errorf("unknown cache type: %s", cacheType)
 	}
 }
+
+// validateOptions checks that the options make sense for the cache type, so
+// NewCache never builds a cache that silently ignores part of its
+// configuration.
+func validateOptions[K comparable, V any](cacheType CacheType, o options) error {
+	costAware := cacheType == MemoryCacheType || cacheType == LRUCacheType || cacheType == LFUCacheType || cacheType == ARCCacheType
+
+	switch {
+	case o.capacity < 0:
+		return fmt.Errorf("invalid capacity: %d", o.capacity)
+	case o.maxCost < 0:
+		return fmt.Errorf("invalid max cost: %d", o.maxCost)
+	case o.defaultTTL < 0:
+		return fmt.Errorf("invalid default TTL: %v", o.defaultTTL)
+	case o.janitorInterval < 0:
+		return fmt.Errorf("invalid janitor interval: %v", o.janitorInterval)
+	}
+
+	switch cacheType {
+	case MemoryCacheType, DiskCacheType:
+	case RedisCacheType:
+		switch {
+		case o.redisAddress == "":
+			return fmt.Errorf("%s caches need WithRedisAddress", cacheType)
+		case o.capacity > 0:
+			return fmt.Errorf("%s caches do not support WithCapacity; set maxmemory on the server", cacheType)
+		case o.onEvict != nil:
+			return fmt.Errorf("%s caches do not support eviction callbacks", cacheType)
+		case o.janitorInterval > 0:
+			return fmt.Errorf("%s caches expire entries on the server and take no janitor", cacheType)
+		}
+	case LRUCacheType, LFUCacheType:
+		if o.capacity == 0 && o.maxCost == 0 {
+			return fmt.Errorf("%s caches need WithCapacity or WithMaxCost", cacheType)
+		}
+	default:
+		if o.capacity == 0 {
+			return fmt.Errorf("%s caches need WithCapacity", cacheType)
+		}
+	}
+
+	if !costAware && (o.maxCost > 0 || o.weigher != nil) {
+		return fmt.Errorf("%s caches do not support cost-based capacity", cacheType)
+	}
+	if o.evictionStrategy != 0 {
+		if cacheType != MemoryCacheType {
+			return fmt.Errorf("%s caches do not take an eviction strategy", cacheType)
+		}
+		if o.evictionStrategy < EvictAllKeysRandom || o.evictionStrategy > EvictVolatileTTL {
+			return fmt.Errorf("invalid eviction strategy: %d", o.evictionStrategy)
+		}
+	}
+	if o.weigher != nil {
+		if _, ok := o.weigher.(Weigher[K, V]); !ok {
+			return fmt.Errorf("weigher %T does not match the cache's key and value types", o.weigher)
+		}
+	}
+	if o.onEvict != nil {
+		if _, ok := o.onEvict.(EvictFunc[K, V]); !ok {
+			return fmt.Errorf("eviction callback %T does not match the cache's key and value types", o.onEvict)
+		}
+	}
+	if _, err := resolveCodec[V](o.codec); err != nil {
+		return err
+	}
+	if _, err := resolveCodec[K](o.keyCodec); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newTypedDiskCacheFor creates a disk cache in the directory given by
+// WithDirectory. Disk caches only support string keys.
+func newTypedDiskCacheFor[K comparable, V any](capacity int, opts ...Option) (TypedCache[K, V], error) {
+	cache, err := NewTypedDiskCache[V](newOptions(opts).directory, capacity, opts...)
+	if err != nil {
+		return nil, err
+	}
+	typed, ok := any(cache).(TypedCache[K, V])
+	if !ok {
+		cache.Close()
+		return nil, fmt.Errorf("disk cache requires string keys, got %v", reflect.TypeOf((*K)(nil)).Elem())
+	}
+	return typed, nil
+}
+
+// newTypedRedisCacheFor creates a Redis cache for the server given by
+// WithRedisAddress. Redis caches only support string keys.
+func newTypedRedisCacheFor[K comparable, V any](opts ...Option) (TypedCache[K, V], error) {
+	cache, err := NewTypedRedisCache[V](newOptions(opts).redisAddress, opts...)
+	if err != nil {
+		return nil, err
+	}
+	typed, ok := any(cache).(TypedCache[K, V])
+	if !ok {
+		cache.Close()
+		return nil, fmt.Errorf("redis cache requires string keys, got %v", reflect.TypeOf((*K)(nil)).Elem())
+	}
+	return typed, nil
+}
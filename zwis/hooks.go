@@ -0,0 +1,133 @@
+package zwis
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EvictReason describes why an entry left the cache.
+type EvictReason int
+
+const (
+	EvictReasonCapacity EvictReason = iota + 1 // Dropped to make room for another entry
+	EvictReasonExpired                         // Its TTL elapsed
+	EvictReasonDeleted                         // Removed by Delete
+	EvictReasonFlushed                         // Removed by Flush
+	EvictReasonReplaced                        // Overwritten by Set with a new value
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictReasonCapacity:
+		return "capacity"
+	case EvictReasonExpired:
+		return "expired"
+	case EvictReasonDeleted:
+		return "deleted"
+	case EvictReasonFlushed:
+		return "flushed"
+	case EvictReasonReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictFunc is called with the key and value of an entry that left the cache.
+type EvictFunc[K comparable, V any] func(key K, value V, reason EvictReason)
+
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+// evictionHooks is embedded by every cache. It updates the statistics for
+// entries leaving the cache and queues them for the registered callbacks,
+// which are only invoked once the cache lock has been released so that they
+// may safely call back into the cache.
+type evictionHooks[K comparable, V any] struct {
+	statsCounter
+	listeners atomic.Pointer[[]EvictFunc[K, V]] // Copy-on-write, so delivery never locks
+	register  sync.Mutex
+	pending   []eviction[K, V] // Guarded by the cache lock
+}
+
+// configureHooks applies the options shared by every cache: the callback
+// set with WithOnEvict and WithStats. A callback of the wrong type is
+// ignored here; NewCache reports it as an error.
+func (h *evictionHooks[K, V]) configureHooks(o options) {
+	if fn, ok := o.onEvict.(EvictFunc[K, V]); ok {
+		h.OnEvict(fn)
+	}
+	h.disabled = o.statsDisabled
+}
+
+// OnEvict registers fn to be called whenever an entry leaves the cache, for
+// any reason. Callbacks run on the goroutine that caused the eviction, after
+// the cache lock has been released.
+func (h *evictionHooks[K, V]) OnEvict(fn EvictFunc[K, V]) {
+	h.register.Lock()
+	defer h.register.Unlock()
+
+	var listeners []EvictFunc[K, V]
+	if current := h.listeners.Load(); current != nil {
+		listeners = append(listeners, *current...)
+	}
+	listeners = append(listeners, fn)
+	h.listeners.Store(&listeners)
+}
+
+// OnExpire registers fn to be called whenever an entry is removed because
+// its TTL elapsed.
+func (h *evictionHooks[K, V]) OnExpire(fn func(key K, value V)) {
+	h.OnEvict(func(key K, value V, reason EvictReason) {
+		if reason == EvictReasonExpired {
+			fn(key, value)
+		}
+	})
+}
+
+// evicted records that an entry left the cache. It must be called with the
+// cache lock held.
+func (h *evictionHooks[K, V]) evicted(key K, value V, reason EvictReason) {
+	h.recordEviction(reason)
+	if h.listeners.Load() != nil {
+		h.pending = append(h.pending, eviction[K, V]{key: key, value: value, reason: reason})
+	}
+}
+
+// hasListeners reports whether any callbacks are registered, for caches
+// that have to do extra work to recover evicted values.
+func (h *evictionHooks[K, V]) hasListeners() bool {
+	return h.listeners.Load() != nil
+}
+
+// unlock releases the cache lock and then delivers any evictions recorded
+// while it was held.
+func (h *evictionHooks[K, V]) unlock(mu sync.Locker) {
+	pending := h.pending
+	h.pending = nil
+	mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	listeners := *h.listeners.Load()
+	for _, e := range pending {
+		for _, fn := range listeners {
+			fn(e.key, e.value, e.reason)
+		}
+	}
+}
+
+// TypedEvictionNotifier is implemented by caches that accept eviction
+// callbacks.
+type TypedEvictionNotifier[K comparable, V any] interface {
+	OnEvict(fn EvictFunc[K, V])
+	OnExpire(fn func(key K, value V))
+}
+
+// EvictionNotifier is the string-keyed, interface{}-valued form of
+// TypedEvictionNotifier.
+type EvictionNotifier = TypedEvictionNotifier[string, interface{}]
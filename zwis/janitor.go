@@ -0,0 +1,116 @@
+package zwis
+
+/*
+Expired entries are normally only noticed when they are read. The janitor is an optional background goroutine that removes them proactively. Each cache keeps its expirable keys in a min-heap ordered by expiration time, so a sweep only looks at the keys that have actually expired instead of scanning the whole cache.
+*/
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// expiryEntry is a key scheduled to expire at a given time.
+type expiryEntry[K comparable] struct {
+	key   K
+	at    int64 // Unix nanoseconds at which the key expires
+	index int   // Position in the heap, maintained by the heap methods
+}
+
+// expiryQueue is a min-heap of keys ordered by expiration time.
+type expiryQueue[K comparable] []*expiryEntry[K]
+
+func (q expiryQueue[K]) Len() int           { return len(q) }
+func (q expiryQueue[K]) Less(i, j int) bool { return q[i].at < q[j].at }
+
+func (q expiryQueue[K]) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *expiryQueue[K]) Push(x interface{}) {
+	e := x.(*expiryEntry[K])
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *expiryQueue[K]) Pop() interface{} {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	e.index = -1
+	return e
+}
+
+// track schedules key to expire at the given time and returns the heap entry
+// to keep alongside the cache entry. e is the entry's previous heap entry, if
+// any. An expiration of 0 means the key never expires and removes it from
+// the heap.
+func (q *expiryQueue[K]) track(e *expiryEntry[K], key K, at int64) *expiryEntry[K] {
+	if at == 0 {
+		q.untrack(e)
+		return nil
+	}
+	if e == nil {
+		e = &expiryEntry[K]{key: key, at: at}
+		heap.Push(q, e)
+		return e
+	}
+	e.at = at
+	heap.Fix(q, e.index)
+	return e
+}
+
+// untrack removes an entry from the heap. It is a no-op for nil entries.
+func (q *expiryQueue[K]) untrack(e *expiryEntry[K]) {
+	if e == nil || e.index < 0 {
+		return
+	}
+	heap.Remove(q, e.index)
+}
+
+// next returns the key that expires soonest if it expired before now.
+func (q expiryQueue[K]) next(now int64) (K, bool) {
+	if len(q) == 0 || q[0].at >= now {
+		var zero K
+		return zero, false
+	}
+	return q[0].key, true
+}
+
+// janitor periodically runs a sweep function, on a schedule kept by the
+// cache's clock.
+type janitor struct {
+	stop func()
+	once sync.Once
+}
+
+// newJanitor starts a janitor calling sweep every interval of clock. It
+// returns nil if interval is not positive.
+func newJanitor(clock Clock, interval time.Duration, sweep func()) *janitor {
+	if interval <= 0 {
+		return nil
+	}
+	return &janitor{stop: clock.Every(interval, sweep)}
+}
+
+// Close stops the janitor and waits for a running sweep to finish. It is
+// safe to call on a nil janitor and more than once.
+func (j *janitor) Close() {
+	if j == nil {
+		return
+	}
+	j.once.Do(j.stop)
+}
+
+// unixNano converts an expiration time to Unix nanoseconds, mapping the zero
+// time (no expiration) to 0.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
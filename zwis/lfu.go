@@ -2,166 +2,363 @@ package zwis
 
 /*
 Least Frequently Used (LFU) is a caching algorithm in which the least frequently used cache block is removed whenever the cache is overflowed. In LFU we check the old page as well as the frequency of that page and if the frequency of the page is larger than the old page we cannot remove it and if all the old pages are having same frequency then take last i.e FIFO method for that and remove that page.
+
+Plain LFU never forgets: a key that was hot yesterday keeps its high frequency and new keys are evicted first. WithFrequencyHalving and WithFrequencyHalfLife make frequencies decay over accesses or time, and WithDynamicAging enables LFU-DA, where new entries start at the priority of the last evicted entry and a hit sets an entry's priority to that age plus its reference count, so the priorities of stale entries are eventually overtaken.
 */
 import (
 	"context"
+	"math"
 	"sync"
 	"time"
 )
 
-type LFUCache struct {
-	capacity int
-	items    map[string]*lfuItem
-	freqs    map[int]*freqNode
-	minFreq  int
-	mu       sync.Mutex
+type TypedLFUCache[K comparable, V any] struct {
+	capacity    int
+	items       map[K]*lfuItem[K, V]
+	freqs       map[int]*freqNode[K, V]
+	head        *freqNode[K, V] // Sentinel of the bucket list, sorted by frequency
+	halveEvery  int             // Accesses between halvings, 0 to disable
+	accesses    int
+	halfLife    time.Duration // Half-life of frequencies, 0 to disable
+	lastDecay   int64
+	dynamicAged bool
+	age         int // LFU-DA: priority of the last evicted entry
+	expiries    expiryQueue[K]
+	janitor     *janitor
+	codecs      snapshotCodecs[K, V]
+	mu          sync.Mutex
+	evictionHooks[K, V]
+	timekeeper
+	costBudget[K, V]
 }
 
-type lfuItem struct {
-	key        string
-	value      interface{}
+// LFUCache is a TypedLFUCache with string keys and interface{} values.
+type LFUCache = TypedLFUCache[string, interface{}]
+
+type lfuItem[K comparable, V any] struct {
+	key   K
+	value V
+	// frequency is the item's priority and picks its bucket: its reference
+	// count, plus the cache's age at its last reference with dynamic aging.
 	frequency  int
+	count      int // References since the item was added
 	expiration int64
-	freqNode   *freqNode
+	expiry     *expiryEntry[K]
+	freqNode   *freqNode[K, V]
+	cost       int64
 }
 
-type freqNode struct {
+// freqNode is the bucket of items sharing a frequency. Buckets form a
+// circular list sorted by frequency, so the least frequently used bucket is
+// always head.next. Empty buckets are removed.
+type freqNode[K comparable, V any] struct {
 	freq  int
-	items map[string]*lfuItem
-	prev  *freqNode
-	next  *freqNode
+	items map[K]*lfuItem[K, V]
+	prev  *freqNode[K, V]
+	next  *freqNode[K, V]
+}
+
+func NewLFUCache(capacity int, opts ...Option) *LFUCache {
+	return NewTypedLFUCache[string, interface{}](capacity, opts...)
 }
 
-func NewLFUCache(capacity int) *LFUCache {
-	return &LFUCache{
-		capacity: capacity,
-		items:    make(map[string]*lfuItem),
-		freqs:    make(map[int]*freqNode),
+func NewTypedLFUCache[K comparable, V any](capacity int, opts ...Option) *TypedLFUCache[K, V] {
+	o := newOptions(opts)
+	c := &TypedLFUCache[K, V]{
+		capacity:    capacity,
+		halveEvery:  o.lfuHalveEvery,
+		halfLife:    o.lfuHalfLife,
+		dynamicAged: o.lfuDynamicAging,
+		timekeeper:  newTimekeeper(o),
 	}
+	c.lastDecay = c.now().UnixNano()
+	c.reset()
+	c.codecs = newSnapshotCodecs[K, V](o)
+	c.configureCost(o)
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c
 }
 
-func (c *LFUCache) Get(ctx context.Context, key string) (interface{}, bool) {
+func (c *TypedLFUCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock(&c.mu)
+	return c.getLocked(key)
+}
 
+// getLocked is Get with c.mu held.
+func (c *TypedLFUCache[K, V]) getLocked(key K) (V, bool) {
+	var zero V
 	if item, ok := c.items[key]; ok {
-		if item.expiration > 0 && item.expiration < time.Now().UnixNano() {
-			c.remove(item)
-			return nil, false
+		now := c.now().UnixNano()
+		if item.expiration > 0 && item.expiration < now {
+			c.remove(item, EvictReasonExpired)
+			c.recordMiss()
+			return zero, false
 		}
 		c.incrementFreq(item)
+		c.decay(now)
+		c.recordHit()
 		return item.value, true
 	}
-	return nil, false
+	c.recordMiss()
+	return zero, false
+}
+
+func (c *TypedLFUCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	return c.SetWithCost(ctx, key, value, c.costOf(key, value), ttl)
 }
 
-func (c *LFUCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+// SetWithCost stores a value with an explicit cost, evicting the least
+// frequently used entries until the total cost fits the budget set with
+// WithMaxCost.
+func (c *TypedLFUCache[K, V]) SetWithCost(ctx context.Context, key K, value V, cost int64, ttl time.Duration) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, value, cost, ttl)
+}
 
-	var expiration int64
-	if ttl > 0 {
-		expiration = time.Now().Add(ttl).UnixNano()
+// setLocked is SetWithCost with c.mu held.
+func (c *TypedLFUCache[K, V]) setLocked(key K, value V, cost int64, ttl time.Duration) error {
+	if err := c.checkCost(cost); err != nil {
+		return err
 	}
 
+	expiration := unixNano(c.expiresAt(ttl))
+
+	c.recordSet()
 	if item, ok := c.items[key]; ok {
+		c.evicted(key, item.value, EvictReasonReplaced)
 		item.value = value
 		item.expiration = expiration
+		item.expiry = c.expiries.track(item.expiry, key, expiration)
+		c.charge(cost - item.cost)
+		item.cost = cost
 		c.incrementFreq(item)
+		for c.overBudget(0) && c.evict(item) {
+		}
 	} else {
-		if len(c.items) >= c.capacity {
-			c.evict()
+		for (c.capacity > 0 && len(c.items) >= c.capacity) || c.overBudget(cost) {
+			if !c.evict(nil) {
+				break
+			}
 		}
-		item := &lfuItem{key: key, value: value, frequency: 0, expiration: expiration}
+		// New entries start one above the aging factor, which stays 0 unless
+		// dynamic aging is enabled. No bucket is below the age, so only the
+		// first bucket can come before the new entry's.
+		item := &lfuItem[K, V]{key: key, value: value, frequency: c.age + 1, count: 1, expiration: expiration, cost: cost}
+		item.expiry = c.expiries.track(nil, key, expiration)
 		c.items[key] = item
-		c.incrementFreq(item)
+		after := c.head
+		if first := c.head.next; first != c.head && first.freq < item.frequency {
+			after = first
+		}
+		c.addToFreqNode(item, after)
+		c.charge(cost)
+		c.recordAdded()
 	}
+	c.decay(c.now().UnixNano())
 	return nil
 }
 
-func (c *LFUCache) Delete(ctx context.Context, key string) error {
+func (c *TypedLFUCache[K, V]) Delete(ctx context.Context, key K) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
 
+// deleteLocked is Delete with c.mu held.
+func (c *TypedLFUCache[K, V]) deleteLocked(key K) error {
+	c.recordDelete()
 	if item, ok := c.items[key]; ok {
-		c.remove(item)
+		c.remove(item, EvictReasonDeleted)
 	}
 	return nil
 }
 
-func (c *LFUCache) Flush(ctx context.Context) error {
+// GetMany looks up every key under a single lock acquisition.
+func (c *TypedLFUCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock(&c.mu)
+	return getMany(keys, c.getLocked)
+}
+
+// SetMany stores every item under a single lock acquisition.
+func (c *TypedLFUCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return c.setLocked(key, item.Value, c.costOf(key, item.Value), item.TTL)
+	})
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedLFUCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
 
-	c.items = make(map[string]*lfuItem)
-	c.freqs = make(map[int]*freqNode)
-	c.minFreq = 0
+func (c *TypedLFUCache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	c.flushLocked()
 	return nil
 }
 
-func (c *LFUCache) incrementFreq(item *lfuItem) {
-	if item.freqNode != nil {
-		delete(item.freqNode.items, item.key)
-		if len(item.freqNode.items) == 0 {
-			c.removeFreqNode(item.freqNode)
-		}
+// flushLocked removes every item, reporting each as flushed. c.mu must be
+// held.
+func (c *TypedLFUCache[K, V]) flushLocked() {
+	for key, item := range c.items {
+		c.evicted(key, item.value, EvictReasonFlushed)
 	}
+	c.reset()
+	c.expiries = nil
+}
 
-	item.frequency++
-	nextFreq := item.frequency
+// Close stops the background janitor, if one was configured.
+func (c *TypedLFUCache[K, V]) Close() error {
+	c.janitor.Close()
+	return nil
+}
 
-	if node, ok := c.freqs[nextFreq]; ok {
-		node.items[item.key] = item
-		item.freqNode = node
-	} else {
-		node := &freqNode{freq: nextFreq, items: make(map[string]*lfuItem)}
-		c.freqs[nextFreq] = node
-		c.addFreqNode(node)
-		node.items[item.key] = item
-		item.freqNode = node
+// deleteExpired removes every entry whose TTL has elapsed.
+func (c *TypedLFUCache[K, V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(c.items[key], EvictReasonExpired)
 	}
+}
 
-	if item.frequency == 1 {
-		c.minFreq = 1
-	} else if item.frequency-1 == c.minFreq && len(c.freqs[c.minFreq].items) == 0 {
-		c.minFreq++
+// reset empties the cache's items and buckets.
+func (c *TypedLFUCache[K, V]) reset() {
+	c.items = make(map[K]*lfuItem[K, V])
+	c.freqs = make(map[int]*freqNode[K, V])
+	c.head = &freqNode[K, V]{}
+	c.head.prev, c.head.next = c.head, c.head
+	c.age = 0
+	c.accesses = 0
+	c.used.Store(0)
+}
+
+// incrementFreq counts a reference to item and moves it to the bucket for
+// its new priority, the age plus its reference count. Without dynamic aging
+// that is the next bucket at most one step away, so this is O(1); with it,
+// the item moves past the buckets between its old and new priority.
+func (c *TypedLFUCache[K, V]) incrementFreq(item *lfuItem[K, V]) {
+	node := item.freqNode
+	delete(node.items, item.key)
+	item.count++
+	item.frequency = max(c.age+item.count, item.frequency+1)
+	c.addToFreqNode(item, node)
+	if len(node.items) == 0 {
+		c.removeFreqNode(node)
 	}
 }
 
-func (c *LFUCache) evict() {
-	if node, ok := c.freqs[c.minFreq]; ok {
+// addToFreqNode puts an item in the bucket for its frequency, creating the
+// bucket after the first bucket from after onwards with a lower frequency if
+// needed. after must not have a higher frequency than the item.
+func (c *TypedLFUCache[K, V]) addToFreqNode(item *lfuItem[K, V], after *freqNode[K, V]) {
+	node, ok := c.freqs[item.frequency]
+	if !ok {
+		for after.next != c.head && after.next.freq < item.frequency {
+			after = after.next
+		}
+		node = &freqNode[K, V]{freq: item.frequency, items: make(map[K]*lfuItem[K, V])}
+		c.freqs[item.frequency] = node
+		c.addFreqNode(node, after)
+	}
+	node.items[item.key] = item
+	item.freqNode = node
+}
+
+// evict removes an item other than keep from the least frequently used
+// bucket that has one, and reports whether it found one. With dynamic aging
+// the cache's age becomes the lowest priority, which is the evicted item's
+// unless keep is alone in the first bucket; the age never exceeds a priority.
+func (c *TypedLFUCache[K, V]) evict(keep *lfuItem[K, V]) bool {
+	for node := c.head.next; node != c.head; node = node.next {
 		for _, item := range node.items {
-			c.remove(item)
-			break
+			if item == keep {
+				continue
+			}
+			if c.dynamicAged {
+				c.age = c.head.next.freq
+			}
+			c.remove(item, EvictReasonCapacity)
+			return true
 		}
 	}
+	return false
 }
 
-func (c *LFUCache) remove(item *lfuItem) {
+// decay is called on every hit and write. It halves frequencies once every
+// halveEvery accesses, or scales them by the decay accumulated since the last
+// time once at least a half-life has passed. Both rebuild the buckets in O(n)
+// and never drop a frequency below one.
+func (c *TypedLFUCache[K, V]) decay(now int64) {
+	c.accesses++
+	if c.halveEvery > 0 && c.accesses >= c.halveEvery {
+		c.accesses = 0
+		c.rescale(func(freq int) int { return freq / 2 })
+	}
+	if c.halfLife > 0 {
+		elapsed := time.Duration(now - c.lastDecay)
+		if elapsed < c.halfLife {
+			return
+		}
+		c.lastDecay = now
+		factor := math.Exp2(-float64(elapsed) / float64(c.halfLife))
+		c.rescale(func(freq int) int { return int(float64(freq) * factor) })
+	}
+}
+
+// rescale replaces every frequency with scale(frequency), clamped to at
+// least one. scale must be non-decreasing so the bucket order is kept.
+func (c *TypedLFUCache[K, V]) rescale(scale func(int) int) {
+	var nodes []*freqNode[K, V]
+	for node := c.head.next; node != c.head; node = node.next {
+		nodes = append(nodes, node)
+	}
+
+	c.freqs = make(map[int]*freqNode[K, V])
+	c.head.prev, c.head.next = c.head, c.head
+	c.age = scale(c.age)
+	for _, node := range nodes {
+		freq := max(scale(node.freq), 1)
+		for _, item := range node.items {
+			item.frequency = freq
+			item.count = max(freq-c.age, 1)
+			c.addToFreqNode(item, c.head.prev)
+		}
+	}
+}
+
+func (c *TypedLFUCache[K, V]) remove(item *lfuItem[K, V], reason EvictReason) {
 	delete(c.items, item.key)
+	c.expiries.untrack(item.expiry)
+	c.charge(-item.cost)
 	delete(item.freqNode.items, item.key)
 	if len(item.freqNode.items) == 0 {
 		c.removeFreqNode(item.freqNode)
 	}
+	c.evicted(item.key, item.value, reason)
 }
 
-func (c *LFUCache) removeFreqNode(node *freqNode) {
+func (c *TypedLFUCache[K, V]) removeFreqNode(node *freqNode[K, V]) {
 	delete(c.freqs, node.freq)
-	if node.prev != nil {
-		node.prev.next = node.next
-	}
-	if node.next != nil {
-		node.next.prev = node.prev
-	}
+	node.prev.next = node.next
+	node.next.prev = node.prev
 }
 
-func (c *LFUCache) addFreqNode(node *freqNode) {
-	if prevNode, ok := c.freqs[node.freq-1]; ok {
-		node.prev = prevNode
-		node.next = prevNode.next
-		prevNode.next = node
-		if node.next != nil {
-			node.next.prev = node
-		}
-	}
+// addFreqNode links node into the bucket list right after prev.
+func (c *TypedLFUCache[K, V]) addFreqNode(node *freqNode[K, V], prev *freqNode[K, V]) {
+	node.prev = prev
+	node.next = prev.next
+	prev.next.prev = node
+	prev.next = node
 }
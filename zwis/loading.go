@@ -0,0 +1,171 @@
+package zwis
+
+/*
+LoadingCache wraps any cache with read-through loading. Concurrent GetOrLoad calls for the same missing key share a single loader invocation, so a burst of misses for a hot key does not stampede the backing store.
+*/
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// loadMaxFailures bounds how many loader errors a LoadingCache remembers;
+// beyond it the oldest are forgotten early.
+const loadMaxFailures = 10000
+
+// Loader computes the value for a key missing from a LoadingCache. The
+// returned TTL is used when caching the value, with the same meaning as the
+// ttl passed to Set.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, time.Duration, error)
+
+// TypedLoadingCache adds GetOrLoad to an existing cache. It embeds the
+// wrapped cache, so it can be used wherever a TypedCache is expected.
+type TypedLoadingCache[K comparable, V any] struct {
+	TypedCache[K, V]
+	timekeeper
+	negativeTTL time.Duration
+	mu          sync.Mutex
+	calls       map[K]*loadCall[V]
+	failures    map[K]*list.Element // Cached loader errors, indexing failureList
+	failureList *list.List          // Cached loader errors, oldest first
+}
+
+// LoadingCache is a TypedLoadingCache with string keys and interface{} values.
+type LoadingCache = TypedLoadingCache[string, interface{}]
+
+// loadCall is an in-flight loader invocation shared by every caller waiting
+// on the same key.
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// loadFailure is a cached loader error.
+type loadFailure[K comparable] struct {
+	key        K
+	err        error
+	expiration time.Time
+}
+
+// NewLoadingCache wraps cache with read-through loading. Use
+// WithNegativeTTL to cache loader errors, and WithClock to time them out
+// with the same clock as cache.
+func NewLoadingCache(cache Cache, opts ...Option) *LoadingCache {
+	return NewTypedLoadingCache[string, interface{}](cache, opts...)
+}
+
+// NewTypedLoadingCache wraps cache with read-through loading. Use
+// WithNegativeTTL to cache loader errors, and WithClock to time them out
+// with the same clock as cache.
+func NewTypedLoadingCache[K comparable, V any](cache TypedCache[K, V], opts ...Option) *TypedLoadingCache[K, V] {
+	o := newOptions(opts)
+	return &TypedLoadingCache[K, V]{
+		TypedCache:  cache,
+		negativeTTL: o.negativeTTL,
+		calls:       make(map[K]*loadCall[V]),
+		failures:    make(map[K]*list.Element),
+		failureList: list.New(),
+		timekeeper:  newTimekeeper(o),
+	}
+}
+
+// GetOrLoad returns the cached value for key. On a miss it calls loader,
+// caches the result and returns it. Only one loader runs per key at a time;
+// other callers wait for its result. A caller whose ctx is done stops waiting
+// and gets ctx.Err(), but the load itself carries on for the others.
+func (c *TypedLoadingCache[K, V]) GetOrLoad(ctx context.Context, key K, loader Loader[K, V]) (V, error) {
+	var zero V
+	if value, ok := c.Get(ctx, key); ok {
+		return value, nil
+	}
+
+	c.mu.Lock()
+	if elem, ok := c.failures[key]; ok {
+		failure := elem.Value.(*loadFailure[K])
+		if c.now().Before(failure.expiration) {
+			c.mu.Unlock()
+			return zero, failure.err
+		}
+		c.forgetFailure(elem)
+	}
+
+	call, ok := c.calls[key]
+	if !ok {
+		// Another load may have finished between the miss above and taking
+		// the lock.
+		if value, ok := c.Get(ctx, key); ok {
+			c.mu.Unlock()
+			return value, nil
+		}
+		call = &loadCall[V]{done: make(chan struct{})}
+		c.calls[key] = call
+		go c.load(context.WithoutCancel(ctx), key, loader, call)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// load runs loader for key and publishes the result to every waiter.
+func (c *TypedLoadingCache[K, V]) load(ctx context.Context, key K, loader Loader[K, V], call *loadCall[V]) {
+	defer close(call.done)
+
+	value, ttl, err := c.runLoader(ctx, key, loader)
+	if err == nil {
+		err = c.Set(ctx, key, value, ttl)
+	}
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if err != nil && c.negativeTTL > 0 {
+		c.rememberFailure(key, err)
+	}
+	c.mu.Unlock()
+
+	call.value, call.err = value, err
+}
+
+// rememberFailure caches a loader error for key. Every failure lives for the
+// same negative TTL, so the list is in expiration order: expired failures are
+// pruned from its front, along with the oldest live ones once there are
+// loadMaxFailures. c.mu must be held.
+func (c *TypedLoadingCache[K, V]) rememberFailure(key K, err error) {
+	now := c.now()
+	for elem := c.failureList.Front(); elem != nil; elem = c.failureList.Front() {
+		if now.Before(elem.Value.(*loadFailure[K]).expiration) && c.failureList.Len() < loadMaxFailures {
+			break
+		}
+		c.forgetFailure(elem)
+	}
+	if elem, ok := c.failures[key]; ok {
+		c.forgetFailure(elem)
+	}
+	failure := &loadFailure[K]{key: key, err: err, expiration: now.Add(c.negativeTTL)}
+	c.failures[key] = c.failureList.PushBack(failure)
+}
+
+// forgetFailure drops a cached loader error. c.mu must be held.
+func (c *TypedLoadingCache[K, V]) forgetFailure(elem *list.Element) {
+	delete(c.failures, elem.Value.(*loadFailure[K]).key)
+	c.failureList.Remove(elem)
+}
+
+// runLoader calls loader, turning a panic into an error so that it cannot
+// take down the process from the background goroutine.
+func (c *TypedLoadingCache[K, V]) runLoader(ctx context.Context, key K, loader Loader[K, V]) (value V, ttl time.Duration, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("zwis: loader panicked: %v", r)
+		}
+	}()
+	return loader(ctx, key)
+}
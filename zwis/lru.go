@@ -7,102 +7,213 @@ import (
 	"time"
 )
 
-type LRUCache struct {
+type TypedLRUCache[K comparable, V any] struct {
 	capacity int
-	cache    map[interface{}]*list.Element
+	cache    map[K]*list.Element
 	list     *list.List
+	expiries expiryQueue[K]
+	janitor  *janitor
+	codecs   snapshotCodecs[K, V]
 	mutex    sync.RWMutex
+	evictionHooks[K, V]
+	timekeeper
+	costBudget[K, V]
 }
 
-type entry struct {
-	key        interface{}
-	value      interface{}
+// LRUCache is a TypedLRUCache with string keys and interface{} values.
+type LRUCache = TypedLRUCache[string, interface{}]
+
+type entry[K comparable, V any] struct {
+	key        K
+	value      V
 	expiration time.Time
+	expiry     *expiryEntry[K]
+	cost       int64
+}
+
+func NewLRUCache(capacity int, opts ...Option) *LRUCache {
+	return NewTypedLRUCache[string, interface{}](capacity, opts...)
 }
 
-func NewLRUCache(capacity int) *LRUCache {
-	return &LRUCache{
-		capacity: capacity,
-		cache:    make(map[interface{}]*list.Element),
-		list:     list.New(),
+func NewTypedLRUCache[K comparable, V any](capacity int, opts ...Option) *TypedLRUCache[K, V] {
+	o := newOptions(opts)
+	lru := &TypedLRUCache[K, V]{
+		capacity:   capacity,
+		cache:      make(map[K]*list.Element),
+		list:       list.New(),
+		timekeeper: newTimekeeper(o),
 	}
+	lru.codecs = newSnapshotCodecs[K, V](o)
+	lru.configureCost(o)
+	lru.configureHooks(o)
+	lru.janitor = newJanitor(lru.clock, o.janitorInterval, lru.deleteExpired)
+	return lru
 }
 
-func (lru *LRUCache) Get(ctx context.Context, key string) (interface{}, bool) {
-	lru.mutex.RLock()
-	elem, ok := lru.cache[key]
-	lru.mutex.RUnlock()
+func (lru *TypedLRUCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	lru.mutex.Lock()
+	defer lru.unlock(&lru.mutex)
+	return lru.getLocked(key)
+}
+
+// getLocked is Get with lru.mutex held.
+func (lru *TypedLRUCache[K, V]) getLocked(key K) (V, bool) {
+	var zero V
 
+	elem, ok := lru.cache[key]
 	if !ok {
-		return nil, false
+		lru.recordMiss()
+		return zero, false
 	}
 
-	lru.mutex.Lock()
-	defer lru.mutex.Unlock()
-
-	entry := elem.Value.(*entry)
-	if !entry.expiration.IsZero() && entry.expiration.Before(time.Now()) {
-		lru.removeElement(elem)
-		return nil, false
+	entry := elem.Value.(*entry[K, V])
+	if !entry.expiration.IsZero() && entry.expiration.Before(lru.now()) {
+		lru.removeElement(elem, EvictReasonExpired)
+		lru.recordMiss()
+		return zero, false
 	}
 
 	lru.list.MoveToFront(elem)
+	lru.recordHit()
 	return entry.value, true
 }
 
-func (lru *LRUCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+func (lru *TypedLRUCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	return lru.SetWithCost(ctx, key, value, lru.costOf(key, value), ttl)
+}
+
+// SetWithCost stores a value with an explicit cost, evicting the least
+// recently used entries until the total cost fits the budget set with
+// WithMaxCost.
+func (lru *TypedLRUCache[K, V]) SetWithCost(ctx context.Context, key K, value V, cost int64, ttl time.Duration) error {
 	lru.mutex.Lock()
-	defer lru.mutex.Unlock()
+	defer lru.unlock(&lru.mutex)
+	return lru.setLocked(key, value, cost, ttl)
+}
 
-	var expiration time.Time
-	if ttl > 0 {
-		expiration = time.Now().Add(ttl)
+// setLocked is SetWithCost with lru.mutex held.
+func (lru *TypedLRUCache[K, V]) setLocked(key K, value V, cost int64, ttl time.Duration) error {
+	if err := lru.checkCost(cost); err != nil {
+		return err
 	}
 
+	expiration := lru.expiresAt(ttl)
+
+	lru.recordSet()
 	if elem, ok := lru.cache[key]; ok {
 		lru.list.MoveToFront(elem)
-		elem.Value.(*entry).value = value
-		elem.Value.(*entry).expiration = expiration
+		entry := elem.Value.(*entry[K, V])
+		lru.evicted(key, entry.value, EvictReasonReplaced)
+		entry.value = value
+		entry.expiration = expiration
+		entry.expiry = lru.expiries.track(entry.expiry, key, unixNano(expiration))
+		lru.charge(cost - entry.cost)
+		entry.cost = cost
+		for lru.overBudget(0) && lru.list.Len() > 1 {
+			lru.removeOldest()
+		}
 	} else {
-		if lru.list.Len() >= lru.capacity {
+		for lru.list.Len() > 0 && ((lru.capacity > 0 && lru.list.Len() >= lru.capacity) || lru.overBudget(cost)) {
 			lru.removeOldest()
 		}
-		elem := lru.list.PushFront(&entry{key, value, expiration})
-		lru.cache[key] = elem
+		entry := &entry[K, V]{key: key, value: value, expiration: expiration, cost: cost}
+		entry.expiry = lru.expiries.track(nil, key, unixNano(expiration))
+		lru.cache[key] = lru.list.PushFront(entry)
+		lru.charge(cost)
+		lru.recordAdded()
 	}
 
 	return nil
 }
 
-func (lru *LRUCache) Delete(ctx context.Context, key string) error {
+func (lru *TypedLRUCache[K, V]) Delete(ctx context.Context, key K) error {
 	lru.mutex.Lock()
-	defer lru.mutex.Unlock()
+	defer lru.unlock(&lru.mutex)
+	return lru.deleteLocked(key)
+}
 
+// deleteLocked is Delete with lru.mutex held.
+func (lru *TypedLRUCache[K, V]) deleteLocked(key K) error {
+	lru.recordDelete()
 	if elem, ok := lru.cache[key]; ok {
-		lru.removeElement(elem)
+		lru.removeElement(elem, EvictReasonDeleted)
 	}
 
 	return nil
 }
 
-func (lru *LRUCache) Flush(ctx context.Context) error {
+// GetMany looks up every key under a single lock acquisition.
+func (lru *TypedLRUCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
 	lru.mutex.Lock()
-	defer lru.mutex.Unlock()
+	defer lru.unlock(&lru.mutex)
+	return getMany(keys, lru.getLocked)
+}
 
+// SetMany stores every item under a single lock acquisition.
+func (lru *TypedLRUCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	lru.mutex.Lock()
+	defer lru.unlock(&lru.mutex)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return lru.setLocked(key, item.Value, lru.costOf(key, item.Value), item.TTL)
+	})
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (lru *TypedLRUCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	lru.mutex.Lock()
+	defer lru.unlock(&lru.mutex)
+	return deleteMany(keys, lru.deleteLocked)
+}
+
+func (lru *TypedLRUCache[K, V]) Flush(ctx context.Context) error {
+	lru.mutex.Lock()
+	defer lru.unlock(&lru.mutex)
+	lru.flushLocked()
+	return nil
+}
+
+// flushLocked removes every entry, reporting each as flushed. lru.mutex must
+// be held.
+func (lru *TypedLRUCache[K, V]) flushLocked() {
+	for elem := lru.list.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*entry[K, V])
+		lru.evicted(entry.key, entry.value, EvictReasonFlushed)
+	}
 	lru.list.Init()
-	lru.cache = make(map[interface{}]*list.Element)
+	lru.cache = make(map[K]*list.Element)
+	lru.expiries = nil
+	lru.used.Store(0)
+}
 
+// Close stops the background janitor, if one was configured.
+func (lru *TypedLRUCache[K, V]) Close() error {
+	lru.janitor.Close()
 	return nil
 }
 
-func (lru *LRUCache) removeOldest() {
+// deleteExpired removes every entry whose TTL has elapsed.
+func (lru *TypedLRUCache[K, V]) deleteExpired() {
+	lru.mutex.Lock()
+	defer lru.unlock(&lru.mutex)
+
+	now := lru.now().UnixNano()
+	for key, ok := lru.expiries.next(now); ok; key, ok = lru.expiries.next(now) {
+		lru.removeElement(lru.cache[key], EvictReasonExpired)
+	}
+}
+
+func (lru *TypedLRUCache[K, V]) removeOldest() {
 	oldest := lru.list.Back()
 	if oldest != nil {
-		lru.removeElement(oldest)
+		lru.removeElement(oldest, EvictReasonCapacity)
 	}
 }
 
-func (lru *LRUCache) removeElement(elem *list.Element) {
+func (lru *TypedLRUCache[K, V]) removeElement(elem *list.Element, reason EvictReason) {
+	entry := elem.Value.(*entry[K, V])
 	lru.list.Remove(elem)
-	delete(lru.cache, elem.Value.(*entry).key)
+	lru.expiries.untrack(entry.expiry)
+	lru.charge(-entry.cost)
+	delete(lru.cache, entry.key)
+	lru.evicted(entry.key, entry.value, reason)
 }
@@ -2,71 +2,349 @@ package zwis
 
 import (
 	"context"
+	"errors"
+	"math/rand"
 	"sync"
 	"time"
 )
 
-type item struct {
-	value      interface{}
+// ErrCacheFull is returned by Set when a full MemoryCache using a volatile
+// eviction strategy has no entries with a TTL left to evict.
+var ErrCacheFull = errors.New("zwis: cache is full and has no evictable entries")
+
+// EvictionStrategy chooses which entry a full MemoryCache drops once there
+// are no expired entries left to remove. The strategies mirror Redis'
+// maxmemory policies of the same names.
+type EvictionStrategy int
+
+const (
+	// EvictAllKeysRandom drops a random entry. It is the default.
+	EvictAllKeysRandom EvictionStrategy = iota + 1
+	// EvictVolatileRandom drops a random entry that has a TTL.
+	EvictVolatileRandom
+	// EvictVolatileTTL drops the entry that is closest to expiring.
+	EvictVolatileTTL
+)
+
+type item[K comparable, V any] struct {
+	value      V
 	expiration time.Time
+	expiry     *expiryEntry[K]
+	slot       int // Index of the key in TypedMemoryCache.keys
+	cost       int64
 }
 
-type MemoryCache struct {
-	items map[string]item
-	mu    sync.RWMutex
+// TypedMemoryCache is a map with TTLs. It is unbounded unless created with
+// WithCapacity or WithMaxCost, in which case a full cache removes expired
+// entries first and then falls back to its EvictionStrategy.
+type TypedMemoryCache[K comparable, V any] struct {
+	capacity int
+	strategy EvictionStrategy
+	items    map[K]item[K, V]
+	keys     []K // Every key, so a random one can be picked in O(1)
+	expiries expiryQueue[K]
+	janitor  *janitor
+	codecs   snapshotCodecs[K, V]
+	mu       sync.RWMutex
+	evictionHooks[K, V]
+	timekeeper
+	costBudget[K, V]
 }
 
-func NewMemoryCache() *MemoryCache {
-	return &MemoryCache{
-		items: make(map[string]item),
+// MemoryCache is a TypedMemoryCache with string keys and interface{} values.
+type MemoryCache = TypedMemoryCache[string, interface{}]
+
+func NewMemoryCache(opts ...Option) *MemoryCache {
+	return NewTypedMemoryCache[string, interface{}](opts...)
+}
+
+func NewTypedMemoryCache[K comparable, V any](opts ...Option) *TypedMemoryCache[K, V] {
+	o := newOptions(opts)
+	c := &TypedMemoryCache[K, V]{
+		capacity:   o.capacity,
+		strategy:   o.evictionStrategy,
+		items:      make(map[K]item[K, V]),
+		timekeeper: newTimekeeper(o),
 	}
+	if c.strategy == 0 {
+		c.strategy = EvictAllKeysRandom
+	}
+	c.codecs = newSnapshotCodecs[K, V](o)
+	c.configureCost(o)
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c
 }
 
-func (c *MemoryCache) Get(ctx context.Context, key string) (interface{}, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+func (c *TypedMemoryCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	var zero V
 
+	c.mu.RLock()
 	item, found := c.items[key]
+	c.mu.RUnlock()
+
 	if !found {
-		return nil, false
+		c.recordMiss()
+		return zero, false
 	}
 
-	if !item.expiration.IsZero() && item.expiration.Before(time.Now()) {
-		return nil, false
+	if !item.expiration.IsZero() && item.expiration.Before(c.now()) {
+		c.mu.Lock()
+		// The entry may have been replaced while the lock was released.
+		c.removeIfExpired(key)
+		c.unlock(&c.mu)
+		c.recordMiss()
+		return zero, false
 	}
 
+	c.recordHit()
 	return item.value, true
 }
 
-func (c *MemoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+func (c *TypedMemoryCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	return c.SetWithCost(ctx, key, value, c.costOf(key, value), ttl)
+}
+
+// SetWithCost stores a value with an explicit cost. If the cache is full it
+// first removes expired entries and then evicts by its EvictionStrategy
+// until the new entry fits.
+func (c *TypedMemoryCache[K, V]) SetWithCost(ctx context.Context, key K, value V, cost int64, ttl time.Duration) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, value, cost, ttl)
+}
 
-	var expiration time.Time
-	if ttl > 0 {
-		expiration = time.Now().Add(ttl)
+// setLocked is SetWithCost with c.mu held.
+func (c *TypedMemoryCache[K, V]) setLocked(key K, value V, cost int64, ttl time.Duration) error {
+	if err := c.checkCost(cost); err != nil {
+		return err
 	}
 
-	c.items[key] = item{
+	if err := c.makeRoom(key, cost); err != nil {
+		return err
+	}
+
+	expiration := c.expiresAt(ttl)
+
+	c.recordSet()
+	current, found := c.items[key]
+	if found {
+		c.evicted(key, current.value, EvictReasonReplaced)
+		c.charge(cost - current.cost)
+	} else {
+		current.slot = len(c.keys)
+		c.keys = append(c.keys, key)
+		c.charge(cost)
+		c.recordAdded()
+	}
+	c.items[key] = item[K, V]{
 		value:      value,
 		expiration: expiration,
+		expiry:     c.expiries.track(current.expiry, key, unixNano(expiration)),
+		slot:       current.slot,
+		cost:       cost,
 	}
 
 	return nil
 }
 
-func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+func (c *TypedMemoryCache[K, V]) Delete(ctx context.Context, key K) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
 
-	delete(c.items, key)
+// deleteLocked is Delete with c.mu held.
+func (c *TypedMemoryCache[K, V]) deleteLocked(key K) error {
+	c.recordDelete()
+	if item, found := c.items[key]; found {
+		c.remove(key, item, EvictReasonDeleted)
+	}
+	return nil
+}
+
+// GetMany looks up every key under a single read lock, then removes the
+// expired ones under a single write lock.
+func (c *TypedMemoryCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	found := make(map[K]V, len(keys))
+	var expired []K
+
+	c.mu.RLock()
+	now := c.now()
+	for _, key := range keys {
+		item, ok := c.items[key]
+		if !ok {
+			c.recordMiss()
+			continue
+		}
+		if !item.expiration.IsZero() && item.expiration.Before(now) {
+			expired = append(expired, key)
+			c.recordMiss()
+			continue
+		}
+		found[key] = item.value
+		c.recordHit()
+	}
+	c.mu.RUnlock()
+
+	if len(expired) > 0 {
+		c.mu.Lock()
+		for _, key := range expired {
+			c.removeIfExpired(key)
+		}
+		c.unlock(&c.mu)
+	}
+	return found
+}
+
+// removeIfExpired removes key if it is in the cache and has expired. It is
+// called with c.mu held after a read lock was released, so it checks the
+// entry again.
+func (c *TypedMemoryCache[K, V]) removeIfExpired(key K) {
+	if current, ok := c.items[key]; ok && !current.expiration.IsZero() && current.expiration.Before(c.now()) {
+		c.remove(key, current, EvictReasonExpired)
+	}
+}
+
+// SetMany stores every item under a single lock acquisition.
+func (c *TypedMemoryCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return c.setLocked(key, item.Value, c.costOf(key, item.Value), item.TTL)
+	})
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedMemoryCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
+
+func (c *TypedMemoryCache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	c.flushLocked()
+	return nil
+}
+
+// flushLocked removes every item, reporting each as flushed. c.mu must be
+// held.
+func (c *TypedMemoryCache[K, V]) flushLocked() {
+	for key, item := range c.items {
+		c.evicted(key, item.value, EvictReasonFlushed)
+	}
+	c.items = make(map[K]item[K, V])
+	c.keys = nil
+	c.expiries = nil
+	c.used.Store(0)
+}
+
+// Close stops the background janitor, if one was configured.
+func (c *TypedMemoryCache[K, V]) Close() error {
+	c.janitor.Close()
 	return nil
 }
 
-func (c *MemoryCache) Flush(ctx context.Context) error {
+// deleteExpired removes every entry whose TTL has elapsed.
+func (c *TypedMemoryCache[K, V]) deleteExpired() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock(&c.mu)
+
+	c.removeExpired(c.now().UnixNano())
+}
 
-	c.items = make(map[string]item)
+func (c *TypedMemoryCache[K, V]) removeExpired(now int64) {
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(key, c.items[key], EvictReasonExpired)
+	}
+}
+
+// full reports whether storing key at the given cost would exceed the
+// capacity or the cost budget.
+func (c *TypedMemoryCache[K, V]) full(key K, cost int64) bool {
+	if current, ok := c.items[key]; ok {
+		return c.overBudget(cost - current.cost)
+	}
+	return (c.capacity > 0 && len(c.items) >= c.capacity) || c.overBudget(cost)
+}
+
+// makeRoom evicts entries other than key until key fits at the given cost,
+// removing expired entries before choosing victims by strategy.
+func (c *TypedMemoryCache[K, V]) makeRoom(key K, cost int64) error {
+	if !c.full(key, cost) {
+		return nil
+	}
+	c.removeExpired(c.now().UnixNano())
+	for c.full(key, cost) {
+		victim, ok := c.victim(key)
+		if !ok {
+			return ErrCacheFull
+		}
+		c.remove(victim, c.items[victim], EvictReasonCapacity)
+	}
 	return nil
 }
+
+// victim picks the next entry to evict, never choosing exclude.
+func (c *TypedMemoryCache[K, V]) victim(exclude K) (K, bool) {
+	switch c.strategy {
+	case EvictVolatileRandom:
+		return randomKey(len(c.expiries), func(i int) K { return c.expiries[i].key }, exclude)
+	case EvictVolatileTTL:
+		// The soonest expiry is the heap's root, or one of its children if
+		// the root is exclude.
+		best := -1
+		for i := 0; i < len(c.expiries) && i < 3; i++ {
+			if c.expiries[i].key != exclude && (best < 0 || c.expiries[i].at < c.expiries[best].at) {
+				best = i
+			}
+		}
+		if best < 0 {
+			var zero K
+			return zero, false
+		}
+		return c.expiries[best].key, true
+	default:
+		return randomKey(len(c.keys), func(i int) K { return c.keys[i] }, exclude)
+	}
+}
+
+// randomKey returns a random one of the n keys returned by keyAt, other than
+// exclude.
+func randomKey[K comparable](n int, keyAt func(int) K, exclude K) (K, bool) {
+	var zero K
+	if n == 0 {
+		return zero, false
+	}
+	i := rand.Intn(n)
+	if keyAt(i) == exclude {
+		if n == 1 {
+			return zero, false
+		}
+		i = (i + 1) % n
+	}
+	return keyAt(i), true
+}
+
+func (c *TypedMemoryCache[K, V]) remove(key K, item item[K, V], reason EvictReason) {
+	c.expiries.untrack(item.expiry)
+
+	// Fill the key's slot with the last key so c.keys stays dense.
+	last := len(c.keys) - 1
+	if item.slot != last {
+		moved := c.keys[last]
+		c.keys[item.slot] = moved
+		m := c.items[moved]
+		m.slot = item.slot
+		c.items[moved] = m
+	}
+	var zero K
+	c.keys[last] = zero
+	c.keys = c.keys[:last]
+
+	delete(c.items, key)
+	c.charge(-item.cost)
+	c.evicted(key, item.value, reason)
+}
@@ -0,0 +1,287 @@
+package zwis
+
+import (
+	"time"
+)
+
+// Option configures optional behaviour of a cache at construction time.
+type Option func(*options)
+
+type options struct {
+	janitorInterval  time.Duration
+	negativeTTL      time.Duration
+	directory        string
+	maxDiskSize      int64
+	codec            interface{}
+	keyCodec         interface{}
+	writeMode        WriteMode
+	promotionTTL     time.Duration
+	recentRatio      float64
+	ghostRatio       float64
+	protectedRatio   float64
+	lfuHalveEvery    int
+	lfuHalfLife      time.Duration
+	lfuDynamicAging  bool
+	maxCost          int64
+	weigher          interface{}
+	capacity         int
+	defaultTTL       time.Duration
+	clock            Clock
+	onEvict          interface{}
+	statsDisabled    bool
+	evictionStrategy EvictionStrategy
+	redisAddress     string
+	keyPrefix        string
+	redisUsername    string
+	redisPassword    string
+	redisDB          int
+	redisPoolSize    int
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ratioOr returns ratio if it lies strictly between 0 and 1, and def
+// otherwise.
+func ratioOr(ratio, def float64) float64 {
+	if ratio <= 0 || ratio >= 1 {
+		return def
+	}
+	return ratio
+}
+
+// WithJanitorInterval starts a background goroutine that removes expired
+// entries every interval. Caches created with a janitor must be closed with
+// Close to stop it.
+func WithJanitorInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.janitorInterval = interval
+	}
+}
+
+// WithNegativeTTL makes a LoadingCache remember loader errors for ttl, so
+// repeated requests for a failing key return the error without calling the
+// loader again. At most 10000 errors are remembered; beyond that the oldest
+// are forgotten first.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.negativeTTL = ttl
+	}
+}
+
+// WithDirectory sets the directory a DiskCache created through NewCache
+// stores its log in.
+func WithDirectory(dir string) Option {
+	return func(o *options) {
+		o.directory = dir
+	}
+}
+
+// WithMaxDiskSize limits the length of a DiskCache's log file. When a write
+// would take the log past it, least recently used entries are evicted and the
+// log is compacted to leave an eighth of the limit free.
+func WithMaxDiskSize(bytes int64) Option {
+	return func(o *options) {
+		o.maxDiskSize = bytes
+	}
+}
+
+// WithCodec sets the codec used to serialise values of type V for disk
+// storage and snapshots. The cache's value type must match V.
+func WithCodec[V any](codec Codec[V]) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+// WithKeyCodec sets the codec used to serialise keys of type K in snapshots.
+// The cache's key type must match K.
+func WithKeyCodec[K any](codec Codec[K]) Option {
+	return func(o *options) {
+		o.keyCodec = codec
+	}
+}
+
+// WithWriteMode sets how a TieredCache propagates writes to its first tier.
+// The default is WriteThrough.
+func WithWriteMode(mode WriteMode) Option {
+	return func(o *options) {
+		o.writeMode = mode
+	}
+}
+
+// WithPromotionTTL sets the TTL a TieredCache gives values it copies from
+// its second tier into its first, and so how long the first tier can keep
+// serving a value after the second expires or evicts it. The default, also
+// used for TTLs that are not positive, is one minute.
+func WithPromotionTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.promotionTTL = ttl
+	}
+}
+
+// WithTwoQueueRatios sets the share of a TwoQueueCache's capacity used by
+// its A1in queue of recently added entries, and the number of evicted keys
+// its A1out ghost queue remembers as a share of the capacity. Ratios outside
+// (0, 1) keep the defaults of 0.25 and 0.5.
+func WithTwoQueueRatios(recentRatio, ghostRatio float64) Option {
+	return func(o *options) {
+		o.recentRatio = recentRatio
+		o.ghostRatio = ghostRatio
+	}
+}
+
+// WithProtectedRatio sets the share of an SLRUCache's capacity used by its
+// protected segment. Ratios outside (0, 1) keep the default of 0.8.
+func WithProtectedRatio(ratio float64) Option {
+	return func(o *options) {
+		o.protectedRatio = ratio
+	}
+}
+
+// WithFrequencyHalving makes an LFUCache halve every access frequency after
+// each accesses reads and writes, so keys that were popular long ago lose
+// their advantage over new ones.
+func WithFrequencyHalving(accesses int) Option {
+	return func(o *options) {
+		o.lfuHalveEvery = accesses
+	}
+}
+
+// WithFrequencyHalfLife makes the access frequencies of an LFUCache decay
+// exponentially with time, halving every halfLife.
+func WithFrequencyHalfLife(halfLife time.Duration) Option {
+	return func(o *options) {
+		o.lfuHalfLife = halfLife
+	}
+}
+
+// WithDynamicAging turns an LFUCache into an LFU with Dynamic Aging
+// (LFU-DA) cache, which starts new entries at the priority of the last
+// evicted entry rather than at a frequency of one. Eviction stays O(1), but a
+// hit moves its entry past every bucket of distinct priority between its old
+// and new priority, so hits cost O(distinct priorities) in the worst case
+// rather than O(1).
+func WithDynamicAging() Option {
+	return func(o *options) {
+		o.lfuDynamicAging = true
+	}
+}
+
+// WithMaxCost bounds the total cost of the entries in a MemoryCache,
+// LRUCache, LFUCache or ARCCache. Entries are weighed with the weigher set by WithWeigher, or
+// cost 1 each, and the least valuable entries are evicted until the total
+// fits. LRU and LFU caches created with a capacity of 0 are bounded by cost
+// alone.
+func WithMaxCost(maxCost int64) Option {
+	return func(o *options) {
+		o.maxCost = maxCost
+	}
+}
+
+// WithWeigher sets the function used to compute the cost of entries stored
+// with Set. The cache's key and value types must match K and V.
+func WithWeigher[K comparable, V any](weigher Weigher[K, V]) Option {
+	return func(o *options) {
+		if weigher != nil {
+			o.weigher = weigher
+		}
+	}
+}
+
+// WithCapacity sets the maximum number of entries of a cache created with
+// NewCache or NewMemoryCache.
+func WithCapacity(capacity int) Option {
+	return func(o *options) {
+		o.capacity = capacity
+	}
+}
+
+// WithDefaultTTL sets the TTL of entries set with a ttl of 0. Entries set
+// with a negative ttl never expire.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.defaultTTL = ttl
+	}
+}
+
+// WithClock sets the clock a cache uses to expire entries. The default is
+// the system clock.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithOnEvict registers fn to be called whenever an entry leaves the cache,
+// as OnEvict does. The cache's key and value types must match K and V.
+func WithOnEvict[K comparable, V any](fn EvictFunc[K, V]) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.onEvict = fn
+		}
+	}
+}
+
+// WithStats turns counting of hits, misses, sets and deletes on or off. It
+// is on by default; turning it off saves an atomic increment on every
+// operation. Entry and eviction counts are always kept.
+func WithStats(enabled bool) Option {
+	return func(o *options) {
+		o.statsDisabled = !enabled
+	}
+}
+
+// WithEvictionStrategy sets how a full MemoryCache chooses entries to evict
+// once no expired entries are left. The default is EvictAllKeysRandom.
+func WithEvictionStrategy(strategy EvictionStrategy) Option {
+	return func(o *options) {
+		o.evictionStrategy = strategy
+	}
+}
+
+// WithRedisAddress sets the host:port of the server a RedisCache created
+// through NewCache connects to.
+func WithRedisAddress(addr string) Option {
+	return func(o *options) {
+		o.redisAddress = addr
+	}
+}
+
+// WithKeyPrefix makes a RedisCache store every key under prefix, so several
+// caches can share a database and Flush only deletes this cache's keys.
+// Flush fails for a cache without a prefix.
+func WithKeyPrefix(prefix string) Option {
+	return func(o *options) {
+		o.keyPrefix = prefix
+	}
+}
+
+// WithRedisAuth makes a RedisCache authenticate each connection with AUTH.
+// Leave username empty for servers without ACL users.
+func WithRedisAuth(username, password string) Option {
+	return func(o *options) {
+		o.redisUsername = username
+		o.redisPassword = password
+	}
+}
+
+// WithRedisDB makes a RedisCache SELECT database db on each connection.
+func WithRedisDB(db int) Option {
+	return func(o *options) {
+		o.redisDB = db
+	}
+}
+
+// WithPoolSize sets how many idle connections a RedisCache keeps open for
+// reuse. The default is 10.
+func WithPoolSize(size int) Option {
+	return func(o *options) {
+		o.redisPoolSize = size
+	}
+}
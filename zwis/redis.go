@@ -0,0 +1,382 @@
+package zwis
+
+/*
+RedisCache keeps entries in a Redis server, talking RESP over a small pool of connections rather than depending on a Redis client library. Every key is stored under the cache's key prefix, so several caches can share a database, and Flush deletes only the keys under the prefix by scanning for them instead of calling FLUSHDB. Without a prefix Flush returns an error, since it would otherwise delete every key in the database. TTLs map to SET's PX option and expiry is left to the server, so WithClock and the janitor do not apply.
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/internal/resp"
+)
+
+const (
+	defaultRedisPoolSize = 10
+	// redisScanCount is the COUNT hint Flush passes to SCAN, and the
+	// most keys it deletes with one DEL.
+	redisScanCount = 1000
+)
+
+// TypedRedisCache is a cache stored in a Redis server, with string keys.
+// Values are serialised with a Codec, GobCodec by default.
+//
+// Get cannot report errors through the Cache interface, so it treats them
+// as misses; use Lookup to see them. Stats counts this client's operations;
+// Entries is always 0, since the server holds the entries.
+type TypedRedisCache[V any] struct {
+	prefix     string
+	codec      Codec[V]
+	defaultTTL time.Duration
+	pool       *redisPool
+	statsCounter
+}
+
+// RedisCache is a TypedRedisCache with interface{} values.
+type RedisCache = TypedRedisCache[interface{}]
+
+// NewRedisCache creates a cache stored in the Redis server at addr, a
+// host:port pair. Connections are made when first needed.
+func NewRedisCache(addr string, opts ...Option) (*RedisCache, error) {
+	return NewTypedRedisCache[interface{}](addr, opts...)
+}
+
+// NewTypedRedisCache creates a cache stored in the Redis server at addr, a
+// host:port pair. Connections are made when first needed.
+func NewTypedRedisCache[V any](addr string, opts ...Option) (*TypedRedisCache[V], error) {
+	o := newOptions(opts)
+	if addr == "" {
+		return nil, errors.New("zwis: redis cache requires an address")
+	}
+	codec, err := resolveCodec[V](o.codec)
+	if err != nil {
+		return nil, err
+	}
+	poolSize := o.redisPoolSize
+	if poolSize <= 0 {
+		poolSize = defaultRedisPoolSize
+	}
+
+	c := &TypedRedisCache[V]{
+		prefix:     o.keyPrefix,
+		codec:      codec,
+		defaultTTL: o.defaultTTL,
+		pool: &redisPool{
+			addr:     addr,
+			username: o.redisUsername,
+			password: o.redisPassword,
+			db:       o.redisDB,
+			maxIdle:  poolSize,
+		},
+	}
+	c.disabled = o.statsDisabled
+	return c, nil
+}
+
+// Lookup is Get that also reports errors other than the key being missing.
+func (c *TypedRedisCache[V]) Lookup(ctx context.Context, key string) (V, bool, error) {
+	var zero V
+	v, err := c.pool.do(ctx, "GET", c.prefix+key)
+	if err != nil {
+		return zero, false, err
+	}
+	if v.IsNull() {
+		c.recordMiss()
+		return zero, false, nil
+	}
+	value, err := c.codec.Decode([]byte(v.Str))
+	if err != nil {
+		c.recordMiss()
+		return zero, false, fmt.Errorf("zwis: decoding %q: %w", key, err)
+	}
+	c.recordHit()
+	return value, true, nil
+}
+
+func (c *TypedRedisCache[V]) Get(ctx context.Context, key string) (V, bool) {
+	value, ok, _ := c.Lookup(ctx, key)
+	return value, ok
+}
+
+func (c *TypedRedisCache[V]) Set(ctx context.Context, key string, value V, ttl time.Duration) error {
+	args, err := c.setArgs(key, value, ttl)
+	if err != nil {
+		return err
+	}
+	if _, err := c.pool.do(ctx, args...); err != nil {
+		return err
+	}
+	c.recordSet()
+	return nil
+}
+
+// setArgs returns the SET command that stores value under key.
+func (c *TypedRedisCache[V]) setArgs(key string, value V, ttl time.Duration) ([]string, error) {
+	data, err := c.codec.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("zwis: encoding %q: %w", key, err)
+	}
+	args := []string{"SET", c.prefix + key, string(data)}
+	if ttl == 0 {
+		ttl = c.defaultTTL
+	}
+	if ttl > 0 {
+		// Round up, since PX 0 is an error and a shorter TTL than asked for
+		// could expire an entry early.
+		ms := (ttl + time.Millisecond - 1) / time.Millisecond
+		args = append(args, "PX", strconv.FormatInt(int64(ms), 10))
+	}
+	return args, nil
+}
+
+func (c *TypedRedisCache[V]) Delete(ctx context.Context, key string) error {
+	if _, err := c.pool.do(ctx, "DEL", c.prefix+key); err != nil {
+		return err
+	}
+	c.recordDelete()
+	return nil
+}
+
+// Flush deletes every key under the cache's prefix, scanning for them in
+// batches. Keys written while Flush runs may survive it. Flush fails for a
+// cache without a key prefix rather than delete every key in the database.
+func (c *TypedRedisCache[V]) Flush(ctx context.Context) error {
+	if c.prefix == "" {
+		return errRedisNoPrefix
+	}
+	pattern := escapeGlob(c.prefix) + "*"
+	cursor := "0"
+	for {
+		v, err := c.pool.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", strconv.Itoa(redisScanCount))
+		if err != nil {
+			return err
+		}
+		if v.Type != resp.Array || len(v.Elems) != 2 {
+			return fmt.Errorf("zwis: unexpected SCAN reply %v", v.Type)
+		}
+		cursor = v.Elems[0].Str
+		if keys := v.Elems[1].Elems; len(keys) > 0 {
+			args := make([]string, 0, len(keys)+1)
+			args = append(args, "DEL")
+			for _, key := range keys {
+				args = append(args, key.Str)
+			}
+			if _, err := c.pool.do(ctx, args...); err != nil {
+				return err
+			}
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// escapeGlob escapes the characters that SCAN's MATCH treats specially.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// GetMany fetches keys with one MGET. Keys whose values cannot be decoded
+// are left out, as are all keys if the command fails.
+func (c *TypedRedisCache[V]) GetMany(ctx context.Context, keys []string) map[string]V {
+	found := make(map[string]V, len(keys))
+	if len(keys) == 0 {
+		return found
+	}
+	args := make([]string, 0, len(keys)+1)
+	args = append(args, "MGET")
+	for _, key := range keys {
+		args = append(args, c.prefix+key)
+	}
+	v, err := c.pool.do(ctx, args...)
+	if err != nil || len(v.Elems) != len(keys) {
+		return found
+	}
+	for i, elem := range v.Elems {
+		if elem.IsNull() {
+			c.recordMiss()
+			continue
+		}
+		value, err := c.codec.Decode([]byte(elem.Str))
+		if err != nil {
+			c.recordMiss()
+			continue
+		}
+		c.recordHit()
+		found[keys[i]] = value
+	}
+	return found
+}
+
+// SetMany stores items with a pipeline of SET commands, one round trip in
+// all. It returns the first error.
+func (c *TypedRedisCache[V]) SetMany(ctx context.Context, items map[string]TypedItem[V]) error {
+	if len(items) == 0 {
+		return nil
+	}
+	cmds := make([][]string, 0, len(items))
+	for key, item := range items {
+		args, err := c.setArgs(key, item.Value, item.TTL)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, args)
+	}
+	replies, err := c.pool.pipeline(ctx, cmds)
+	if err != nil {
+		return err
+	}
+	for _, v := range replies {
+		if v.Type == resp.Error {
+			return resp.ServerError(v.Str)
+		}
+		c.recordSet()
+	}
+	return nil
+}
+
+// DeleteMany deletes keys with one DEL.
+func (c *TypedRedisCache[V]) DeleteMany(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]string, 0, len(keys)+1)
+	args = append(args, "DEL")
+	for _, key := range keys {
+		args = append(args, c.prefix+key)
+	}
+	if _, err := c.pool.do(ctx, args...); err != nil {
+		return err
+	}
+	for range keys {
+		c.recordDelete()
+	}
+	return nil
+}
+
+// Close closes the idle connections. Connections in use are closed when
+// they are returned.
+func (c *TypedRedisCache[V]) Close() error {
+	return c.pool.close()
+}
+
+// redisPool keeps idle connections to a Redis server for reuse.
+type redisPool struct {
+	addr     string
+	username string
+	password string
+	db       int
+	maxIdle  int
+
+	mu     sync.Mutex
+	idle   []*resp.Conn
+	closed bool
+}
+
+var (
+	errRedisClosed   = errors.New("zwis: redis cache is closed")
+	errRedisNoPrefix = errors.New("zwis: cannot flush a redis cache without a key prefix")
+)
+
+// do runs a command on a pooled connection.
+func (p *redisPool) do(ctx context.Context, args ...string) (resp.Value, error) {
+	conn, err := p.get(ctx)
+	if err != nil {
+		return resp.Value{}, err
+	}
+	defer p.put(conn)
+	return conn.Do(ctx, args...)
+}
+
+// pipeline runs commands in one round trip on a pooled connection.
+func (p *redisPool) pipeline(ctx context.Context, cmds [][]string) ([]resp.Value, error) {
+	conn, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.put(conn)
+	return conn.Pipeline(ctx, cmds...)
+}
+
+// get returns an idle connection, or dials a new one.
+func (p *redisPool) get(ctx context.Context) (*resp.Conn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errRedisClosed
+	}
+	if n := len(p.idle); n > 0 {
+		conn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return conn, nil
+	}
+	p.mu.Unlock()
+	return p.dial(ctx)
+}
+
+// dial connects to the server, authenticating and selecting the database
+// if the cache was configured to.
+func (p *redisPool) dial(ctx context.Context) (*resp.Conn, error) {
+	conn, err := resp.Dial(ctx, "tcp", p.addr)
+	if err != nil {
+		return nil, err
+	}
+	var setup [][]string
+	switch {
+	case p.username != "":
+		setup = append(setup, []string{"AUTH", p.username, p.password})
+	case p.password != "":
+		setup = append(setup, []string{"AUTH", p.password})
+	}
+	if p.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(p.db)})
+	}
+	for _, args := range setup {
+		if _, err := conn.Do(ctx, args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("zwis: redis %s: %w", args[0], err)
+		}
+	}
+	return conn, nil
+}
+
+// put returns conn to the pool, or closes it if it is broken or the pool is
+// full or closed.
+func (p *redisPool) put(conn *resp.Conn) {
+	p.mu.Lock()
+	if conn.Broken() || p.closed || len(p.idle) >= p.maxIdle {
+		p.mu.Unlock()
+		conn.Close()
+		return
+	}
+	p.idle = append(p.idle, conn)
+	p.mu.Unlock()
+}
+
+func (p *redisPool) close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	var errs []error
+	for _, conn := range idle {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,344 @@
+package zwis
+
+/*
+S3-FIFO uses three FIFO queues: a small queue that new entries enter, a main queue for entries that proved themselves while in the small queue, and a ghost queue that remembers the keys recently evicted from the small queue. Each entry carries a small saturating access counter that hits bump atomically, so lookups run under a read lock and never reorder a queue.
+
+When the small queue is over its share of the capacity its oldest entry is either moved to the main queue, if it was accessed while there, or evicted and remembered as a ghost. Keys that come back while remembered go straight to the main queue. The main queue behaves like a CLOCK: its oldest entry is reinserted at the head with its counter decremented until it reaches zero and is evicted.
+*/
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	s3FIFOMaxFreq = 3
+	// s3FIFOSmallRatio is the share of the capacity given to the small queue.
+	s3FIFOSmallRatio = 0.1
+)
+
+// TypedS3FIFOCache implements the S3-FIFO eviction policy.
+type TypedS3FIFOCache[K comparable, V any] struct {
+	capacity int
+	smallCap int
+	small    *list.List // Newest entries at the front
+	main     *list.List // Newest entries at the front
+	ghost    *list.List // Keys evicted from small, newest at the front
+	cache    map[K]*list.Element
+	ghosts   map[K]*list.Element
+	expiries expiryQueue[K]
+	janitor  *janitor
+	mu       sync.RWMutex
+	evictionHooks[K, V]
+	timekeeper
+}
+
+// S3FIFOCache is a TypedS3FIFOCache with string keys and interface{} values.
+type S3FIFOCache = TypedS3FIFOCache[string, interface{}]
+
+type s3FIFOEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration int64
+	expiry     *expiryEntry[K]
+	freq       atomic.Int32
+	inMain     bool
+}
+
+// NewS3FIFOCache creates an S3-FIFO cache with the given capacity.
+func NewS3FIFOCache(capacity int, opts ...Option) *S3FIFOCache {
+	return NewTypedS3FIFOCache[string, interface{}](capacity, opts...)
+}
+
+// NewTypedS3FIFOCache creates a generic S3-FIFO cache with the given capacity.
+// 10% of the capacity is used for the small queue, and the ghost queue
+// remembers as many keys as the main queue can hold. A capacity below 1 is
+// treated as 1.
+func NewTypedS3FIFOCache[K comparable, V any](capacity int, opts ...Option) *TypedS3FIFOCache[K, V] {
+	o := newOptions(opts)
+	capacity = max(capacity, 1)
+	c := &TypedS3FIFOCache[K, V]{
+		capacity:   capacity,
+		smallCap:   max(1, int(float64(capacity)*s3FIFOSmallRatio)),
+		small:      list.New(),
+		main:       list.New(),
+		ghost:      list.New(),
+		cache:      make(map[K]*list.Element),
+		ghosts:     make(map[K]*list.Element),
+		timekeeper: newTimekeeper(o),
+	}
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c
+}
+
+func (c *TypedS3FIFOCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	var zero V
+
+	c.mu.RLock()
+	elem, ok := c.cache[key]
+	if !ok {
+		c.mu.RUnlock()
+		c.recordMiss()
+		return zero, false
+	}
+
+	entry := elem.Value.(*s3FIFOEntry[K, V])
+	if entry.expiration > 0 && entry.expiration < c.now().UnixNano() {
+		c.mu.RUnlock()
+		c.mu.Lock()
+		// The entry may have been replaced while the lock was released.
+		c.removeIfExpired(key)
+		c.unlock(&c.mu)
+		c.recordMiss()
+		return zero, false
+	}
+
+	entry.touch()
+	value := entry.value
+	c.mu.RUnlock()
+
+	c.recordHit()
+	return value, true
+}
+
+func (c *TypedS3FIFOCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, value, ttl)
+}
+
+// setLocked is Set with c.mu held.
+func (c *TypedS3FIFOCache[K, V]) setLocked(key K, value V, ttl time.Duration) error {
+	expiration := unixNano(c.expiresAt(ttl))
+
+	c.recordSet()
+	if elem, ok := c.cache[key]; ok {
+		entry := elem.Value.(*s3FIFOEntry[K, V])
+		c.evicted(key, entry.value, EvictReasonReplaced)
+		entry.value = value
+		entry.expiration = expiration
+		entry.expiry = c.expiries.track(entry.expiry, key, expiration)
+		entry.touch()
+		return nil
+	}
+
+	// Forget the ghost before evicting, which could push it out of the ghost
+	// queue.
+	ghost, remembered := c.ghosts[key]
+	if remembered {
+		c.ghost.Remove(ghost)
+		delete(c.ghosts, key)
+	}
+	for c.small.Len()+c.main.Len() >= c.capacity {
+		c.evict()
+	}
+
+	entry := &s3FIFOEntry[K, V]{key: key, value: value, expiration: expiration}
+	entry.expiry = c.expiries.track(nil, key, expiration)
+	if remembered {
+		entry.inMain = true
+		c.cache[key] = c.main.PushFront(entry)
+	} else {
+		c.cache[key] = c.small.PushFront(entry)
+	}
+	c.recordAdded()
+	return nil
+}
+
+func (c *TypedS3FIFOCache[K, V]) Delete(ctx context.Context, key K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
+
+// deleteLocked is Delete with c.mu held.
+func (c *TypedS3FIFOCache[K, V]) deleteLocked(key K) error {
+	c.recordDelete()
+	if elem, ok := c.cache[key]; ok {
+		c.remove(elem, EvictReasonDeleted)
+	}
+	return nil
+}
+
+// GetMany looks up every key under a single read lock, then removes the
+// expired ones under a single write lock.
+func (c *TypedS3FIFOCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	found := make(map[K]V, len(keys))
+	var expired []K
+
+	c.mu.RLock()
+	now := c.now().UnixNano()
+	for _, key := range keys {
+		elem, ok := c.cache[key]
+		if !ok {
+			c.recordMiss()
+			continue
+		}
+		entry := elem.Value.(*s3FIFOEntry[K, V])
+		if entry.expiration > 0 && entry.expiration < now {
+			expired = append(expired, key)
+			c.recordMiss()
+			continue
+		}
+		entry.touch()
+		found[key] = entry.value
+		c.recordHit()
+	}
+	c.mu.RUnlock()
+
+	if len(expired) > 0 {
+		c.mu.Lock()
+		for _, key := range expired {
+			c.removeIfExpired(key)
+		}
+		c.unlock(&c.mu)
+	}
+	return found
+}
+
+// removeIfExpired removes key if it is in the cache and has expired. It is
+// called with c.mu held after a read lock was released, so it checks the
+// entry again.
+func (c *TypedS3FIFOCache[K, V]) removeIfExpired(key K) {
+	if elem, ok := c.cache[key]; ok {
+		if e := elem.Value.(*s3FIFOEntry[K, V]); e.expiration > 0 && e.expiration < c.now().UnixNano() {
+			c.remove(elem, EvictReasonExpired)
+		}
+	}
+}
+
+// SetMany stores every item under a single lock acquisition.
+func (c *TypedS3FIFOCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return c.setLocked(key, item.Value, item.TTL)
+	})
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedS3FIFOCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
+
+func (c *TypedS3FIFOCache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	for _, l := range []*list.List{c.small, c.main} {
+		for elem := l.Back(); elem != nil; elem = elem.Prev() {
+			entry := elem.Value.(*s3FIFOEntry[K, V])
+			c.evicted(entry.key, entry.value, EvictReasonFlushed)
+		}
+		l.Init()
+	}
+	c.ghost.Init()
+	c.cache = make(map[K]*list.Element)
+	c.ghosts = make(map[K]*list.Element)
+	c.expiries = nil
+	return nil
+}
+
+// Close stops the background janitor, if one was configured.
+func (c *TypedS3FIFOCache[K, V]) Close() error {
+	c.janitor.Close()
+	return nil
+}
+
+// deleteExpired removes every entry whose TTL has elapsed.
+func (c *TypedS3FIFOCache[K, V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(c.cache[key], EvictReasonExpired)
+	}
+}
+
+// evict frees one slot, from the small queue if it is over its share of the
+// capacity and from the main queue otherwise.
+func (c *TypedS3FIFOCache[K, V]) evict() {
+	if c.small.Len() >= c.smallCap || c.main.Len() == 0 {
+		c.evictSmall()
+	} else {
+		c.evictMain()
+	}
+}
+
+// evictSmall moves the oldest entries of the small queue that were accessed
+// to the main queue until it finds one that was not, which it evicts and
+// remembers as a ghost.
+func (c *TypedS3FIFOCache[K, V]) evictSmall() {
+	for elem := c.small.Back(); elem != nil; elem = c.small.Back() {
+		entry := elem.Value.(*s3FIFOEntry[K, V])
+		if entry.freq.Load() == 0 {
+			c.remove(elem, EvictReasonCapacity)
+			c.addGhost(entry.key)
+			return
+		}
+
+		c.small.Remove(elem)
+		entry.freq.Store(0)
+		entry.inMain = true
+		c.cache[entry.key] = c.main.PushFront(entry)
+		if c.main.Len() > c.capacity-c.smallCap {
+			c.evictMain()
+			return
+		}
+	}
+}
+
+// evictMain reinserts the oldest entries of the main queue with their access
+// counter decremented until it finds one whose counter is zero and evicts it.
+func (c *TypedS3FIFOCache[K, V]) evictMain() {
+	for elem := c.main.Back(); elem != nil; elem = c.main.Back() {
+		entry := elem.Value.(*s3FIFOEntry[K, V])
+		if freq := entry.freq.Load(); freq > 0 {
+			entry.freq.Store(freq - 1)
+			c.main.MoveToFront(elem)
+			continue
+		}
+		c.remove(elem, EvictReasonCapacity)
+		return
+	}
+}
+
+// addGhost remembers a key evicted from the small queue, forgetting the
+// oldest ghost once there are as many ghosts as the main queue can hold.
+func (c *TypedS3FIFOCache[K, V]) addGhost(key K) {
+	c.ghosts[key] = c.ghost.PushFront(key)
+	for c.ghost.Len() > max(1, c.capacity-c.smallCap) {
+		oldest := c.ghost.Back()
+		c.ghost.Remove(oldest)
+		delete(c.ghosts, oldest.Value.(K))
+	}
+}
+
+func (c *TypedS3FIFOCache[K, V]) remove(elem *list.Element, reason EvictReason) {
+	entry := elem.Value.(*s3FIFOEntry[K, V])
+	if entry.inMain {
+		c.main.Remove(elem)
+	} else {
+		c.small.Remove(elem)
+	}
+	c.expiries.untrack(entry.expiry)
+	delete(c.cache, entry.key)
+	c.evicted(entry.key, entry.value, reason)
+}
+
+// touch bumps the entry's access counter, saturating at s3FIFOMaxFreq.
+func (e *s3FIFOEntry[K, V]) touch() {
+	for {
+		freq := e.freq.Load()
+		if freq >= s3FIFOMaxFreq || e.freq.CompareAndSwap(freq, freq+1) {
+			return
+		}
+	}
+}
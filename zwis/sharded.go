@@ -0,0 +1,312 @@
+package zwis
+
+/*
+ShardedCache spreads keys across several independent caches of the same policy, each with its own lock, so goroutines working on different keys rarely contend. Eviction decisions are made per shard, which approximates the policy over the whole key space.
+*/
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// TypedShardedCache hashes keys across a fixed number of shards.
+type TypedShardedCache[K comparable, V any] struct {
+	shards []TypedCache[K, V]
+}
+
+// ShardedCache is a TypedShardedCache with string keys and interface{} values.
+type ShardedCache = TypedShardedCache[string, interface{}]
+
+// NewShardedCache creates a cache made of shards caches of the given type.
+// The capacity and maximum cost set by opts are totals and are split evenly
+// between the shards.
+func NewShardedCache(cacheType CacheType, shards int, opts ...Option) (*ShardedCache, error) {
+	return NewTypedShardedCache[string, interface{}](cacheType, shards, opts...)
+}
+
+// NewTypedShardedCache creates a cache made of shards caches of the given
+// type. The capacity and maximum cost set by opts are totals and are split
+// evenly between the shards.
+func NewTypedShardedCache[K comparable, V any](cacheType CacheType, shards int, opts ...Option) (*TypedShardedCache[K, V], error) {
+	if shards <= 0 {
+		return nil, fmt.Errorf("invalid shard count: %d", shards)
+	}
+
+	o := newOptions(opts)
+	shardOpts := append(opts[:len(opts):len(opts)], WithCapacity((o.capacity+shards-1)/shards))
+	if o.maxCost > 0 {
+		shardOpts = append(shardOpts, WithMaxCost((o.maxCost+int64(shards)-1)/int64(shards)))
+	}
+	c := &TypedShardedCache[K, V]{shards: make([]TypedCache[K, V], shards)}
+	for i := range c.shards {
+		shard, err := NewTypedCache[K, V](cacheType, shardOpts...)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.shards[i] = shard
+	}
+	return c, nil
+}
+
+func (c *TypedShardedCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	return c.shard(key).Get(ctx, key)
+}
+
+func (c *TypedShardedCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	return c.shard(key).Set(ctx, key, value, ttl)
+}
+
+func (c *TypedShardedCache[K, V]) Delete(ctx context.Context, key K) error {
+	return c.shard(key).Delete(ctx, key)
+}
+
+// GetMany looks up the keys of each shard with one batch call per shard.
+func (c *TypedShardedCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	found := make(map[K]V, len(keys))
+	for i, group := range c.groupKeys(keys) {
+		if len(group) == 0 {
+			continue
+		}
+		for key, value := range NewTypedBatchCache(c.shards[i]).GetMany(ctx, group) {
+			found[key] = value
+		}
+	}
+	return found
+}
+
+// SetMany stores the items of each shard with one batch call per shard,
+// returning the first error encountered.
+func (c *TypedShardedCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	groups := make([]map[K]TypedItem[V], len(c.shards))
+	for key, item := range items {
+		i := c.shardIndex(key)
+		if groups[i] == nil {
+			groups[i] = make(map[K]TypedItem[V])
+		}
+		groups[i][key] = item
+	}
+
+	var firstErr error
+	for i, group := range groups {
+		if group == nil {
+			continue
+		}
+		if err := NewTypedBatchCache(c.shards[i]).SetMany(ctx, group); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// DeleteMany removes the keys of each shard with one batch call per shard,
+// returning the first error encountered.
+func (c *TypedShardedCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	var firstErr error
+	for i, group := range c.groupKeys(keys) {
+		if len(group) == 0 {
+			continue
+		}
+		if err := NewTypedBatchCache(c.shards[i]).DeleteMany(ctx, group); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Flush flushes every shard, returning the first error encountered.
+func (c *TypedShardedCache[K, V]) Flush(ctx context.Context) error {
+	var firstErr error
+	for _, shard := range c.shards {
+		if err := shard.Flush(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Stats returns the sum of every shard's statistics.
+func (c *TypedShardedCache[K, V]) Stats() Stats {
+	var total Stats
+	for _, shard := range c.shards {
+		sp, ok := shard.(StatsProvider)
+		if !ok {
+			continue
+		}
+		s := sp.Stats()
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Sets += s.Sets
+		total.Deletes += s.Deletes
+		total.CapacityEvictions += s.CapacityEvictions
+		total.ExpiredEvictions += s.ExpiredEvictions
+		total.ExplicitEvictions += s.ExplicitEvictions
+		total.Entries += s.Entries
+	}
+	if lookups := total.Hits + total.Misses; lookups > 0 {
+		total.HitRatio = float64(total.Hits) / float64(lookups)
+	}
+	return total
+}
+
+// ResetStats resets the statistics of every shard.
+func (c *TypedShardedCache[K, V]) ResetStats() {
+	for _, shard := range c.shards {
+		if sp, ok := shard.(StatsProvider); ok {
+			sp.ResetStats()
+		}
+	}
+}
+
+// OnEvict registers fn on every shard.
+func (c *TypedShardedCache[K, V]) OnEvict(fn EvictFunc[K, V]) {
+	for _, shard := range c.shards {
+		if n, ok := shard.(TypedEvictionNotifier[K, V]); ok {
+			n.OnEvict(fn)
+		}
+	}
+}
+
+// OnExpire registers fn on every shard.
+func (c *TypedShardedCache[K, V]) OnExpire(fn func(key K, value V)) {
+	for _, shard := range c.shards {
+		if n, ok := shard.(TypedEvictionNotifier[K, V]); ok {
+			n.OnExpire(fn)
+		}
+	}
+}
+
+// Close closes every shard that has background resources.
+func (c *TypedShardedCache[K, V]) Close() error {
+	var firstErr error
+	for _, shard := range c.shards {
+		if closer, ok := shard.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (c *TypedShardedCache[K, V]) shard(key K) TypedCache[K, V] {
+	return c.shards[c.shardIndex(key)]
+}
+
+func (c *TypedShardedCache[K, V]) shardIndex(key K) int {
+	return int(hashKey(key) % uint64(len(c.shards)))
+}
+
+// groupKeys splits keys by the shard that owns them.
+func (c *TypedShardedCache[K, V]) groupKeys(keys []K) [][]K {
+	groups := make([][]K, len(c.shards))
+	for _, key := range keys {
+		i := c.shardIndex(key)
+		groups[i] = append(groups[i], key)
+	}
+	return groups
+}
+
+// hashKey hashes a comparable key. Strings and integers are hashed without
+// allocating; other key types are hashed field by field through reflection,
+// so that keys which compare equal always land on the same shard.
+func hashKey[K comparable](key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return fnv1a(k)
+	case int:
+		return mix64(uint64(k))
+	case int32:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	default:
+		return hashValue(reflect.ValueOf(key))
+	}
+}
+
+// hashValue hashes a value of a comparable type consistently with ==: -0 and
+// +0 hash alike, pointers hash by address and interfaces by their dynamic
+// value.
+func hashValue(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.Bool:
+		if v.Bool() {
+			return mix64(1)
+		}
+		return mix64(0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mix64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return mix64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return hashFloat(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return mix64(hashFloat(real(c))) ^ hashFloat(imag(c))
+	case reflect.String:
+		return fnv1a(v.String())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return mix64(uint64(v.Pointer()))
+	case reflect.Interface:
+		return hashValue(v.Elem())
+	case reflect.Array:
+		var h uint64
+		for i := 0; i < v.Len(); i++ {
+			h = mix64(h ^ hashValue(v.Index(i)))
+		}
+		return h
+	case reflect.Struct:
+		var h uint64
+		for i := 0; i < v.NumField(); i++ {
+			h = mix64(h ^ hashValue(v.Field(i)))
+		}
+		return h
+	default:
+		panic(fmt.Sprintf("zwis: cannot hash key of type %s", v.Type()))
+	}
+}
+
+// hashFloat hashes a float so that -0 and +0, which compare equal, agree.
+func hashFloat(f float64) uint64 {
+	if f == 0 {
+		f = 0
+	}
+	return mix64(math.Float64bits(f))
+}
+
+// fnv1a is FNV-1a over a string, inlined to avoid the allocation of hash/fnv.
+func fnv1a(s string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime64
+	}
+	return h
+}
+
+// mix64 is the splitmix64 finalizer, spreading sequential integers evenly.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
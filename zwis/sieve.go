@@ -0,0 +1,262 @@
+package zwis
+
+/*
+SIEVE keeps entries in a single FIFO queue and a "hand" that sweeps from the oldest entry towards the newest. A hit only marks the entry as visited, so lookups never reorder the queue and can run under a read lock. On eviction the hand clears the visited bit of each entry it passes and evicts the first one that was not visited since the hand last went by. New entries are always inserted at the head, so entries that survive a sweep stay in place rather than being moved.
+*/
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TypedSIEVECache implements the SIEVE eviction policy.
+type TypedSIEVECache[K comparable, V any] struct {
+	capacity int
+	queue    *list.List    // Newest entries at the front
+	hand     *list.Element // Next eviction candidate, nil to start at the back
+	cache    map[K]*list.Element
+	expiries expiryQueue[K]
+	janitor  *janitor
+	mu       sync.RWMutex
+	evictionHooks[K, V]
+	timekeeper
+}
+
+// SIEVECache is a TypedSIEVECache with string keys and interface{} values.
+type SIEVECache = TypedSIEVECache[string, interface{}]
+
+type sieveEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration int64
+	expiry     *expiryEntry[K]
+	visited    atomic.Bool
+}
+
+// NewSIEVECache creates a SIEVE cache with the given capacity.
+func NewSIEVECache(capacity int, opts ...Option) *SIEVECache {
+	return NewTypedSIEVECache[string, interface{}](capacity, opts...)
+}
+
+// NewTypedSIEVECache creates a generic SIEVE cache with the given capacity.
+func NewTypedSIEVECache[K comparable, V any](capacity int, opts ...Option) *TypedSIEVECache[K, V] {
+	o := newOptions(opts)
+	c := &TypedSIEVECache[K, V]{
+		capacity:   capacity,
+		queue:      list.New(),
+		cache:      make(map[K]*list.Element),
+		timekeeper: newTimekeeper(o),
+	}
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c
+}
+
+func (c *TypedSIEVECache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	var zero V
+
+	c.mu.RLock()
+	elem, ok := c.cache[key]
+	if !ok {
+		c.mu.RUnlock()
+		c.recordMiss()
+		return zero, false
+	}
+
+	entry := elem.Value.(*sieveEntry[K, V])
+	if entry.expiration > 0 && entry.expiration < c.now().UnixNano() {
+		c.mu.RUnlock()
+		c.mu.Lock()
+		// The entry may have been replaced while the lock was released.
+		c.removeIfExpired(key)
+		c.unlock(&c.mu)
+		c.recordMiss()
+		return zero, false
+	}
+
+	entry.visited.Store(true)
+	value := entry.value
+	c.mu.RUnlock()
+
+	c.recordHit()
+	return value, true
+}
+
+func (c *TypedSIEVECache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, value, ttl)
+}
+
+// setLocked is Set with c.mu held.
+func (c *TypedSIEVECache[K, V]) setLocked(key K, value V, ttl time.Duration) error {
+	expiration := unixNano(c.expiresAt(ttl))
+
+	c.recordSet()
+	if elem, ok := c.cache[key]; ok {
+		entry := elem.Value.(*sieveEntry[K, V])
+		c.evicted(key, entry.value, EvictReasonReplaced)
+		entry.value = value
+		entry.expiration = expiration
+		entry.expiry = c.expiries.track(entry.expiry, key, expiration)
+		entry.visited.Store(true)
+		return nil
+	}
+
+	if c.queue.Len() >= c.capacity {
+		c.evict()
+	}
+
+	entry := &sieveEntry[K, V]{key: key, value: value, expiration: expiration}
+	entry.expiry = c.expiries.track(nil, key, expiration)
+	c.cache[key] = c.queue.PushFront(entry)
+	c.recordAdded()
+	return nil
+}
+
+func (c *TypedSIEVECache[K, V]) Delete(ctx context.Context, key K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
+
+// deleteLocked is Delete with c.mu held.
+func (c *TypedSIEVECache[K, V]) deleteLocked(key K) error {
+	c.recordDelete()
+	if elem, ok := c.cache[key]; ok {
+		c.remove(elem, EvictReasonDeleted)
+	}
+	return nil
+}
+
+// GetMany looks up every key under a single read lock, then removes the
+// expired ones under a single write lock.
+func (c *TypedSIEVECache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	found := make(map[K]V, len(keys))
+	var expired []K
+
+	c.mu.RLock()
+	now := c.now().UnixNano()
+	for _, key := range keys {
+		elem, ok := c.cache[key]
+		if !ok {
+			c.recordMiss()
+			continue
+		}
+		entry := elem.Value.(*sieveEntry[K, V])
+		if entry.expiration > 0 && entry.expiration < now {
+			expired = append(expired, key)
+			c.recordMiss()
+			continue
+		}
+		entry.visited.Store(true)
+		found[key] = entry.value
+		c.recordHit()
+	}
+	c.mu.RUnlock()
+
+	if len(expired) > 0 {
+		c.mu.Lock()
+		for _, key := range expired {
+			c.removeIfExpired(key)
+		}
+		c.unlock(&c.mu)
+	}
+	return found
+}
+
+// removeIfExpired removes key if it is in the cache and has expired. It is
+// called with c.mu held after a read lock was released, so it checks the
+// entry again.
+func (c *TypedSIEVECache[K, V]) removeIfExpired(key K) {
+	if elem, ok := c.cache[key]; ok {
+		if e := elem.Value.(*sieveEntry[K, V]); e.expiration > 0 && e.expiration < c.now().UnixNano() {
+			c.remove(elem, EvictReasonExpired)
+		}
+	}
+}
+
+// SetMany stores every item under a single lock acquisition.
+func (c *TypedSIEVECache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return c.setLocked(key, item.Value, item.TTL)
+	})
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedSIEVECache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
+
+func (c *TypedSIEVECache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	for elem := c.queue.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*sieveEntry[K, V])
+		c.evicted(entry.key, entry.value, EvictReasonFlushed)
+	}
+	c.queue.Init()
+	c.hand = nil
+	c.cache = make(map[K]*list.Element)
+	c.expiries = nil
+	return nil
+}
+
+// Close stops the background janitor, if one was configured.
+func (c *TypedSIEVECache[K, V]) Close() error {
+	c.janitor.Close()
+	return nil
+}
+
+// deleteExpired removes every entry whose TTL has elapsed.
+func (c *TypedSIEVECache[K, V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(c.cache[key], EvictReasonExpired)
+	}
+}
+
+// evict moves the hand towards the front of the queue, clearing visited bits,
+// and evicts the first entry that has not been visited.
+func (c *TypedSIEVECache[K, V]) evict() {
+	elem := c.hand
+	if elem == nil {
+		elem = c.queue.Back()
+	}
+	for elem != nil {
+		entry := elem.Value.(*sieveEntry[K, V])
+		if !entry.visited.Load() {
+			break
+		}
+		entry.visited.Store(false)
+		if elem = elem.Prev(); elem == nil {
+			elem = c.queue.Back()
+		}
+	}
+	if elem != nil {
+		c.hand = elem
+		c.remove(elem, EvictReasonCapacity)
+	}
+}
+
+func (c *TypedSIEVECache[K, V]) remove(elem *list.Element, reason EvictReason) {
+	if c.hand == elem {
+		c.hand = elem.Prev()
+	}
+	entry := elem.Value.(*sieveEntry[K, V])
+	c.queue.Remove(elem)
+	c.expiries.untrack(entry.expiry)
+	delete(c.cache, entry.key)
+	c.evicted(entry.key, entry.value, reason)
+}
@@ -0,0 +1,234 @@
+package zwis
+
+/*
+A segmented LRU splits the cache into a probationary and a protected segment, each ordered by recency. New entries enter the probationary segment and are only promoted to the protected segment when they are hit again. Evictions come from the probationary segment, so a scan of keys touched once can only displace other probationary entries; entries demoted when the protected segment overflows get one more chance on probation.
+*/
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// TypedSLRUCache implements the segmented LRU eviction policy.
+type TypedSLRUCache[K comparable, V any] struct {
+	capacity     int
+	protectedCap int
+	probation    *list.List
+	protected    *list.List
+	cache        map[K]*list.Element
+	expiries     expiryQueue[K]
+	janitor      *janitor
+	mu           sync.Mutex
+	evictionHooks[K, V]
+	timekeeper
+}
+
+// SLRUCache is a TypedSLRUCache with string keys and interface{} values.
+type SLRUCache = TypedSLRUCache[string, interface{}]
+
+type slruEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration time.Time
+	expiry     *expiryEntry[K]
+	protected  bool
+}
+
+// NewSLRUCache creates a segmented LRU cache with the given capacity.
+func NewSLRUCache(capacity int, opts ...Option) *SLRUCache {
+	return NewTypedSLRUCache[string, interface{}](capacity, opts...)
+}
+
+// NewTypedSLRUCache creates a generic segmented LRU cache with the given
+// capacity. The size of the protected segment is set with
+// WithProtectedRatio. A capacity below 1 is treated as 1.
+func NewTypedSLRUCache[K comparable, V any](capacity int, opts ...Option) *TypedSLRUCache[K, V] {
+	o := newOptions(opts)
+	capacity = max(capacity, 1)
+	c := &TypedSLRUCache[K, V]{
+		capacity:     capacity,
+		protectedCap: int(float64(capacity) * ratioOr(o.protectedRatio, 0.8)),
+		probation:    list.New(),
+		protected:    list.New(),
+		cache:        make(map[K]*list.Element),
+		timekeeper:   newTimekeeper(o),
+	}
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c
+}
+
+func (c *TypedSLRUCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.getLocked(key)
+}
+
+// getLocked is Get with c.mu held.
+func (c *TypedSLRUCache[K, V]) getLocked(key K) (V, bool) {
+	var zero V
+
+	elem, ok := c.cache[key]
+	if !ok {
+		c.recordMiss()
+		return zero, false
+	}
+
+	entry := elem.Value.(*slruEntry[K, V])
+	if !entry.expiration.IsZero() && entry.expiration.Before(c.now()) {
+		c.remove(elem, EvictReasonExpired)
+		c.recordMiss()
+		return zero, false
+	}
+
+	c.touch(elem)
+	c.recordHit()
+	return entry.value, true
+}
+
+func (c *TypedSLRUCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, value, ttl)
+}
+
+// setLocked is Set with c.mu held.
+func (c *TypedSLRUCache[K, V]) setLocked(key K, value V, ttl time.Duration) error {
+	expiration := c.expiresAt(ttl)
+
+	c.recordSet()
+	if elem, ok := c.cache[key]; ok {
+		entry := elem.Value.(*slruEntry[K, V])
+		c.evicted(key, entry.value, EvictReasonReplaced)
+		entry.value = value
+		entry.expiration = expiration
+		entry.expiry = c.expiries.track(entry.expiry, key, unixNano(expiration))
+		c.touch(elem)
+		return nil
+	}
+
+	if c.probation.Len()+c.protected.Len() >= c.capacity {
+		victim := c.probation.Back()
+		if victim == nil {
+			victim = c.protected.Back()
+		}
+		c.remove(victim, EvictReasonCapacity)
+	}
+
+	entry := &slruEntry[K, V]{key: key, value: value, expiration: expiration}
+	entry.expiry = c.expiries.track(nil, key, unixNano(expiration))
+	c.cache[key] = c.probation.PushFront(entry)
+	c.recordAdded()
+	return nil
+}
+
+func (c *TypedSLRUCache[K, V]) Delete(ctx context.Context, key K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
+
+// deleteLocked is Delete with c.mu held.
+func (c *TypedSLRUCache[K, V]) deleteLocked(key K) error {
+	c.recordDelete()
+	if elem, ok := c.cache[key]; ok {
+		c.remove(elem, EvictReasonDeleted)
+	}
+	return nil
+}
+
+// GetMany looks up every key under a single lock acquisition.
+func (c *TypedSLRUCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return getMany(keys, c.getLocked)
+}
+
+// SetMany stores every item under a single lock acquisition.
+func (c *TypedSLRUCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return c.setLocked(key, item.Value, item.TTL)
+	})
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedSLRUCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
+
+func (c *TypedSLRUCache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	for _, l := range []*list.List{c.probation, c.protected} {
+		for elem := l.Back(); elem != nil; elem = elem.Prev() {
+			entry := elem.Value.(*slruEntry[K, V])
+			c.evicted(entry.key, entry.value, EvictReasonFlushed)
+		}
+		l.Init()
+	}
+	c.cache = make(map[K]*list.Element)
+	c.expiries = nil
+	return nil
+}
+
+// Close stops the background janitor, if one was configured.
+func (c *TypedSLRUCache[K, V]) Close() error {
+	c.janitor.Close()
+	return nil
+}
+
+// deleteExpired removes every entry whose TTL has elapsed.
+func (c *TypedSLRUCache[K, V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(c.cache[key], EvictReasonExpired)
+	}
+}
+
+// touch moves a protected entry to the front of its segment, or promotes a
+// probationary one, demoting the least recently used protected entry if the
+// protected segment is full.
+func (c *TypedSLRUCache[K, V]) touch(elem *list.Element) {
+	entry := elem.Value.(*slruEntry[K, V])
+	if entry.protected {
+		c.protected.MoveToFront(elem)
+		return
+	}
+	if c.protectedCap == 0 {
+		c.probation.MoveToFront(elem)
+		return
+	}
+
+	c.probation.Remove(elem)
+	entry.protected = true
+	c.cache[entry.key] = c.protected.PushFront(entry)
+	if c.protected.Len() > c.protectedCap {
+		demoted := c.protected.Back()
+		c.protected.Remove(demoted)
+		demotedEntry := demoted.Value.(*slruEntry[K, V])
+		demotedEntry.protected = false
+		c.cache[demotedEntry.key] = c.probation.PushFront(demotedEntry)
+	}
+}
+
+func (c *TypedSLRUCache[K, V]) remove(elem *list.Element, reason EvictReason) {
+	entry := elem.Value.(*slruEntry[K, V])
+	if entry.protected {
+		c.protected.Remove(elem)
+	} else {
+		c.probation.Remove(elem)
+	}
+	c.expiries.untrack(entry.expiry)
+	delete(c.cache, entry.key)
+	c.evicted(entry.key, entry.value, reason)
+}
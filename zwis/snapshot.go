@@ -0,0 +1,562 @@
+package zwis
+
+/*
+Snapshots let a cache be saved before a restart and restored afterwards. The format is versioned and shared by every policy:
+
+	magic    "ZWIS"
+	version  byte
+	policy   byte
+	p        uvarint  ARC's target T1 size, 0 for other policies
+	sections uvarint  followed by each section of entries:
+	  count  uvarint  followed by each entry:
+	    key    uvarint length + bytes from the key codec
+	    value  uvarint length + bytes from the value codec
+	    ttl    varint   remaining time to live in nanoseconds, 0 for none
+	    freq   uvarint  LFU access frequency, 0 for other policies
+	ghosts   uvarint  followed by each section of ghost keys:
+	  count  uvarint  followed by each key as above
+
+Entries are written from least to most recently used, so inserting them in order restores recency. Memory, LRU and LFU caches write a single section; ARC writes T1 and T2 as entry sections and B1 and B2 as ghost sections.
+*/
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	snapshotMagic   = "ZWIS"
+	snapshotVersion = 1
+)
+
+type snapshotPolicy byte
+
+const (
+	snapshotMemory snapshotPolicy = iota + 1
+	snapshotLRU
+	snapshotLFU
+	snapshotARC
+)
+
+// Snapshotter is implemented by caches that can save and restore their
+// contents.
+type Snapshotter interface {
+	// Snapshot writes the cache's entries, their remaining TTLs and the
+	// policy's metadata to w.
+	Snapshot(w io.Writer) error
+	// Restore replaces the cache's contents with a snapshot written by a
+	// cache of the same type. The replaced entries are reported to eviction
+	// listeners as flushed.
+	Restore(r io.Reader) error
+}
+
+// snapshotCodecs holds the codecs a cache uses for snapshots. err is set if
+// the configured codecs do not match the cache's types.
+type snapshotCodecs[K comparable, V any] struct {
+	key   Codec[K]
+	value Codec[V]
+	err   error
+}
+
+func newSnapshotCodecs[K comparable, V any](o options) snapshotCodecs[K, V] {
+	key, err := resolveCodec[K](o.keyCodec)
+	if err != nil {
+		return snapshotCodecs[K, V]{err: err}
+	}
+	value, err := resolveCodec[V](o.codec)
+	return snapshotCodecs[K, V]{key: key, value: value, err: err}
+}
+
+type snapshotEntry[K comparable, V any] struct {
+	key  K
+	val  V
+	ttl  time.Duration
+	freq int
+}
+
+// snapshot is the decoded form of a snapshot, independent of the policy.
+type snapshot[K comparable, V any] struct {
+	policy   snapshotPolicy
+	p        int
+	sections [][]snapshotEntry[K, V]
+	ghosts   [][]K
+}
+
+// remainingTTL converts an expiration in Unix nanoseconds to the time left.
+// It reports false if the entry has already expired.
+func remainingTTL(expiration, now int64) (time.Duration, bool) {
+	if expiration == 0 {
+		return 0, true
+	}
+	if expiration <= now {
+		return 0, false
+	}
+	return time.Duration(expiration - now), true
+}
+
+// restoredExpiration converts a remaining TTL back to an expiration.
+func restoredExpiration(ttl time.Duration, now int64) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return now + int64(ttl)
+}
+
+func (s *snapshot[K, V]) writeTo(w io.Writer, codecs snapshotCodecs[K, V]) error {
+	if codecs.err != nil {
+		return codecs.err
+	}
+
+	bw := bufio.NewWriter(w)
+	sw := snapshotWriter{w: bw}
+	sw.bytes([]byte(snapshotMagic))
+	sw.byte(snapshotVersion)
+	sw.byte(byte(s.policy))
+	sw.uvarint(uint64(s.p))
+
+	sw.uvarint(uint64(len(s.sections)))
+	for _, section := range s.sections {
+		sw.uvarint(uint64(len(section)))
+		for _, e := range section {
+			sw.encoded(codecs.key.Encode(e.key))
+			sw.encoded(codecs.value.Encode(e.val))
+			sw.varint(int64(e.ttl))
+			sw.uvarint(uint64(e.freq))
+		}
+	}
+
+	sw.uvarint(uint64(len(s.ghosts)))
+	for _, ghosts := range s.ghosts {
+		sw.uvarint(uint64(len(ghosts)))
+		for _, key := range ghosts {
+			sw.encoded(codecs.key.Encode(key))
+		}
+	}
+
+	if sw.err != nil {
+		return sw.err
+	}
+	return bw.Flush()
+}
+
+func readSnapshot[K comparable, V any](r io.Reader, codecs snapshotCodecs[K, V], policy snapshotPolicy) (*snapshot[K, V], error) {
+	if codecs.err != nil {
+		return nil, codecs.err
+	}
+
+	sr := snapshotReader{r: bufio.NewReader(r)}
+	if magic := sr.fixed(len(snapshotMagic)); sr.err == nil && string(magic) != snapshotMagic {
+		return nil, errors.New("zwis: not a snapshot")
+	}
+	if version := sr.byte(); sr.err == nil && version != snapshotVersion {
+		return nil, fmt.Errorf("zwis: unsupported snapshot version %d", version)
+	}
+	if got := snapshotPolicy(sr.byte()); sr.err == nil && got != policy {
+		return nil, fmt.Errorf("zwis: snapshot of policy %d cannot be restored into policy %d", got, policy)
+	}
+
+	s := &snapshot[K, V]{policy: policy, p: int(sr.uvarint())}
+
+	// Lengths are not trusted for preallocation; the slices grow as entries
+	// are actually decoded.
+	for i, n := 0, sr.count(); i < n && sr.err == nil; i++ {
+		var section []snapshotEntry[K, V]
+		for j, m := 0, sr.count(); j < m && sr.err == nil; j++ {
+			section = append(section, snapshotEntry[K, V]{
+				key:  decodeWith(&sr, codecs.key),
+				val:  decodeWith(&sr, codecs.value),
+				ttl:  time.Duration(sr.varint()),
+				freq: int(sr.uvarint()),
+			})
+		}
+		s.sections = append(s.sections, section)
+	}
+
+	for i, n := 0, sr.count(); i < n && sr.err == nil; i++ {
+		var ghosts []K
+		for j, m := 0, sr.count(); j < m && sr.err == nil; j++ {
+			ghosts = append(ghosts, decodeWith(&sr, codecs.key))
+		}
+		s.ghosts = append(s.ghosts, ghosts)
+	}
+
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	return s, nil
+}
+
+// snapshotWriter remembers the first error so that encoding can be written
+// as a straight sequence of calls.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (w *snapshotWriter) bytes(b []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(b)
+	}
+}
+
+func (w *snapshotWriter) byte(b byte) {
+	if w.err == nil {
+		w.err = w.w.WriteByte(b)
+	}
+}
+
+func (w *snapshotWriter) uvarint(v uint64) {
+	w.bytes(w.buf[:binary.PutUvarint(w.buf[:], v)])
+}
+
+func (w *snapshotWriter) varint(v int64) {
+	w.bytes(w.buf[:binary.PutVarint(w.buf[:], v)])
+}
+
+// encoded writes the output of a codec as a length-prefixed field.
+func (w *snapshotWriter) encoded(data []byte, err error) {
+	if err != nil {
+		if w.err == nil {
+			w.err = err
+		}
+		return
+	}
+	w.uvarint(uint64(len(data)))
+	w.bytes(data)
+}
+
+// snapshotReader is the reading counterpart of snapshotWriter.
+type snapshotReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+// fixed reads exactly n bytes. The buffer grows as data arrives, so a
+// corrupt length cannot trigger a huge allocation up front.
+func (r *snapshotReader) fixed(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	b, err := io.ReadAll(io.LimitReader(r.r, int64(n)))
+	if err == nil && len(b) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	r.err = err
+	return b
+}
+
+func (r *snapshotReader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	var b byte
+	b, r.err = r.r.ReadByte()
+	return b
+}
+
+func (r *snapshotReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	var v uint64
+	v, r.err = binary.ReadUvarint(r.r)
+	return v
+}
+
+func (r *snapshotReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	var v int64
+	v, r.err = binary.ReadVarint(r.r)
+	return v
+}
+
+// count reads a collection or field length.
+func (r *snapshotReader) count() int {
+	n := r.uvarint()
+	if r.err == nil && n > 1<<31 {
+		r.err = errors.New("zwis: corrupt snapshot")
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func decodeWith[T any](r *snapshotReader, codec Codec[T]) T {
+	var zero T
+	data := r.fixed(r.count())
+	if r.err != nil {
+		return zero
+	}
+	v, err := codec.Decode(data)
+	if err != nil {
+		r.err = err
+		return zero
+	}
+	return v
+}
+
+// timeFromUnixNano is the inverse of unixNano.
+func timeFromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// Snapshot writes every live entry and its remaining TTL to w.
+func (c *TypedMemoryCache[K, V]) Snapshot(w io.Writer) error {
+	c.mu.RLock()
+	now := c.now().UnixNano()
+	entries := make([]snapshotEntry[K, V], 0, len(c.items))
+	for key, item := range c.items {
+		if ttl, ok := remainingTTL(unixNano(item.expiration), now); ok {
+			entries = append(entries, snapshotEntry[K, V]{key: key, val: item.value, ttl: ttl})
+		}
+	}
+	c.mu.RUnlock()
+
+	s := snapshot[K, V]{policy: snapshotMemory, sections: [][]snapshotEntry[K, V]{entries}}
+	return s.writeTo(w, c.codecs)
+}
+
+// Restore replaces the cache's contents with a snapshot of a MemoryCache.
+func (c *TypedMemoryCache[K, V]) Restore(r io.Reader) error {
+	s, err := readSnapshot(r, c.codecs, snapshotMemory)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	c.flushLocked()
+	for _, section := range s.sections {
+		if c.capacity > 0 && len(section) > c.capacity {
+			section = section[len(section)-c.capacity:]
+		}
+		for _, e := range c.fitNewest(section) {
+			expiration := restoredExpiration(e.ttl, now)
+			c.items[e.key] = item[K, V]{
+				value:      e.val,
+				expiration: timeFromUnixNano(expiration),
+				expiry:     c.expiries.track(nil, e.key, expiration),
+				slot:       len(c.keys),
+				cost:       c.costOf(e.key, e.val),
+			}
+			c.keys = append(c.keys, e.key)
+		}
+	}
+	c.entries.Store(int64(len(c.items)))
+	return nil
+}
+
+// Snapshot writes every live entry and its remaining TTL to w, preserving
+// recency order.
+func (lru *TypedLRUCache[K, V]) Snapshot(w io.Writer) error {
+	lru.mutex.RLock()
+	now := lru.now().UnixNano()
+	entries := make([]snapshotEntry[K, V], 0, lru.list.Len())
+	for elem := lru.list.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*entry[K, V])
+		if ttl, ok := remainingTTL(unixNano(entry.expiration), now); ok {
+			entries = append(entries, snapshotEntry[K, V]{key: entry.key, val: entry.value, ttl: ttl})
+		}
+	}
+	lru.mutex.RUnlock()
+
+	s := snapshot[K, V]{policy: snapshotLRU, sections: [][]snapshotEntry[K, V]{entries}}
+	return s.writeTo(w, lru.codecs)
+}
+
+// Restore replaces the cache's contents with a snapshot of an LRUCache. If
+// the snapshot holds more entries than the capacity or cost budget, the least
+// recently used ones are dropped. Costs are not saved: restored entries are
+// weighed again with the cache's weigher.
+func (lru *TypedLRUCache[K, V]) Restore(r io.Reader) error {
+	s, err := readSnapshot(r, lru.codecs, snapshotLRU)
+	if err != nil {
+		return err
+	}
+
+	lru.mutex.Lock()
+	defer lru.unlock(&lru.mutex)
+
+	now := lru.now().UnixNano()
+	lru.flushLocked()
+	for _, section := range s.sections {
+		if lru.capacity > 0 && len(section) > lru.capacity {
+			section = section[len(section)-lru.capacity:]
+		}
+		for _, e := range lru.fitNewest(section) {
+			expiration := restoredExpiration(e.ttl, now)
+			entry := &entry[K, V]{key: e.key, value: e.val, expiration: timeFromUnixNano(expiration), cost: lru.costOf(e.key, e.val)}
+			entry.expiry = lru.expiries.track(nil, e.key, expiration)
+			lru.cache[e.key] = lru.list.PushFront(entry)
+		}
+	}
+	lru.entries.Store(int64(lru.list.Len()))
+	return nil
+}
+
+// Snapshot writes every live entry, its remaining TTL and its access
+// frequency to w.
+func (c *TypedLFUCache[K, V]) Snapshot(w io.Writer) error {
+	c.mu.Lock()
+	now := c.now().UnixNano()
+	entries := make([]snapshotEntry[K, V], 0, len(c.items))
+	for _, item := range c.items {
+		if ttl, ok := remainingTTL(item.expiration, now); ok {
+			entries = append(entries, snapshotEntry[K, V]{key: item.key, val: item.value, ttl: ttl, freq: item.frequency})
+		}
+	}
+	c.mu.Unlock()
+
+	s := snapshot[K, V]{policy: snapshotLFU, sections: [][]snapshotEntry[K, V]{entries}}
+	return s.writeTo(w, c.codecs)
+}
+
+// Restore replaces the cache's contents with a snapshot of an LFUCache. If
+// the snapshot holds more entries than the capacity or cost budget, the least
+// frequently used ones are dropped. Restored entries are weighed again with
+// the cache's weigher.
+func (c *TypedLFUCache[K, V]) Restore(r io.Reader) error {
+	s, err := readSnapshot(r, c.codecs, snapshotLFU)
+	if err != nil {
+		return err
+	}
+
+	var entries []snapshotEntry[K, V]
+	for _, section := range s.sections {
+		entries = append(entries, section...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].freq < entries[j].freq })
+	if c.capacity > 0 && len(entries) > c.capacity {
+		entries = entries[len(entries)-c.capacity:]
+	}
+
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	c.flushLocked()
+	// Insert from the lowest frequency up, so every new bucket goes at the
+	// tail of the list.
+	entries = c.fitNewest(entries)
+	for _, e := range entries {
+		expiration := restoredExpiration(e.ttl, now)
+		item := &lfuItem[K, V]{key: e.key, value: e.val, frequency: max(e.freq, 1), expiration: expiration, cost: c.costOf(e.key, e.val)}
+		item.expiry = c.expiries.track(nil, e.key, expiration)
+		c.items[e.key] = item
+		c.addToFreqNode(item, c.head.prev)
+	}
+	if c.dynamicAged && len(entries) > 0 {
+		// The age is not saved; restart it just below the lowest priority so
+		// new entries compete with the restored ones.
+		c.age = c.head.next.freq - 1
+	}
+	for _, item := range c.items {
+		item.count = item.frequency - c.age
+	}
+	c.entries.Store(int64(len(c.items)))
+	return nil
+}
+
+// Snapshot writes T1 and T2 with their remaining TTLs, the B1 and B2 ghost
+// keys and the adaptive target p to w.
+func (c *TypedARCCache[K, V]) Snapshot(w io.Writer) error {
+	c.mu.Lock()
+	now := c.now().UnixNano()
+	s := snapshot[K, V]{policy: snapshotARC, p: c.p}
+	for _, l := range []*list.List{c.t1, c.t2} {
+		entries := make([]snapshotEntry[K, V], 0, l.Len())
+		for elt := l.Back(); elt != nil; elt = elt.Prev() {
+			item := elt.Value.(*arcItem[K, V])
+			if ttl, ok := remainingTTL(item.expiration, now); ok {
+				entries = append(entries, snapshotEntry[K, V]{key: item.key, val: item.value, ttl: ttl})
+			}
+		}
+		s.sections = append(s.sections, entries)
+	}
+	for _, l := range []*list.List{c.b1, c.b2} {
+		keys := make([]K, 0, l.Len())
+		for elt := l.Back(); elt != nil; elt = elt.Prev() {
+			keys = append(keys, elt.Value.(*arcItem[K, V]).key)
+		}
+		s.ghosts = append(s.ghosts, keys)
+	}
+	c.mu.Unlock()
+
+	return s.writeTo(w, c.codecs)
+}
+
+// Restore replaces the cache's contents with a snapshot of an ARCCache,
+// including list membership and the adaptive target p. Restored entries are
+// weighed again with the cache's weigher.
+func (c *TypedARCCache[K, V]) Restore(r io.Reader) error {
+	s, err := readSnapshot(r, c.codecs, snapshotARC)
+	if err != nil {
+		return err
+	}
+	if len(s.sections) != 2 || len(s.ghosts) != 2 {
+		return errors.New("zwis: corrupt ARC snapshot")
+	}
+
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	c.flushLocked()
+	c.p = min(s.p, c.capacity)
+
+	// If the snapshot came from a larger cache, keep the most recent entries
+	// of each list, giving up T1 entries first.
+	t1, t2 := s.sections[0], s.sections[1]
+	if len(t2) > c.capacity {
+		t2 = t2[len(t2)-c.capacity:]
+	}
+	if len(t1)+len(t2) > c.capacity {
+		t1 = t1[len(t1)+len(t2)-c.capacity:]
+	}
+	t2 = c.fitNewest(t2)
+	t1 = c.fitNewest(t1)
+	for i, entries := range [][]snapshotEntry[K, V]{t1, t2} {
+		l, tag := c.t1, arcT1
+		if i == 1 {
+			l, tag = c.t2, arcT2
+		}
+		for _, e := range entries {
+			expiration := restoredExpiration(e.ttl, now)
+			item := &arcItem[K, V]{key: e.key, value: e.val, expiration: expiration, list: tag, cost: c.costOf(e.key, e.val)}
+			item.expiry = c.expiries.track(nil, e.key, expiration)
+			c.cache[e.key] = l.PushFront(item)
+		}
+	}
+	for i, keys := range s.ghosts {
+		l, tag := c.b1, arcB1
+		if i == 1 {
+			l, tag = c.b2, arcB2
+		}
+		if len(keys) > c.capacity {
+			keys = keys[len(keys)-c.capacity:]
+		}
+		for _, key := range keys {
+			if _, resident := c.cache[key]; resident {
+				continue
+			}
+			c.ghosts[key] = l.PushFront(&arcItem[K, V]{key: key, list: tag})
+		}
+	}
+	c.entries.Store(int64(len(c.cache)))
+	return nil
+}
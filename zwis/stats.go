@@ -0,0 +1,112 @@
+package zwis
+
+import (
+	"sync/atomic"
+)
+
+// Stats is a point-in-time snapshot of a cache's counters.
+type Stats struct {
+	Hits              uint64  // Get calls that found a live entry
+	Misses            uint64  // Get calls that found nothing or an expired entry
+	Sets              uint64  // Set calls
+	Deletes           uint64  // Delete calls
+	CapacityEvictions uint64  // Entries dropped to make room for new ones
+	ExpiredEvictions  uint64  // Entries dropped because their TTL elapsed
+	ExplicitEvictions uint64  // Entries dropped by Delete or Flush
+	Entries           int64   // Entries currently held
+	HitRatio          float64 // Hits / (Hits + Misses), or 0 before any Get
+}
+
+// StatsProvider is implemented by caches that keep usage statistics.
+type StatsProvider interface {
+	// Stats returns a snapshot of the cache's counters.
+	Stats() Stats
+	// ResetStats zeroes every counter except the current entry count.
+	ResetStats()
+}
+
+// statsCounter is embedded by every cache. All counters are atomic so
+// recording and reading them never touches the cache's own lock.
+type statsCounter struct {
+	hits              atomic.Uint64
+	misses            atomic.Uint64
+	sets              atomic.Uint64
+	deletes           atomic.Uint64
+	capacityEvictions atomic.Uint64
+	expiredEvictions  atomic.Uint64
+	explicitEvictions atomic.Uint64
+	entries           atomic.Int64
+	disabled          bool // Set by WithStats(false) before the cache is used
+}
+
+// Stats returns a snapshot of the cache's counters.
+func (s *statsCounter) Stats() Stats {
+	stats := Stats{
+		Hits:              s.hits.Load(),
+		Misses:            s.misses.Load(),
+		Sets:              s.sets.Load(),
+		Deletes:           s.deletes.Load(),
+		CapacityEvictions: s.capacityEvictions.Load(),
+		ExpiredEvictions:  s.expiredEvictions.Load(),
+		ExplicitEvictions: s.explicitEvictions.Load(),
+		Entries:           s.entries.Load(),
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// ResetStats zeroes every counter except the current entry count.
+func (s *statsCounter) ResetStats() {
+	s.hits.Store(0)
+	s.misses.Store(0)
+	s.sets.Store(0)
+	s.deletes.Store(0)
+	s.capacityEvictions.Store(0)
+	s.expiredEvictions.Store(0)
+	s.explicitEvictions.Store(0)
+}
+
+func (s *statsCounter) recordHit() {
+	if !s.disabled {
+		s.hits.Add(1)
+	}
+}
+
+func (s *statsCounter) recordMiss() {
+	if !s.disabled {
+		s.misses.Add(1)
+	}
+}
+
+func (s *statsCounter) recordSet() {
+	if !s.disabled {
+		s.sets.Add(1)
+	}
+}
+
+func (s *statsCounter) recordDelete() {
+	if !s.disabled {
+		s.deletes.Add(1)
+	}
+}
+
+// recordAdded is called whenever a new entry becomes resident.
+func (s *statsCounter) recordAdded() { s.entries.Add(1) }
+
+// recordEviction is called whenever an entry leaves the cache.
+func (s *statsCounter) recordEviction(reason EvictReason) {
+	switch reason {
+	case EvictReasonCapacity:
+		s.capacityEvictions.Add(1)
+	case EvictReasonExpired:
+		s.expiredEvictions.Add(1)
+	case EvictReasonDeleted, EvictReasonFlushed:
+		s.explicitEvictions.Add(1)
+	default:
+		// A replaced value leaves its entry in place.
+		return
+	}
+	s.entries.Add(-1)
+}
@@ -0,0 +1,169 @@
+package zwis
+
+/*
+TieredCache puts a small, fast cache (L1) in front of a larger or slower one (L2). Reads check L1 first and copy L2 hits into L1; writes, deletes and flushes go to both tiers so that L1 never serves a value overwritten or deleted through the TieredCache. L1 cannot learn when L2 expires or evicts an entry, so copies promoted from L2 are kept for a finite promotion TTL, which bounds how long L1 can serve a value L2 no longer holds.
+*/
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// defaultPromotionTTL is the promotion TTL used when WithPromotionTTL is not
+// given a positive one.
+const defaultPromotionTTL = time.Minute
+
+// WriteMode controls how a TieredCache handles Set.
+type WriteMode int
+
+const (
+	// WriteThrough writes new values to both tiers.
+	WriteThrough WriteMode = iota
+	// WriteAround writes new values to L2 only and drops any stale copy
+	// from L1, so L1 is only filled by reads.
+	WriteAround
+)
+
+// TieredStats reports where a TieredCache's reads were served from, along
+// with each tier's own statistics when it provides them.
+type TieredStats struct {
+	L1Hits uint64
+	L2Hits uint64
+	Misses uint64
+	L1     Stats
+	L2     Stats
+}
+
+// TypedTieredCache composes two caches into a two-level cache.
+type TypedTieredCache[K comparable, V any] struct {
+	l1         TypedCache[K, V]
+	l2         TypedCache[K, V]
+	writeMode  WriteMode
+	promoteTTL time.Duration
+	l1Hits     atomic.Uint64
+	l2Hits     atomic.Uint64
+	statsCounter
+}
+
+// TieredCache is a TypedTieredCache with string keys and interface{} values.
+type TieredCache = TypedTieredCache[string, interface{}]
+
+// NewTieredCache creates a two-level cache reading from l1 before l2. Use
+// WithWriteMode to choose how writes reach l1 and WithPromotionTTL to bound
+// how long values copied from l2 stay in l1.
+func NewTieredCache(l1, l2 Cache, opts ...Option) *TieredCache {
+	return NewTypedTieredCache[string, interface{}](l1, l2, opts...)
+}
+
+// NewTypedTieredCache creates a two-level cache reading from l1 before l2.
+// Use WithWriteMode to choose how writes reach l1 and WithPromotionTTL to
+// bound how long values copied from l2 stay in l1.
+func NewTypedTieredCache[K comparable, V any](l1, l2 TypedCache[K, V], opts ...Option) *TypedTieredCache[K, V] {
+	o := newOptions(opts)
+	promoteTTL := o.promotionTTL
+	if promoteTTL <= 0 {
+		promoteTTL = defaultPromotionTTL
+	}
+	return &TypedTieredCache[K, V]{
+		l1:         l1,
+		l2:         l2,
+		writeMode:  o.writeMode,
+		promoteTTL: promoteTTL,
+	}
+}
+
+// Get reads from L1, then from L2. An L2 hit is copied into L1 for the
+// promotion TTL.
+func (c *TypedTieredCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	if value, ok := c.l1.Get(ctx, key); ok {
+		c.l1Hits.Add(1)
+		c.recordHit()
+		return value, true
+	}
+
+	value, ok := c.l2.Get(ctx, key)
+	if !ok {
+		c.recordMiss()
+		return value, false
+	}
+
+	c.l2Hits.Add(1)
+	c.recordHit()
+	c.l1.Set(ctx, key, value, c.promoteTTL)
+	return value, true
+}
+
+// Set writes to L2 and, depending on the write mode, to L1.
+func (c *TypedTieredCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	c.recordSet()
+	if err := c.l2.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	if c.writeMode == WriteAround {
+		return c.l1.Delete(ctx, key)
+	}
+	return c.l1.Set(ctx, key, value, ttl)
+}
+
+// Delete removes the key from both tiers.
+func (c *TypedTieredCache[K, V]) Delete(ctx context.Context, key K) error {
+	c.recordDelete()
+	return errors.Join(c.l1.Delete(ctx, key), c.l2.Delete(ctx, key))
+}
+
+// Flush flushes both tiers.
+func (c *TypedTieredCache[K, V]) Flush(ctx context.Context) error {
+	return errors.Join(c.l1.Flush(ctx), c.l2.Flush(ctx))
+}
+
+// Stats returns the tiered cache's own counters: a hit in either tier counts
+// as a hit. Entries reports the L2 entry count, as L2 holds every entry.
+func (c *TypedTieredCache[K, V]) Stats() Stats {
+	stats := c.statsCounter.Stats()
+	if sp, ok := c.l2.(StatsProvider); ok {
+		stats.Entries = sp.Stats().Entries
+	}
+	return stats
+}
+
+// TierStats breaks hits down by tier.
+func (c *TypedTieredCache[K, V]) TierStats() TieredStats {
+	stats := TieredStats{
+		L1Hits: c.l1Hits.Load(),
+		L2Hits: c.l2Hits.Load(),
+		Misses: c.misses.Load(),
+	}
+	if sp, ok := c.l1.(StatsProvider); ok {
+		stats.L1 = sp.Stats()
+	}
+	if sp, ok := c.l2.(StatsProvider); ok {
+		stats.L2 = sp.Stats()
+	}
+	return stats
+}
+
+// ResetStats resets the tiered cache's counters and those of both tiers.
+func (c *TypedTieredCache[K, V]) ResetStats() {
+	c.statsCounter.ResetStats()
+	c.l1Hits.Store(0)
+	c.l2Hits.Store(0)
+	for _, tier := range []TypedCache[K, V]{c.l1, c.l2} {
+		if sp, ok := tier.(StatsProvider); ok {
+			sp.ResetStats()
+		}
+	}
+}
+
+// Close closes both tiers if they hold background resources.
+func (c *TypedTieredCache[K, V]) Close() error {
+	var errs []error
+	for _, tier := range []TypedCache[K, V]{c.l1, c.l2} {
+		if closer, ok := tier.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,75 @@
+package zwis
+
+import "time"
+
+// Clock tells a cache the current time and drives its janitor. Inject one
+// with WithClock to control expiry, for example with zwistest.FakeClock in
+// tests.
+type Clock interface {
+	Now() time.Time
+
+	// Every calls f every d until the returned stop function is called.
+	// stop waits for a call of f that is already running to return.
+	Every(d time.Duration, f func()) (stop func())
+}
+
+// SystemClock is the Clock used when none is configured. It reads the time
+// from the time package and runs Every on a time.Ticker.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+func (SystemClock) Every(d time.Duration, f func()) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// timekeeper is embedded by every cache that expires entries. It holds the
+// cache's clock and the TTL used for entries set without one.
+type timekeeper struct {
+	clock      Clock
+	defaultTTL time.Duration
+}
+
+func newTimekeeper(o options) timekeeper {
+	t := timekeeper{clock: o.clock, defaultTTL: o.defaultTTL}
+	if t.clock == nil {
+		t.clock = SystemClock{}
+	}
+	return t
+}
+
+// now returns the current time according to the cache's clock.
+func (t timekeeper) now() time.Time {
+	return t.clock.Now()
+}
+
+// expiresAt returns when an entry set with ttl expires, or the zero time if
+// it never does. A ttl of 0 uses the default TTL, and a negative ttl never
+// expires.
+func (t timekeeper) expiresAt(ttl time.Duration) time.Time {
+	if ttl == 0 {
+		ttl = t.defaultTTL
+	}
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return t.now().Add(ttl)
+}
@@ -0,0 +1,402 @@
+package zwis
+
+/*
+Window TinyLFU (W-TinyLFU) combines a small LRU admission window with a larger segmented-LRU main region. New entries always enter the window. When the window overflows, its least recently used entry becomes a candidate for the main region and only gets in if it has been accessed more often than the entry the main region would evict. Access frequencies are estimated by a Count-Min Sketch of 4-bit counters that are halved periodically so that old popularity fades, fronted by a doorkeeper Bloom filter that keeps one-hit wonders out of the sketch.
+
+The main region is split into a probation segment, for entries admitted from the window, and a protected segment, for entries hit again while on probation. This makes the policy resistant to scans while the window still lets bursts of new keys be served.
+*/
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// tinyLFUSegment identifies which region of the cache an entry lives in.
+type tinyLFUSegment uint8
+
+const (
+	tinyLFUWindow tinyLFUSegment = iota
+	tinyLFUProbation
+	tinyLFUProtected
+)
+
+// TypedTinyLFUCache implements the W-TinyLFU eviction policy.
+type TypedTinyLFUCache[K comparable, V any] struct {
+	capacity     int
+	windowCap    int // Maximum size of the admission window
+	protectedCap int // Maximum size of the protected segment
+	window       *list.List
+	probation    *list.List
+	protected    *list.List
+	cache        map[K]*list.Element
+	sketch       *countMinSketch
+	expiries     expiryQueue[K]
+	janitor      *janitor
+	mu           sync.Mutex
+	evictionHooks[K, V]
+	timekeeper
+}
+
+// TinyLFUCache is a TypedTinyLFUCache with string keys and interface{} values.
+type TinyLFUCache = TypedTinyLFUCache[string, interface{}]
+
+type tinyLFUEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration int64
+	expiry     *expiryEntry[K]
+	segment    tinyLFUSegment
+}
+
+// NewTinyLFUCache creates a W-TinyLFU cache with the given capacity.
+func NewTinyLFUCache(capacity int, opts ...Option) *TinyLFUCache {
+	return NewTypedTinyLFUCache[string, interface{}](capacity, opts...)
+}
+
+// NewTypedTinyLFUCache creates a generic W-TinyLFU cache with the given
+// capacity. 1% of the capacity is used for the admission window and 80% of
+// the rest for the protected segment.
+func NewTypedTinyLFUCache[K comparable, V any](capacity int, opts ...Option) *TypedTinyLFUCache[K, V] {
+	o := newOptions(opts)
+	windowCap := max(1, capacity/100)
+	c := &TypedTinyLFUCache[K, V]{
+		capacity:     capacity,
+		windowCap:    windowCap,
+		protectedCap: (capacity - windowCap) * 8 / 10,
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		cache:        make(map[K]*list.Element),
+		sketch:       newCountMinSketch(capacity),
+		timekeeper:   newTimekeeper(o),
+	}
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c
+}
+
+func (c *TypedTinyLFUCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.getLocked(key)
+}
+
+// getLocked is Get with c.mu held.
+func (c *TypedTinyLFUCache[K, V]) getLocked(key K) (V, bool) {
+	var zero V
+	c.sketch.increment(hashKey(key))
+
+	elem, ok := c.cache[key]
+	if !ok {
+		c.recordMiss()
+		return zero, false
+	}
+
+	entry := elem.Value.(*tinyLFUEntry[K, V])
+	if entry.expiration > 0 && entry.expiration < c.now().UnixNano() {
+		c.remove(elem, EvictReasonExpired)
+		c.recordMiss()
+		return zero, false
+	}
+
+	c.touch(elem)
+	c.recordHit()
+	return entry.value, true
+}
+
+func (c *TypedTinyLFUCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, value, ttl)
+}
+
+// setLocked is Set with c.mu held.
+func (c *TypedTinyLFUCache[K, V]) setLocked(key K, value V, ttl time.Duration) error {
+	expiration := unixNano(c.expiresAt(ttl))
+
+	c.recordSet()
+	c.sketch.increment(hashKey(key))
+
+	if elem, ok := c.cache[key]; ok {
+		entry := elem.Value.(*tinyLFUEntry[K, V])
+		c.evicted(key, entry.value, EvictReasonReplaced)
+		entry.value = value
+		entry.expiration = expiration
+		entry.expiry = c.expiries.track(entry.expiry, key, expiration)
+		c.touch(elem)
+		return nil
+	}
+
+	entry := &tinyLFUEntry[K, V]{key: key, value: value, expiration: expiration, segment: tinyLFUWindow}
+	entry.expiry = c.expiries.track(nil, key, expiration)
+	c.cache[key] = c.window.PushFront(entry)
+	c.recordAdded()
+
+	if c.window.Len() > c.windowCap {
+		c.admit(c.window.Back())
+	}
+	return nil
+}
+
+func (c *TypedTinyLFUCache[K, V]) Delete(ctx context.Context, key K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
+
+// deleteLocked is Delete with c.mu held.
+func (c *TypedTinyLFUCache[K, V]) deleteLocked(key K) error {
+	c.recordDelete()
+	if elem, ok := c.cache[key]; ok {
+		c.remove(elem, EvictReasonDeleted)
+	}
+	return nil
+}
+
+// GetMany looks up every key under a single lock acquisition.
+func (c *TypedTinyLFUCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return getMany(keys, c.getLocked)
+}
+
+// SetMany stores every item under a single lock acquisition.
+func (c *TypedTinyLFUCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return c.setLocked(key, item.Value, item.TTL)
+	})
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedTinyLFUCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
+
+func (c *TypedTinyLFUCache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	for _, l := range []*list.List{c.window, c.probation, c.protected} {
+		for elem := l.Back(); elem != nil; elem = elem.Prev() {
+			entry := elem.Value.(*tinyLFUEntry[K, V])
+			c.evicted(entry.key, entry.value, EvictReasonFlushed)
+		}
+		l.Init()
+	}
+	c.cache = make(map[K]*list.Element)
+	c.expiries = nil
+	c.sketch.reset()
+	return nil
+}
+
+// Close stops the background janitor, if one was configured.
+func (c *TypedTinyLFUCache[K, V]) Close() error {
+	c.janitor.Close()
+	return nil
+}
+
+// deleteExpired removes every entry whose TTL has elapsed.
+func (c *TypedTinyLFUCache[K, V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(c.cache[key], EvictReasonExpired)
+	}
+}
+
+// touch records a hit on a resident entry. Window and protected entries move
+// to the front of their segment; probation entries are promoted to the
+// protected segment, demoting its least recently used entry if it is full.
+func (c *TypedTinyLFUCache[K, V]) touch(elem *list.Element) {
+	entry := elem.Value.(*tinyLFUEntry[K, V])
+	switch entry.segment {
+	case tinyLFUWindow:
+		c.window.MoveToFront(elem)
+	case tinyLFUProtected:
+		c.protected.MoveToFront(elem)
+	case tinyLFUProbation:
+		c.probation.Remove(elem)
+		entry.segment = tinyLFUProtected
+		c.cache[entry.key] = c.protected.PushFront(entry)
+		if c.protected.Len() > c.protectedCap {
+			demoted := c.protected.Back()
+			c.protected.Remove(demoted)
+			demotedEntry := demoted.Value.(*tinyLFUEntry[K, V])
+			demotedEntry.segment = tinyLFUProbation
+			c.cache[demotedEntry.key] = c.probation.PushFront(demotedEntry)
+		}
+	}
+}
+
+// admit moves the window's least recently used entry into the main region,
+// or evicts it if the main region is full and the entry it would displace is
+// used at least as often.
+func (c *TypedTinyLFUCache[K, V]) admit(candidate *list.Element) {
+	entry := candidate.Value.(*tinyLFUEntry[K, V])
+	mainCap := c.capacity - c.windowCap
+
+	if c.probation.Len()+c.protected.Len() >= mainCap {
+		victim := c.probation.Back()
+		if victim == nil {
+			victim = c.protected.Back()
+		}
+		if victim == nil {
+			c.remove(candidate, EvictReasonCapacity)
+			return
+		}
+
+		victimKey := victim.Value.(*tinyLFUEntry[K, V]).key
+		if c.sketch.estimate(hashKey(entry.key)) <= c.sketch.estimate(hashKey(victimKey)) {
+			c.remove(candidate, EvictReasonCapacity)
+			return
+		}
+		c.remove(victim, EvictReasonCapacity)
+	}
+
+	c.window.Remove(candidate)
+	entry.segment = tinyLFUProbation
+	c.cache[entry.key] = c.probation.PushFront(entry)
+}
+
+func (c *TypedTinyLFUCache[K, V]) remove(elem *list.Element, reason EvictReason) {
+	entry := elem.Value.(*tinyLFUEntry[K, V])
+	switch entry.segment {
+	case tinyLFUWindow:
+		c.window.Remove(elem)
+	case tinyLFUProbation:
+		c.probation.Remove(elem)
+	case tinyLFUProtected:
+		c.protected.Remove(elem)
+	}
+	c.expiries.untrack(entry.expiry)
+	delete(c.cache, entry.key)
+	c.evicted(entry.key, entry.value, reason)
+}
+
+// countMinSketch estimates access frequencies with saturating 4-bit counters
+// in sketchDepth rows. Once sampleSize increments have been recorded every
+// counter is halved, so popularity decays over time. A doorkeeper Bloom
+// filter absorbs the first access to each key, so keys seen only once never
+// reach the sketch.
+type countMinSketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	doorkeeper []uint64
+	additions  int
+	sampleSize int
+}
+
+const (
+	sketchDepth      = 4
+	sketchMaxCounter = 15
+)
+
+// sketchSeeds give each row an independent hash of the same key.
+var sketchSeeds = [sketchDepth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 16
+	for width < 4*capacity {
+		width <<= 1
+	}
+	sampleSize := 10 * max(capacity, 1)
+	s := &countMinSketch{
+		mask:       uint64(width - 1),
+		doorkeeper: make([]uint64, (sampleSize+63)/64),
+		sampleSize: sampleSize,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// increment records an access to the key with the given hash.
+func (s *countMinSketch) increment(hash uint64) {
+	if !s.admitDoorkeeper(hash) {
+		return
+	}
+	for i := range s.rows {
+		idx := mix64(hash^sketchSeeds[i]) & s.mask
+		if s.rows[i][idx] < sketchMaxCounter {
+			s.rows[i][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.age()
+	}
+}
+
+// estimate returns the approximate access count for the key with the given
+// hash.
+func (s *countMinSketch) estimate(hash uint64) int {
+	least := uint8(sketchMaxCounter)
+	for i := range s.rows {
+		idx := mix64(hash^sketchSeeds[i]) & s.mask
+		if v := s.rows[i][idx]; v < least {
+			least = v
+		}
+	}
+	if s.doorkeeperContains(hash) {
+		return int(least) + 1
+	}
+	return int(least)
+}
+
+// age halves every counter and clears the doorkeeper.
+func (s *countMinSketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	for i := range s.doorkeeper {
+		s.doorkeeper[i] = 0
+	}
+	s.additions /= 2
+}
+
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] = 0
+		}
+	}
+	for i := range s.doorkeeper {
+		s.doorkeeper[i] = 0
+	}
+	s.additions = 0
+}
+
+// doorkeeperBits returns the two Bloom filter bit positions for a hash.
+func (s *countMinSketch) doorkeeperBits(hash uint64) (uint64, uint64) {
+	bits := uint64(len(s.doorkeeper) * 64)
+	return hash % bits, (hash >> 32) % bits
+}
+
+func (s *countMinSketch) doorkeeperContains(hash uint64) bool {
+	a, b := s.doorkeeperBits(hash)
+	return s.doorkeeper[a/64]&(1<<(a%64)) != 0 && s.doorkeeper[b/64]&(1<<(b%64)) != 0
+}
+
+// admitDoorkeeper adds the hash to the doorkeeper and reports whether it was
+// already there.
+func (s *countMinSketch) admitDoorkeeper(hash uint64) bool {
+	if s.doorkeeperContains(hash) {
+		return true
+	}
+	a, b := s.doorkeeperBits(hash)
+	s.doorkeeper[a/64] |= 1 << (a % 64)
+	s.doorkeeper[b/64] |= 1 << (b % 64)
+	return false
+}
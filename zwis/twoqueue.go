@@ -0,0 +1,262 @@
+package zwis
+
+/*
+2Q keeps newly added entries in a FIFO queue (A1in) and only moves an entry into the main LRU queue (Am) once it is requested again after leaving A1in. Keys evicted from A1in are remembered in a ghost queue (A1out); setting one of them again admits it straight to Am. Entries touched only once, such as those read by a scan, therefore pass through A1in without disturbing the working set in Am.
+*/
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// twoQueueList identifies which queue of a TwoQueueCache an entry is in.
+type twoQueueList uint8
+
+const (
+	twoQueueA1in twoQueueList = iota
+	twoQueueAm
+)
+
+// TypedTwoQueueCache implements the full 2Q eviction policy.
+type TypedTwoQueueCache[K comparable, V any] struct {
+	capacity  int
+	recentCap int // Maximum size of A1in before it is reclaimed from
+	ghostCap  int // Maximum number of keys remembered in A1out
+	a1in      *list.List
+	a1out     *list.List
+	am        *list.List
+	cache     map[K]*list.Element
+	ghosts    map[K]*list.Element
+	expiries  expiryQueue[K]
+	janitor   *janitor
+	mu        sync.Mutex
+	evictionHooks[K, V]
+	timekeeper
+}
+
+// TwoQueueCache is a TypedTwoQueueCache with string keys and interface{}
+// values.
+type TwoQueueCache = TypedTwoQueueCache[string, interface{}]
+
+type twoQueueEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration time.Time
+	expiry     *expiryEntry[K]
+	list       twoQueueList
+}
+
+// NewTwoQueueCache creates a 2Q cache with the given capacity.
+func NewTwoQueueCache(capacity int, opts ...Option) *TwoQueueCache {
+	return NewTypedTwoQueueCache[string, interface{}](capacity, opts...)
+}
+
+// NewTypedTwoQueueCache creates a generic 2Q cache with the given capacity.
+// The sizes of A1in and A1out are set with WithTwoQueueRatios.
+func NewTypedTwoQueueCache[K comparable, V any](capacity int, opts ...Option) *TypedTwoQueueCache[K, V] {
+	o := newOptions(opts)
+	c := &TypedTwoQueueCache[K, V]{
+		capacity:   capacity,
+		recentCap:  max(1, int(float64(capacity)*ratioOr(o.recentRatio, 0.25))),
+		ghostCap:   max(1, int(float64(capacity)*ratioOr(o.ghostRatio, 0.5))),
+		a1in:       list.New(),
+		a1out:      list.New(),
+		am:         list.New(),
+		cache:      make(map[K]*list.Element),
+		ghosts:     make(map[K]*list.Element),
+		timekeeper: newTimekeeper(o),
+	}
+	c.configureHooks(o)
+	c.janitor = newJanitor(c.clock, o.janitorInterval, c.deleteExpired)
+	return c
+}
+
+func (c *TypedTwoQueueCache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.getLocked(key)
+}
+
+// getLocked is Get with c.mu held.
+func (c *TypedTwoQueueCache[K, V]) getLocked(key K) (V, bool) {
+	var zero V
+
+	elem, ok := c.cache[key]
+	if !ok {
+		c.recordMiss()
+		return zero, false
+	}
+
+	entry := elem.Value.(*twoQueueEntry[K, V])
+	if !entry.expiration.IsZero() && entry.expiration.Before(c.now()) {
+		c.remove(elem, EvictReasonExpired)
+		c.recordMiss()
+		return zero, false
+	}
+
+	// A1in is a FIFO: hits there do not reorder it.
+	if entry.list == twoQueueAm {
+		c.am.MoveToFront(elem)
+	}
+	c.recordHit()
+	return entry.value, true
+}
+
+func (c *TypedTwoQueueCache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.setLocked(key, value, ttl)
+}
+
+// setLocked is Set with c.mu held.
+func (c *TypedTwoQueueCache[K, V]) setLocked(key K, value V, ttl time.Duration) error {
+	expiration := c.expiresAt(ttl)
+
+	c.recordSet()
+	if elem, ok := c.cache[key]; ok {
+		entry := elem.Value.(*twoQueueEntry[K, V])
+		c.evicted(key, entry.value, EvictReasonReplaced)
+		entry.value = value
+		entry.expiration = expiration
+		entry.expiry = c.expiries.track(entry.expiry, key, unixNano(expiration))
+		if entry.list == twoQueueAm {
+			c.am.MoveToFront(elem)
+		}
+		return nil
+	}
+
+	// Forget the ghost before reclaiming, which could push it out of A1out.
+	ghost, remembered := c.ghosts[key]
+	if remembered {
+		c.a1out.Remove(ghost)
+		delete(c.ghosts, key)
+	}
+	if c.a1in.Len()+c.am.Len() >= c.capacity {
+		c.reclaim()
+	}
+
+	entry := &twoQueueEntry[K, V]{key: key, value: value, expiration: expiration}
+	entry.expiry = c.expiries.track(nil, key, unixNano(expiration))
+	if remembered {
+		entry.list = twoQueueAm
+		c.cache[key] = c.am.PushFront(entry)
+	} else {
+		entry.list = twoQueueA1in
+		c.cache[key] = c.a1in.PushFront(entry)
+	}
+	c.recordAdded()
+	return nil
+}
+
+func (c *TypedTwoQueueCache[K, V]) Delete(ctx context.Context, key K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return c.deleteLocked(key)
+}
+
+// deleteLocked is Delete with c.mu held.
+func (c *TypedTwoQueueCache[K, V]) deleteLocked(key K) error {
+	c.recordDelete()
+	if elem, ok := c.cache[key]; ok {
+		c.remove(elem, EvictReasonDeleted)
+	}
+	return nil
+}
+
+// GetMany looks up every key under a single lock acquisition.
+func (c *TypedTwoQueueCache[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return getMany(keys, c.getLocked)
+}
+
+// SetMany stores every item under a single lock acquisition.
+func (c *TypedTwoQueueCache[K, V]) SetMany(ctx context.Context, items map[K]TypedItem[V]) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return setMany(items, func(key K, item TypedItem[V]) error {
+		return c.setLocked(key, item.Value, item.TTL)
+	})
+}
+
+// DeleteMany removes every key under a single lock acquisition.
+func (c *TypedTwoQueueCache[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+	return deleteMany(keys, c.deleteLocked)
+}
+
+func (c *TypedTwoQueueCache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	for _, l := range []*list.List{c.a1in, c.am} {
+		for elem := l.Back(); elem != nil; elem = elem.Prev() {
+			entry := elem.Value.(*twoQueueEntry[K, V])
+			c.evicted(entry.key, entry.value, EvictReasonFlushed)
+		}
+		l.Init()
+	}
+	c.a1out.Init()
+	c.cache = make(map[K]*list.Element)
+	c.ghosts = make(map[K]*list.Element)
+	c.expiries = nil
+	return nil
+}
+
+// Close stops the background janitor, if one was configured.
+func (c *TypedTwoQueueCache[K, V]) Close() error {
+	c.janitor.Close()
+	return nil
+}
+
+// deleteExpired removes every entry whose TTL has elapsed.
+func (c *TypedTwoQueueCache[K, V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.unlock(&c.mu)
+
+	now := c.now().UnixNano()
+	for key, ok := c.expiries.next(now); ok; key, ok = c.expiries.next(now) {
+		c.remove(c.cache[key], EvictReasonExpired)
+	}
+}
+
+// reclaim frees one slot. If A1in has outgrown its share the oldest entry
+// there is evicted and its key remembered in A1out; otherwise the least
+// recently used entry of Am is evicted.
+func (c *TypedTwoQueueCache[K, V]) reclaim() {
+	if c.a1in.Len() > c.recentCap || c.am.Len() == 0 {
+		if oldest := c.a1in.Back(); oldest != nil {
+			key := oldest.Value.(*twoQueueEntry[K, V]).key
+			c.remove(oldest, EvictReasonCapacity)
+			c.addGhost(key)
+		}
+		return
+	}
+	c.remove(c.am.Back(), EvictReasonCapacity)
+}
+
+// addGhost remembers a key evicted from A1in, forgetting the oldest ghost
+// once A1out is full.
+func (c *TypedTwoQueueCache[K, V]) addGhost(key K) {
+	c.ghosts[key] = c.a1out.PushFront(key)
+	if c.a1out.Len() > c.ghostCap {
+		oldest := c.a1out.Back()
+		c.a1out.Remove(oldest)
+		delete(c.ghosts, oldest.Value.(K))
+	}
+}
+
+func (c *TypedTwoQueueCache[K, V]) remove(elem *list.Element, reason EvictReason) {
+	entry := elem.Value.(*twoQueueEntry[K, V])
+	if entry.list == twoQueueAm {
+		c.am.Remove(elem)
+	} else {
+		c.a1in.Remove(elem)
+	}
+	c.expiries.untrack(entry.expiry)
+	delete(c.cache, entry.key)
+	c.evicted(entry.key, entry.value, reason)
+}
@@ -1,5 +1,10 @@
 // Package zwis provides various cache implementations including in-memory,
 // LRU (Least Recently Used), LFU (Least Frequently Used), and ARC (Adaptive Replacement Cache).
+//
+// Every implementation is generic over its key and value types. The
+// non-generic names (Cache, LRUCache, LFUCache, ARCCache and MemoryCache) are
+// aliases for the string-keyed, interface{}-valued instantiations, so code
+// written against them keeps working unchanged.
 package zwis
 
 import (
@@ -17,16 +22,19 @@ Flush()
 
 */
 
-// Cache interface defines the methods that all cache implementations must support.
-type Cache interface {
+// TypedCache interface defines the methods that all cache implementations must support.
+type TypedCache[K comparable, V any] interface {
 	// Set adds an item to the cache, replacing any existing item. If the TTL
 	// is 0, the item never expires.
-	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Set(ctx context.Context, key K, value V, ttl time.Duration) error
 	// Get retrieves an item from the cache. It returns the item and a boolean
 	// indicating whether the key was found.
-	Get(ctx context.Context, key string) (interface{}, bool)
+	Get(ctx context.Context, key K) (V, bool)
 	// Delete removes the provided key from the cache.
-	Delete(ctx context.Context, key string) error
-	// Clear removes all items from the cache.
+	Delete(ctx context.Context, key K) error
+	// Flush removes all items from the cache.
 	Flush(ctx context.Context) error
 }
+
+// Cache is the string-keyed, interface{}-valued form of TypedCache.
+type Cache = TypedCache[string, interface{}]
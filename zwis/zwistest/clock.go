@@ -0,0 +1,92 @@
+// Package zwistest provides helpers for testing code that uses zwis caches.
+package zwistest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NonsoAmadi10/zwis/zwis"
+)
+
+// FakeClock is a zwis.Clock that only moves when Advance is called. Pass it
+// to a cache with zwis.WithClock to expire entries and run the janitor
+// without sleeping.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+var _ zwis.Clock = (*FakeClock)(nil)
+
+// fakeTicker is a callback registered with Every.
+type fakeTicker struct {
+	interval time.Duration
+	next     time.Time
+	f        func()
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Every registers f to be called by Advance each time the clock passes
+// another multiple of d.
+func (c *FakeClock) Every(d time.Duration, f func()) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{interval: d, next: c.now.Add(d), f: f}
+	c.tickers = append(c.tickers, t)
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, other := range c.tickers {
+			if other == t {
+				c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Advance moves the clock forward by d. Callbacks registered with Every that
+// fall due are called in time order on the calling goroutine, with the clock
+// set to the time they were due, so Advance returns only after every janitor
+// sweep it triggered has finished.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		t := c.due(end)
+		if t == nil {
+			break
+		}
+		c.now = t.next
+		t.next = t.next.Add(t.interval)
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// due returns the ticker that falls due soonest, no later than end, or nil.
+// c.mu must be held.
+func (c *FakeClock) due(end time.Time) *fakeTicker {
+	var first *fakeTicker
+	for _, t := range c.tickers {
+		if !t.next.After(end) && (first == nil || t.next.Before(first.next)) {
+			first = t
+		}
+	}
+	return first
+}